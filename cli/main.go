@@ -6,16 +6,70 @@
 // Usage of `d2w`:
 //	 d2w [options]
 // Options:
+//   -buffer float
+//         include stations within this distance in km from the domain border
+//   -bufrtables string
+//         directory containing BUFR table B and table D CSV files (BUFR)
+//   -csvmapping string
+//         JSON file describing columns, units and time format of input files (CSV)
 //   -date string
 //         date and hour of the data to download [YYYYMMDDHH]
+//   -dem string
+//         DEM file used for stations elevation (.nc, .tif or .asc), instead of ~/.dewetra2wrf/orog.nc
 //   -domain string
-//         domain to filter stations to download [MinLat,MaxLat,MinLon,MaxLon]
+//         domain to filter stations to download [MinLat,MaxLat,MinLon,MaxLon], WKT polygon, .wkt, .geojson, .shp, geo_em or wrfinput file
+//   -elevation string
+//         where stations elevation is taken from (REPORTED_OR_DEM, REPORTED or DEM) (default "REPORTED_OR_DEM")
+//   -elevgap string
+//         how stations above -maxelevgap are handled (REJECT or INFLATE) (default "REJECT")
 //   -format string
-//         format of input files (DEWETRA or WUNDERGROUND) (default ".")
+//         format of input files (DEWETRA, WUNDERGROUND, WUNDERHIST, METAR, SYNOP, BUFR, CSV, NETATMO, WRFASCII, LITTLER or WYOMING) (default ".")
+//   -geojson string
+//         where to save a GeoJSON map of stations, if given
 //   -input string
 //         where to read input files (default ".")
+//   -margin float
+//         grid cells along borders of WRF domains whose stations are excluded (geo_em or wrfinput domain)
+//   -maxelevgap float
+//         maximum gap in meters between station elevation and model terrain height (with -terrain)
 //   -outfile string
 //         where to save converted file (default "./out")
+//   -outformat string
+//         format of converted file (WRFASCII or NETCDF) (default "WRFASCII")
+//   -precipout string
+//         where to save precipitation of stations, as NetCDF if it ends with .nc, otherwise as CSV, if given
+//   -precipperiod string
+//         period over which precipitation is accumulated (1h, 3h, 6h or 24h) with DEWETRA, WUNDERGROUND, WUNDERHIST, SYNOP or NETATMO formats, if given
+//   -stationcache string
+//         JSON file caching DEM elevation of stations between runs, if given
+//   -stations string
+//         CSV table of stations coordinates, for formats that lack them (METAR, SYNOP, CSV)
+//   -temp12h
+//         read temperatures of 12 hours before from input files, to reduce pressure with the 12 hours mean temperature
+//   -terrain string
+//         geo_em file whose terrain height is compared with stations elevation, if given
+//   -windwindow string
+//         time window over which wind is averaged as a vector, e.g. 10m (DEWETRA), if given
+//
+// Usage of `d2w stations`:
+//	 d2w stations [options]
+// Options:
+//   -active string
+//         list only stations active at this date and hour [YYYYMMDDHH]
+//   -domain string
+//         list only stations within this domain [MinLat,MaxLat,MinLon,MaxLon], WKT polygon, .wkt, .geojson or .shp file
+//   -format string
+//         format of files to import (DEWETRA or WUNDERGROUND) (default "DEWETRA")
+//   -id string
+//         ID of a station, or of one of its sensors, to show
+//   -import string
+//         directory of files whose stations are added to the registry, if given
+//   -network string
+//         list only stations of this network (DPCTrusted or Wunderground)
+//   -registry string
+//         JSON file of the stations registry
+//   -sensor string
+//         list only stations with a sensor of this class
 //
 package main
 
@@ -27,14 +81,37 @@ import (
 	"time"
 
 	"github.com/meteocima/dewetra2wrf"
+	"github.com/meteocima/dewetra2wrf/obsreader"
 )
 
 func main() {
-	format := flag.String("format", ".", "format of input files (DEWETRA or WUNDERGROUND)")
+	if len(os.Args) > 1 && os.Args[1] == "stations" {
+		stationsCommand(os.Args[2:])
+		return
+	}
+
+	format := flag.String("format", ".", "format of input files (DEWETRA, WUNDERGROUND, WUNDERHIST, METAR, SYNOP, BUFR, CSV, NETATMO, WRFASCII, LITTLER or WYOMING)")
 	input := flag.String("input", ".", "where to read input files")
 	outfile := flag.String("outfile", "./out", "where to save converted file")
-	domainS := flag.String("domain", "", "domain to filter stations to download [MinLat,MaxLat,MinLon,MaxLon]")
+	outformat := flag.String("outformat", "WRFASCII", "format of converted file (WRFASCII or NETCDF)")
+	domainS := flag.String("domain", "", "domain to filter stations to download [MinLat,MaxLat,MinLon,MaxLon], WKT polygon, .wkt, .geojson, .shp, geo_em or wrfinput file")
+	geojson := flag.String("geojson", "", "where to save a GeoJSON map of stations, if given")
+	csvMapping := flag.String("csvmapping", "", "JSON file describing columns, units and time format of input files (CSV)")
 	dateS := flag.String("date", "", "date and hour of the data to download [YYYYMMDDHH]")
+	buffer := flag.Float64("buffer", 0, "include stations within this distance in km from the domain border")
+	bufrTables := flag.String("bufrtables", "", "directory containing BUFR table B and table D CSV files (BUFR)")
+	margin := flag.Float64("margin", 0, "grid cells along borders of WRF domains whose stations are excluded (geo_em or wrfinput domain)")
+	terrainFile := flag.String("terrain", "", "geo_em file whose terrain height is compared with stations elevation, if given")
+	maxElevGap := flag.Float64("maxelevgap", 0, "maximum gap in meters between station elevation and model terrain height (with -terrain)")
+	elevGap := flag.String("elevgap", "REJECT", "how stations above -maxelevgap are handled (REJECT or INFLATE)")
+	demFile := flag.String("dem", "", "DEM file used for stations elevation (.nc, .tif or .asc), instead of ~/.dewetra2wrf/orog.nc")
+	elevation := flag.String("elevation", "REPORTED_OR_DEM", "where stations elevation is taken from (REPORTED_OR_DEM, REPORTED or DEM)")
+	stationCache := flag.String("stationcache", "", "JSON file caching DEM elevation of stations between runs, if given")
+	precipPeriodS := flag.String("precipperiod", "", "period over which precipitation is accumulated (1h, 3h, 6h or 24h) with DEWETRA, WUNDERGROUND, WUNDERHIST, SYNOP or NETATMO formats, if given")
+	precipOut := flag.String("precipout", "", "where to save precipitation of stations, as NetCDF if it ends with .nc, otherwise as CSV, if given")
+	windWindowS := flag.String("windwindow", "", "time window over which wind is averaged as a vector, e.g. 10m (DEWETRA), if given")
+	temp12h := flag.Bool("temp12h", false, "read temperatures of 12 hours before from input files, to reduce pressure with the 12 hours mean temperature")
+	stations := flag.String("stations", "", "CSV table of stations coordinates, for formats that lack them (METAR, SYNOP, CSV)")
 
 	flag.Parse()
 
@@ -50,10 +127,57 @@ func main() {
 		os.Exit(1)
 	}
 
+	var precipPeriod time.Duration
+	if *precipPeriodS != "" {
+		precipPeriod, err = time.ParseDuration(*precipPeriodS)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			flag.Usage()
+			os.Exit(1)
+		}
+	}
+
+	var windWindow time.Duration
+	if *windWindowS != "" {
+		windWindow, err = time.ParseDuration(*windWindowS)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			flag.Usage()
+			os.Exit(1)
+		}
+	}
+
 	var form dewetra2wrf.InputFormat
 	form.FromString(*format)
 
-	err = dewetra2wrf.Convert(form, *input, *domainS, date, *outfile)
+	var outform dewetra2wrf.OutputFormat
+	outform.FromString(*outformat)
+
+	var gapPolicy dewetra2wrf.ElevationGapPolicy
+	gapPolicy.FromString(*elevGap)
+
+	var elevSource obsreader.ElevationSource
+	elevSource.FromString(*elevation)
+
+	err = dewetra2wrf.ConvertWithOptions(form, *input, *domainS, date, *outfile, dewetra2wrf.Options{
+		StationsFile:       *stations,
+		BufrTablesDir:      *bufrTables,
+		CSVMappingFile:     *csvMapping,
+		OutputFormat:       outform,
+		GeoJSONFile:        *geojson,
+		Buffer:             *buffer,
+		DomainMargin:       *margin,
+		TerrainFile:        *terrainFile,
+		MaxElevationGap:    *maxElevGap,
+		ElevationGapPolicy: gapPolicy,
+		ElevationSource:    elevSource,
+		DEMFile:            *demFile,
+		StationCacheFile:   *stationCache,
+		PrecipPeriod:       precipPeriod,
+		PrecipitationFile:  *precipOut,
+		WindWindow:         windWindow,
+		Temperature12h:     *temp12h,
+	})
 
 	if err != nil {
 		log.Fatal(err)
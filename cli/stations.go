@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/stations"
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// stationsCommand implements `d2w stations`, that imports
+// stations into a registry file, lists them or shows
+// the metadata of one of them.
+func stationsCommand(args []string) {
+	flags := flag.NewFlagSet("stations", flag.ExitOnError)
+	registryFile := flags.String("registry", "", "JSON file of the stations registry")
+	importDir := flags.String("import", "", "directory of files whose stations are added to the registry, if given")
+	format := flags.String("format", "DEWETRA", "format of files to import (DEWETRA or WUNDERGROUND)")
+	id := flags.String("id", "", "ID of a station, or of one of its sensors, to show")
+	network := flags.String("network", "", "list only stations of this network (DPCTrusted or Wunderground)")
+	domainS := flags.String("domain", "", "list only stations within this domain [MinLat,MaxLat,MinLon,MaxLon], WKT polygon, .wkt, .geojson or .shp file")
+	activeS := flags.String("active", "", "list only stations active at this date and hour [YYYYMMDDHH]")
+	sensor := flags.String("sensor", "", "list only stations with a sensor of this class")
+	flags.Parse(args)
+
+	if *registryFile == "" {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	registry, err := stations.Open(*registryFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *importDir != "" {
+		if *format == "DEWETRA" {
+			err = registry.ImportDewetra(*importDir)
+		} else if *format == "WUNDERGROUND" {
+			err = registry.ImportWunderground(*importDir)
+		} else {
+			err = fmt.Errorf("Unknown stations format %s", *format)
+		}
+		if err == nil {
+			err = registry.Save(*registryFile)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *id != "" {
+		station, ok := registry.Get(*id)
+		if !ok {
+			log.Fatalf("station %s not found", *id)
+		}
+		printStation(station)
+		return
+	}
+
+	q := stations.Query{Network: *network, SensorClass: *sensor}
+	if *domainS != "" {
+		q.Region, err = types.RegionFromS(*domainS)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *activeS != "" {
+		q.ActiveAt, err = time.Parse("2006010215", *activeS)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNETWORK\tNAME\tLAT\tLON\tELEVATION\tSENSORS")
+	for _, s := range registry.Query(q) {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.5f\t%.5f\t%s\t%d\n", s.ID, s.Network, s.Name, s.Lat, s.Lon, elevationS(s.Elevation), len(s.Sensors))
+	}
+	w.Flush()
+}
+
+func elevationS(elevation types.Value) string {
+	if elevation.IsNaN() {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f", elevation)
+}
+
+func printStation(s *stations.Station) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%s\n", s.ID)
+	fmt.Fprintf(w, "Name:\t%s\n", s.Name)
+	fmt.Fprintf(w, "Network:\t%s\n", s.Network)
+	fmt.Fprintf(w, "Coordinates:\t%.5f, %.5f\n", s.Lat, s.Lon)
+	fmt.Fprintf(w, "Elevation:\t%s\n", elevationS(s.Elevation))
+	fmt.Fprintln(w, "Sensors:")
+	for _, sensor := range s.Sensors {
+		fmt.Fprintf(w, "\t%s\t%s\t%s\n", sensor.Class, sensor.Unit, sensor.ID)
+	}
+	fmt.Fprintln(w, "Active:")
+	for _, p := range s.Active {
+		fmt.Fprintf(w, "\t%s\t%s\n", p.From.Format(time.RFC3339), p.To.Format(time.RFC3339))
+	}
+	w.Flush()
+}
@@ -16,17 +16,42 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
 	"strings"
 	"time"
 	"unicode"
 
 	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/meteocima/dewetra2wrf/wind"
 )
 
 // qc is
 const qc = 0
 
+// observation errors written for each variable
+const (
+	pressureError          = 1.0
+	speedError             = 1.0
+	directionError         = 3.0
+	heightError            = 100.0
+	temperatureError       = 1.0
+	dewpointError          = 1.0
+	humidityError          = 2.0
+	seaLevelPressureError  = 99.99
+	precipitableWaterError = 99.99
+	// surfaceHeightError is written with the height of
+	// surface observations, that is always missing:
+	// station elevation is in the INFO record.
+	surfaceHeightError = 999.99
+)
+
+// inflated returns err increased, in
+// quadrature, by inflation.
+func inflated(err, inflation float64) float64 {
+	return math.Hypot(err, inflation)
+}
+
 func str(s string, ln int) string {
 	strFmt := fmt.Sprintf("%%-%ds", ln)
 	res := fmt.Sprintf(strFmt, s)
@@ -105,8 +130,16 @@ func writeCols(w io.Writer, values []string) {
 	bufw.WriteRune('\n')
 }
 
+// windComponents returns the eastward and
+// northward components of the wind of obs.
+func windComponents(obs types.Observation) (u, v types.Value) {
+	uf, vf := wind.Components(obs.Metric.WindspeedAvg.AsFloat(), obs.WinddirAvg.AsFloat())
+	return types.Value(uf), types.Value(vf)
+}
+
 // WriteCSVObservation ...
 func WriteCSVObservation(w io.Writer, obs types.Observation) {
+	u, v := windComponents(obs)
 	writeCols(w, []string{
 		obs.StationID,
 		num(types.Value(obs.Lat), 12.3),
@@ -118,19 +151,45 @@ func WriteCSVObservation(w io.Writer, obs types.Observation) {
 		num(obs.HumidityAvg, 12.3),
 		num(obs.Metric.TempAvg, 12.3),
 		num(obs.Metric.WindspeedAvg, 12.3),
+		num(obs.Metric.WindgustAvg, 12.3),
+		num(obs.Metric.PrecipRate, 12.3),
+		num(obs.WinddirAvg, 12.3),
+		num(u, 12.3),
+		num(v, 12.3),
 	})
 }
 
-// ToWRFASCII converts a types.Observation into a string
+// eachLine formats a level of an
+// upper-air observation as an EACH record.
+func eachLine(level types.Level) string {
+	return dataQCError(num(level.Pressure, 12.3), pressureError) +
+		dataQCError(num(level.WindspeedAvg, 12.3), speedError) +
+		dataQCError(num(level.WinddirAvg, 12.3), directionError) +
+		space(11) +
+		dataQCError(num(level.Height, 12.3), heightError) +
+		dataQCError(num(level.TempAvg, 12.3), temperatureError) +
+		dataQCError(num(level.DewptAvg, 12.3), dewpointError) +
+		space(11) +
+		dataQCError(num(level.HumidityAvg, 12.3), humidityError)
+}
+
+// ToWRFASCII converts a types.Observation into a string.
+// Upper-air observations are written with
+// an EACH record for every level.
 func ToWRFASCII(obs types.Observation) string {
+	levels := len(obs.Levels)
+	if levels == 0 {
+		levels = 1
+	}
+
 	firstLine :=
-		str("FM-12 SYNOP", 12) +
+		str(obs.PlatformType(), 12) +
 			" " +
 			date(obs.ObsTimeUtc) +
 			" " +
 			str(onlyletters(obs.StationName), 40) +
 			" " +
-			integer(1, 6) +
+			integer(levels, 6) +
 			num(types.Value(obs.Lat), 12.3) +
 			space(11) +
 			num(types.Value(obs.Lon), 12.3) +
@@ -140,23 +199,34 @@ func ToWRFASCII(obs types.Observation) string {
 			space(6) +
 			str(onlyletters(obs.StationID), 40)
 
-	surfaceLevelPressure := types.NaN()
-	precipTotal := types.NaN() // obs.Metric.PrecipTotal
+	// PW is the precipitable water of the column, not
+	// the precipitation fallen at the station, so
+	// obs.Metric.PrecipTotal can't be written here:
+	// use WritePrecipitationCSV or WritePrecipitationNetCDF.
+	precipitableWater := types.NaN()
 
 	secondLine :=
-		dataQCError(num(surfaceLevelPressure, 12.3), 99.99) +
-			dataQCError3(num(precipTotal, 12.3), 99.99)
+		dataQCError(num(obs.Metric.SeaLevelPressure, 12.3), seaLevelPressureError) +
+			dataQCError3(num(precipitableWater, 12.3), precipitableWaterError)
+
+	if len(obs.Levels) > 0 {
+		lines := []string{firstLine, secondLine}
+		for _, level := range obs.Levels {
+			lines = append(lines, eachLine(level))
+		}
+		return strings.Join(lines, "\n")
+	}
 
 	thirstLine :=
-		dataQCError(num( /*obs.Metric.Pressure*/ types.NaN(), 12.3), 1.0) +
-			dataQCError(num( /*obs.Metric.WindspeedAvg*/ types.NaN(), 12.3), 1.0) +
-			dataQCError(num( /*obs.WinddirAvg*/ types.NaN(), 12.3), 3.0) +
+		dataQCError(num(obs.Metric.Pressure, 12.3), pressureError) +
+			dataQCError(num(obs.Metric.WindspeedAvg, 12.3), speedError) +
+			dataQCError(num(obs.WinddirAvg, 12.3), directionError) +
 			space(11) +
-			dataQCError(num(types.Value(obs.Elevation), 12.3), 100.00) +
-			dataQCError(num(obs.Metric.TempAvg, 12.3), 1) +
-			dataQCError(num( /*obs.Metric.DewptAvg*/ types.NaN(), 12.3), 1.0) +
+			dataQCError(num(types.NaN(), 12.3), surfaceHeightError) +
+			dataQCError(num(obs.Metric.TempAvg, 12.3), inflated(temperatureError, obs.TempErrorInflation)) +
+			dataQCError(num(obs.Metric.DewptAvg, 12.3), inflated(dewpointError, obs.TempErrorInflation)) +
 			space(11) +
-			dataQCError(num( /*obs.HumidityAvg*/ types.NaN(), 12.3), 2)
+			dataQCError(num(obs.HumidityAvg, 12.3), humidityError)
 
 	return firstLine + "\n" + secondLine + "\n" + thirstLine
 }
@@ -20,9 +20,12 @@ var testobs = types.Observation{
 	WinddirAvg:  6,
 	Metric: types.ObservationMetric{
 		TempAvg:      7,
+		DewptAvg:     4,
 		WindspeedAvg: 8,
 		Pressure:     9,
 		PrecipTotal:  10,
+
+		SeaLevelPressure: 101320,
 	},
 }
 
@@ -32,11 +35,32 @@ func TestConvertToAscii(t *testing.T) {
 
 	expected := []string{
 		"FM-12 SYNOP  2020-03-30_18:01:02 FoggiaXIstitutoXAgrario                       1      41.469                 15.483               1234.000                 XXXXXXXXXXX                             ",
-		" -888888.000 -88  99.99 -888888.000 -88 99.990",
-		"       9.000   0   1.00       8.000   0   1.00       6.000   0   3.00            -888888.000 -88 999.99       7.000   0   1.00 -888888.000 -88   1.00                  5.000   0   2.00",
+		"  101320.000   0  99.99 -888888.000 -88 99.990",
+		"       9.000   0   1.00       8.000   0   1.00       6.000   0   3.00            -888888.000 -88 999.99       7.000   0   1.00       4.000   0   1.00                  5.000   0   2.00",
 	}
 	for i, l := range strings.Split(actual, "\n") {
 		assert.Equal(t, expected[i], l, "lines %d differs", i)
 
 	}
 }
+
+func TestInflatedTemperatureError(t *testing.T) {
+	obs := testobs
+	obs.TempErrorInflation = 3.25
+	lines := strings.Split(ToWRFASCII(obs), "\n")
+	assert.Equal(t, "       7.000   0   3.40", lines[2][103:126])
+	assert.Equal(t, "       4.000   0   3.40", lines[2][126:149])
+
+	temp := outputVariables[0]
+	assert.InDelta(t, 3.4004, temp.errorOf(obs), 1e-4)
+	assert.Equal(t, humidityError, outputVariables[2].errorOf(obs))
+}
+
+func TestSurfacePressure(t *testing.T) {
+	obs := testobs
+	obs.Metric.Pressure = 87343.3
+	obs.Metric.SeaLevelPressure = 101320
+	lines := strings.Split(ToWRFASCII(obs), "\n")
+	assert.Equal(t, "  101320.000   0  99.99", lines[1][:23])
+	assert.Equal(t, "   87343.300   0   1.00", lines[2][:23])
+}
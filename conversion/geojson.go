@@ -0,0 +1,145 @@
+package conversion
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// Filter is the reason why an observation
+// was excluded from converted output.
+type Filter string
+
+// Filter values
+const (
+	// NotFiltered is used for observations included in output
+	NotFiltered Filter = ""
+	// FilteredByDomain is used for observations outside the domain
+	FilteredByDomain Filter = "domain"
+	// FilteredByQC is used for observations discarded by QC
+	FilteredByQC Filter = "qc"
+	// FilteredByElevation is used for observations whose elevation
+	// differs too much from model terrain height
+	FilteredByElevation Filter = "elevation"
+)
+
+// StationStatus is an observation together with
+// the filter that excluded it from output, if any.
+type StationStatus struct {
+	Observation types.Observation
+	Filter      Filter
+}
+
+// PassesQC returns whether obs contains at least a
+// valid value among written variables, or some levels.
+// Observations that don't are discarded from output.
+func PassesQC(obs types.Observation) bool {
+	if len(obs.Levels) > 0 {
+		return true
+	}
+	for _, v := range outputVariables {
+		if !v.value(obs).IsNaN() {
+			return true
+		}
+	}
+	return false
+}
+
+type geoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+type geoJSONProperties struct {
+	StationID   string              `json:"stationId"`
+	StationName string              `json:"stationName"`
+	Platform    string              `json:"platform"`
+	Group       string              `json:"group"`
+	Time        time.Time           `json:"time"`
+	Elevation   *float64            `json:"elevation"`
+	Levels      int                 `json:"levels"`
+	Included    bool                `json:"included"`
+	Filtered    *Filter             `json:"filtered"`
+	Variables   map[string]*float64 `json:"variables"`
+	QC          map[string]int32    `json:"qc"`
+}
+
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Geometry   geoJSONGeometry   `json:"geometry"`
+	Properties geoJSONProperties `json:"properties"`
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// geoJSONValue returns a pointer to v,
+// or nil if it's NaN, so that it's encoded as null.
+func geoJSONValue(v types.Value) *float64 {
+	if v.IsNaN() {
+		return nil
+	}
+	f := float64(v)
+	return &f
+}
+
+// WriteGeoJSON writes stations to w as a GeoJSON
+// FeatureCollection of points. Properties of each feature
+// contain values of the observation, their QC flag, and
+// the filter that excluded it from output, if any.
+func WriteGeoJSON(w io.Writer, stations []StationStatus) error {
+	collection := geoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]geoJSONFeature, len(stations)),
+	}
+
+	for i, station := range stations {
+		obs := station.Observation
+		elevation := geoJSONValue(types.Value(obs.Elevation))
+		coords := []float64{obs.Lon, obs.Lat}
+		if elevation != nil {
+			coords = append(coords, *elevation)
+		}
+
+		props := geoJSONProperties{
+			StationID:   obs.StationID,
+			StationName: obs.StationName,
+			Platform:    obs.PlatformType(),
+			Group:       obs.Group.String(),
+			Time:        obs.ObsTimeUtc,
+			Elevation:   elevation,
+			Levels:      len(obs.Levels),
+			Included:    station.Filter == NotFiltered,
+			Variables:   map[string]*float64{},
+			QC:          map[string]int32{},
+		}
+		if station.Filter != NotFiltered {
+			filter := station.Filter
+			props.Filtered = &filter
+		}
+		for _, v := range outputVariables {
+			value := v.value(obs)
+			props.Variables[v.name] = geoJSONValue(value)
+			props.QC[v.name] = qcFlag(value)
+		}
+
+		collection.Features[i] = geoJSONFeature{
+			Type: "Feature",
+			ID:   obs.StationID,
+			Geometry: geoJSONGeometry{
+				Type:        "Point",
+				Coordinates: coords,
+			},
+			Properties: props,
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(collection)
+}
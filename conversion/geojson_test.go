@@ -0,0 +1,78 @@
+package conversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPassesQC(t *testing.T) {
+	assert.True(t, PassesQC(testobs))
+
+	empty := types.Observation{
+		HumidityAvg: types.NaN(),
+		WinddirAvg:  types.NaN(),
+		Visibility:  types.NaN(),
+		Metric:      types.MissingMetric(),
+	}
+	assert.False(t, PassesQC(empty))
+
+	empty.Levels = []types.Level{{Pressure: 85000}}
+	assert.True(t, PassesQC(empty))
+}
+
+func TestWriteGeoJSON(t *testing.T) {
+	filtered := testobs
+	filtered.StationID = "ILIGURIA42"
+	filtered.Elevation = types.NaN().AsFloat()
+	filtered.Metric.TempAvg = types.NaN()
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteGeoJSON(&buf, []StationStatus{
+		{Observation: testobs},
+		{Observation: filtered, Filter: FilteredByDomain},
+	}))
+
+	var collection struct {
+		Type     string
+		Features []struct {
+			Type     string
+			ID       string
+			Geometry struct {
+				Type        string
+				Coordinates []float64
+			}
+			Properties map[string]interface{}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &collection))
+	assert.Equal(t, "FeatureCollection", collection.Type)
+	assert.Equal(t, 2, len(collection.Features))
+
+	included := collection.Features[0]
+	assert.Equal(t, "Feature", included.Type)
+	assert.Equal(t, "Point", included.Geometry.Type)
+	assert.Equal(t, []float64{15.483, 41.469, 1234}, included.Geometry.Coordinates)
+	assert.Equal(t, true, included.Properties["included"])
+	assert.Nil(t, included.Properties["filtered"])
+	assert.Equal(t, "Wunderground", included.Properties["group"])
+	assert.Equal(t, "2020-03-30T18:01:02Z", included.Properties["time"])
+	variables := included.Properties["variables"].(map[string]interface{})
+	assert.Equal(t, 7.0, variables["air_temperature"])
+	qc := included.Properties["qc"].(map[string]interface{})
+	assert.Equal(t, 0.0, qc["air_temperature"])
+
+	excluded := collection.Features[1]
+	assert.Equal(t, "ILIGURIA42", excluded.ID)
+	assert.Equal(t, []float64{15.483, 41.469}, excluded.Geometry.Coordinates)
+	assert.Equal(t, false, excluded.Properties["included"])
+	assert.Equal(t, "domain", excluded.Properties["filtered"])
+	assert.Nil(t, excluded.Properties["elevation"])
+	variables = excluded.Properties["variables"].(map[string]interface{})
+	assert.Nil(t, variables["air_temperature"])
+	qc = excluded.Properties["qc"].(map[string]interface{})
+	assert.Equal(t, -88.0, qc["air_temperature"])
+}
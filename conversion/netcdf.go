@@ -0,0 +1,301 @@
+package conversion
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/meteocima/dewetra2wrf/internal/ncdf"
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// missingValue is used as _FillValue
+// of NetCDF variables, like in ob.ascii files.
+const missingValue = -888888.0
+
+// qcMissing is the QC flag of missing values
+const qcMissing = -88
+
+// qcFlag returns the QC flag of value
+func qcFlag(value types.Value) int32 {
+	if value.IsNaN() {
+		return qcMissing
+	}
+	return qc
+}
+
+// outputVariable describes a variable of an observation
+// written by WriteNetCDF and WriteGeoJSON.
+// In NetCDF files, each of them is followed by a QC flag
+// variable and, when err is not NaN, by an observation
+// error variable.
+type outputVariable struct {
+	name         string
+	standardName string
+	units        string
+	err          float64
+	value        func(obs types.Observation) types.Value
+}
+
+var outputVariables = []outputVariable{
+	{"air_temperature", "air_temperature", "K", temperatureError,
+		func(obs types.Observation) types.Value { return obs.Metric.TempAvg }},
+	{"dew_point_temperature", "dew_point_temperature", "K", dewpointError,
+		func(obs types.Observation) types.Value { return obs.Metric.DewptAvg }},
+	{"relative_humidity", "relative_humidity", "%", humidityError,
+		func(obs types.Observation) types.Value { return obs.HumidityAvg }},
+	{"wind_speed", "wind_speed", "m s-1", speedError,
+		func(obs types.Observation) types.Value { return obs.Metric.WindspeedAvg }},
+	{"wind_speed_of_gust", "wind_speed_of_gust", "m s-1", math.NaN(),
+		func(obs types.Observation) types.Value { return obs.Metric.WindgustAvg }},
+	{"wind_from_direction", "wind_from_direction", "degree", directionError,
+		func(obs types.Observation) types.Value { return obs.WinddirAvg }},
+	{"eastward_wind", "eastward_wind", "m s-1", speedError,
+		func(obs types.Observation) types.Value { u, _ := windComponents(obs); return u }},
+	{"northward_wind", "northward_wind", "m s-1", speedError,
+		func(obs types.Observation) types.Value { _, v := windComponents(obs); return v }},
+	{"surface_air_pressure", "surface_air_pressure", "Pa", pressureError,
+		func(obs types.Observation) types.Value { return obs.Metric.Pressure }},
+	{"air_pressure_at_mean_sea_level", "air_pressure_at_mean_sea_level", "Pa", seaLevelPressureError,
+		func(obs types.Observation) types.Value { return obs.Metric.SeaLevelPressure }},
+	{"precipitation_amount", "precipitation_amount", "kg m-2", math.NaN(),
+		func(obs types.Observation) types.Value { return obs.Metric.PrecipTotal }},
+	{"precipitation_rate", "lwe_precipitation_rate", "mm h-1", math.NaN(),
+		func(obs types.Observation) types.Value { return obs.Metric.PrecipRate }},
+	{"visibility", "visibility_in_air", "m", math.NaN(),
+		func(obs types.Observation) types.Value { return obs.Visibility }},
+}
+
+// errorOf returns the observation error of v for obs:
+// errors of temperatures are inflated by obs.TempErrorInflation.
+func (v outputVariable) errorOf(obs types.Observation) float64 {
+	if v.units == "K" {
+		return inflated(v.err, obs.TempErrorInflation)
+	}
+	return v.err
+}
+
+// netcdfStations groups observations by station. It returns
+// the first observation of each station, and for each
+// observation the index of its station.
+func netcdfStations(observations []types.Observation) ([]types.Observation, []int32) {
+	stations := []types.Observation{}
+	index := make([]int32, len(observations))
+	stationIdx := map[string]int32{}
+
+	for i, obs := range observations {
+		key := fmt.Sprintf("%s:%05f:%05f", obs.StationID, obs.Lat, obs.Lon)
+		idx, ok := stationIdx[key]
+		if !ok {
+			idx = int32(len(stations))
+			stationIdx[key] = idx
+			stations = append(stations, obs)
+		}
+		index[i] = idx
+	}
+	return stations, index
+}
+
+// maxLen returns the length of the longest
+// of strings, or 1 if they are all empty.
+func maxLen(strings []string) uint64 {
+	res := 1
+	for _, s := range strings {
+		if len(s) > res {
+			res = len(s)
+		}
+	}
+	return uint64(res)
+}
+
+func netcdfValue(v types.Value) float64 {
+	if v.IsNaN() {
+		return missingValue
+	}
+	return float64(v)
+}
+
+// createTimeSeries creates filename as a CF-1.8 NetCDF
+// file of observations, with timeSeries features stored as
+// an indexed ragged array: station metadata use the station
+// dimension, while the station index, time and platform
+// of observations use the obs dimension.
+// Returned function writes their values,
+// and must be called after EndDef.
+func createTimeSeries(filename, title string, observations []types.Observation) (*ncdf.File, func()) {
+	stations, stationIndex := netcdfStations(observations)
+
+	ids := make([]string, len(stations))
+	names := make([]string, len(stations))
+	lats := make([]float64, len(stations))
+	lons := make([]float64, len(stations))
+	alts := make([]float64, len(stations))
+	for i, station := range stations {
+		ids[i] = station.StationID
+		names[i] = station.StationName
+		lats[i] = station.Lat
+		lons[i] = station.Lon
+		alts[i] = netcdfValue(types.Value(station.Elevation))
+	}
+
+	platforms := make([]string, len(observations))
+	times := make([]float64, len(observations))
+	for i, obs := range observations {
+		platforms[i] = obs.PlatformType()
+		times[i] = float64(obs.ObsTimeUtc.Unix())
+	}
+
+	f := ncdf.CreateFile(filename)
+	f.SetAttrib("Conventions", "CF-1.8")
+	f.SetAttrib("featureType", "timeSeries")
+	f.SetAttrib("title", title)
+	f.SetAttrib("source", "dewetra2wrf")
+
+	f.AddDim("station", uint64(len(stations)))
+	f.AddDim("obs", uint64(len(observations)))
+	f.AddDim("id_strlen", maxLen(ids))
+	f.AddDim("name_strlen", maxLen(names))
+	f.AddDim("platform_strlen", maxLen(platforms))
+
+	stationID := f.AddVar("station_id", ncdf.Char, "station", "id_strlen")
+	stationID.SetAttrib("long_name", "station identifier")
+	stationID.SetAttrib("cf_role", "timeseries_id")
+
+	stationName := f.AddVar("station_name", ncdf.Char, "station", "name_strlen")
+	stationName.SetAttrib("long_name", "station name")
+
+	lat := f.AddVar("lat", ncdf.Float64, "station")
+	lat.SetAttrib("standard_name", "latitude")
+	lat.SetAttrib("long_name", "station latitude")
+	lat.SetAttrib("units", "degrees_north")
+
+	lon := f.AddVar("lon", ncdf.Float64, "station")
+	lon.SetAttrib("standard_name", "longitude")
+	lon.SetAttrib("long_name", "station longitude")
+	lon.SetAttrib("units", "degrees_east")
+
+	alt := f.AddVar("alt", ncdf.Float64, "station")
+	alt.SetAttrib("standard_name", "altitude")
+	alt.SetAttrib("long_name", "station elevation")
+	alt.SetAttrib("units", "m")
+	alt.SetAttrib("positive", "up")
+	alt.SetAttrib("axis", "Z")
+	alt.SetAttribFloat64s("_FillValue", missingValue)
+
+	index := f.AddVar("station_index", ncdf.Int32, "obs")
+	index.SetAttrib("long_name", "index of the station of this observation")
+	index.SetAttrib("instance_dimension", "station")
+
+	timeVar := f.AddVar("time", ncdf.Float64, "obs")
+	timeVar.SetAttrib("standard_name", "time")
+	timeVar.SetAttrib("long_name", "time of observation")
+	timeVar.SetAttrib("units", "seconds since 1970-01-01 00:00:00 UTC")
+	timeVar.SetAttrib("calendar", "standard")
+
+	platform := f.AddVar("platform", ncdf.Char, "obs", "platform_strlen")
+	platform.SetAttrib("long_name", "WMO platform type")
+
+	return f, func() {
+		stationID.WriteStrings(ids)
+		stationName.WriteStrings(names)
+		lat.WriteFloat64s(lats)
+		lon.WriteFloat64s(lons)
+		alt.WriteFloat64s(alts)
+		index.WriteInt32s(stationIndex)
+		timeVar.WriteFloat64s(times)
+		platform.WriteStrings(platforms)
+	}
+}
+
+// WriteNetCDF writes observations to filename as a
+// CF-1.8 NetCDF file, using the discrete sampling
+// geometry of timeSeries features stored as an indexed
+// ragged array: station metadata use the station dimension,
+// and values the obs dimension.
+// Model terrain height at stations and its gap from
+// their elevation are written too, as missing when
+// Observation.ModelElevation is NaN.
+// Only surface values are written: levels of upper-air
+// observations are ignored.
+func WriteNetCDF(filename string, observations []types.Observation) error {
+	f, writeTimeSeries := createTimeSeries(filename, "Weather stations observations", observations)
+
+	modelAlt := f.AddVar("model_alt", ncdf.Float64, "station")
+	modelAlt.SetAttrib("long_name", "model terrain height at station location")
+	modelAlt.SetAttrib("units", "m")
+	modelAlt.SetAttribFloat64s("_FillValue", missingValue)
+
+	altGap := f.AddVar("alt_gap", ncdf.Float64, "station")
+	altGap.SetAttrib("long_name", "station elevation minus model terrain height")
+	altGap.SetAttrib("units", "m")
+	altGap.SetAttribFloat64s("_FillValue", missingValue)
+
+	type dataVars struct {
+		value, qc, err *ncdf.Variable
+	}
+	vars := make([]dataVars, len(outputVariables))
+	for i, v := range outputVariables {
+		ancillary := v.name + "_qc"
+		if !math.IsNaN(v.err) {
+			ancillary += " " + v.name + "_error"
+		}
+
+		vars[i].value = f.AddVar(v.name, ncdf.Float64, "obs")
+		vars[i].value.SetAttrib("standard_name", v.standardName)
+		vars[i].value.SetAttrib("units", v.units)
+		vars[i].value.SetAttrib("coordinates", "time lat lon alt")
+		vars[i].value.SetAttrib("ancillary_variables", ancillary)
+		vars[i].value.SetAttribFloat64s("_FillValue", missingValue)
+
+		vars[i].qc = f.AddVar(v.name+"_qc", ncdf.Int32, "obs")
+		vars[i].qc.SetAttrib("long_name", "quality control flag of "+v.name)
+		vars[i].qc.SetAttrib("standard_name", v.standardName+" status_flag")
+		vars[i].qc.SetAttribInt32s("flag_values", qcMissing, qc)
+		vars[i].qc.SetAttrib("flag_meanings", "missing good")
+
+		if !math.IsNaN(v.err) {
+			vars[i].err = f.AddVar(v.name+"_error", ncdf.Float64, "obs")
+			vars[i].err.SetAttrib("long_name", "observation error of "+v.name)
+			vars[i].err.SetAttrib("units", v.units)
+			vars[i].err.SetAttribFloat64s("_FillValue", missingValue)
+		}
+	}
+
+	f.EndDef()
+	writeTimeSeries()
+
+	stations, _ := netcdfStations(observations)
+	modelAlts := make([]float64, len(stations))
+	altGaps := make([]float64, len(stations))
+	for i, station := range stations {
+		modelAlts[i] = netcdfValue(types.Value(station.ModelElevation))
+		altGaps[i] = netcdfValue(types.Value(station.Elevation - station.ModelElevation))
+	}
+	modelAlt.WriteFloat64s(modelAlts)
+	altGap.WriteFloat64s(altGaps)
+
+	for i, v := range outputVariables {
+		values := make([]float64, len(observations))
+		qcs := make([]int32, len(observations))
+		errs := make([]float64, len(observations))
+		for j, obs := range observations {
+			value := v.value(obs)
+			values[j] = netcdfValue(value)
+			qcs[j] = qcFlag(value)
+			errs[j] = v.errorOf(obs)
+			if value.IsNaN() {
+				errs[j] = missingValue
+			}
+		}
+		vars[i].value.WriteFloat64s(values)
+		vars[i].qc.WriteInt32s(qcs)
+		if vars[i].err != nil {
+			vars[i].err.WriteFloat64s(errs)
+		}
+	}
+
+	if err := f.Error(); err != nil {
+		return err
+	}
+	f.Close()
+	return f.Error()
+}
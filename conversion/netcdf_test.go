@@ -0,0 +1,73 @@
+package conversion
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/meteocima/dewetra2wrf/internal/ncdf"
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetCDFStations(t *testing.T) {
+	second := testobs
+	second.StationID = "ILIGURIA42"
+	third := testobs
+	third.ObsTimeUtc = testobs.ObsTimeUtc.Add(3600)
+
+	stations, index := netcdfStations([]types.Observation{testobs, second, third})
+	assert.Equal(t, 2, len(stations))
+	assert.Equal(t, "ILIGURIA42", stations[1].StationID)
+	assert.Equal(t, []int32{0, 1, 0}, index)
+}
+
+func TestWriteNetCDF(t *testing.T) {
+	first := testobs
+	first.Metric.SeaLevelPressure = types.NaN()
+	first.ModelElevation = 1000
+	first.TempErrorInflation = 1
+	second := testobs
+	second.StationID = "ILIGURIA42"
+	second.Metric.TempAvg = types.NaN()
+	second.Metric.SeaLevelPressure = 101320
+	second.ModelElevation = math.NaN()
+
+	file := filepath.Join(t.TempDir(), "obs.nc")
+	assert.NoError(t, WriteNetCDF(file, []types.Observation{first, second}))
+
+	f := ncdf.OpenFile(file)
+	defer f.Close()
+	assert.Equal(t, "CF-1.8", f.Attrib("Conventions"))
+	assert.Equal(t, "timeSeries", f.Attrib("featureType"))
+
+	assert.Equal(t, []float64{41.469, 41.469}, f.Var("lat").ValuesFloat64())
+	assert.Equal(t, []int32{0, 1}, f.Var("station_index").ValuesInt32())
+	assert.Equal(t, []float64{float64(testobs.ObsTimeUtc.Unix()), float64(testobs.ObsTimeUtc.Unix())}, f.Var("time").ValuesFloat64())
+
+	temp := f.Var("air_temperature")
+	assert.Equal(t, "K", temp.Attrib("units"))
+	assert.Equal(t, "air_temperature_qc air_temperature_error", temp.Attrib("ancillary_variables"))
+	assert.Equal(t, []float64{7, -888888}, temp.ValuesFloat64())
+	assert.Equal(t, []int32{0, -88}, f.Var("air_temperature_qc").ValuesInt32())
+	assert.Equal(t, []float64{math.Sqrt2, -888888}, f.Var("air_temperature_error").ValuesFloat64())
+	assert.Equal(t, []float64{speedError, speedError}, f.Var("wind_speed_error").ValuesFloat64())
+	assert.Equal(t, []float64{1000, -888888}, f.Var("model_alt").ValuesFloat64())
+	assert.Equal(t, []float64{testobs.Elevation - 1000, -888888}, f.Var("alt_gap").ValuesFloat64())
+	assert.Equal(t, []float64{-888888, 101320}, f.Var("air_pressure_at_mean_sea_level").ValuesFloat64())
+	// wind of 8 m/s from 6°
+	assert.InDelta(t, -0.836, f.Var("eastward_wind").ValuesFloat64()[0], 1e-3)
+	assert.InDelta(t, -7.956, f.Var("northward_wind").ValuesFloat64()[0], 1e-3)
+	assert.NoError(t, f.Error())
+}
+
+func TestWriteNetCDFEmpty(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "obs.nc")
+	assert.NoError(t, WriteNetCDF(file, []types.Observation{}))
+
+	f := ncdf.OpenFile(file)
+	defer f.Close()
+	assert.Equal(t, "timeSeries", f.Attrib("featureType"))
+	assert.Equal(t, []uint64{0}, f.Var("air_temperature").Dims())
+	assert.NoError(t, f.Error())
+}
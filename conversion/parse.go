@@ -0,0 +1,254 @@
+package conversion
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// Measure is a single value of an ob.ascii
+// record, with its quality control flag and
+// observation error.
+type Measure struct {
+	Value types.Value
+	QC    int
+	Error float64
+}
+
+// Level contains the values of
+// an EACH record of an ob.ascii report.
+type Level struct {
+	Pressure    Measure
+	Speed       Measure
+	Direction   Measure
+	Height      Measure
+	Temperature Measure
+	Dewpoint    Measure
+	Humidity    Measure
+}
+
+// Report is an observation report read from an
+// ob.ascii file: an INFO record, a SRFC
+// record and one EACH record for every level.
+type Report struct {
+	Platform          string
+	Date              time.Time
+	Name              string
+	Lat, Lon          float64
+	Elevation         float64
+	ID                string
+	SeaLevelPressure  Measure
+	PrecipitableWater Measure
+	Levels            []Level
+}
+
+// Observation converts the report into a types.Observation,
+// using the first level as surface values. Reports with
+// more than one level fill the Levels of the observation.
+func (r Report) Observation() types.Observation {
+	obs := types.Observation{
+		Elevation:   r.Elevation,
+		StationID:   r.ID,
+		StationName: r.Name,
+		ObsTimeUtc:  r.Date,
+		Lat:         r.Lat,
+		Lon:         r.Lon,
+		HumidityAvg: types.NaN(),
+		WinddirAvg:  types.NaN(),
+		Visibility:  types.NaN(),
+		UvHigh:      types.NaN(),
+		Platform:    r.Platform,
+		Group:       types.Unknown,
+		Metric:      types.MissingMetric(),
+	}
+	obs.Metric.SeaLevelPressure = r.SeaLevelPressure.Value
+	if len(r.Levels) > 0 {
+		surface := r.Levels[0]
+		obs.Metric.Pressure = surface.Pressure.Value
+		obs.Metric.WindspeedAvg = surface.Speed.Value
+		obs.WinddirAvg = surface.Direction.Value
+		obs.Metric.TempAvg = surface.Temperature.Value
+		obs.Metric.DewptAvg = surface.Dewpoint.Value
+		obs.HumidityAvg = surface.Humidity.Value
+	}
+	if len(r.Levels) > 1 {
+		obs.Levels = make([]types.Level, len(r.Levels))
+		for i, l := range r.Levels {
+			obs.Levels[i] = types.Level{
+				Pressure:     l.Pressure.Value,
+				Height:       l.Height.Value,
+				TempAvg:      l.Temperature.Value,
+				DewptAvg:     l.Dewpoint.Value,
+				WindspeedAvg: l.Speed.Value,
+				WinddirAvg:   l.Direction.Value,
+				HumidityAvg:  l.Humidity.Value,
+			}
+		}
+	}
+	return obs
+}
+
+// headerEnd is the line that separates
+// the header of an ob.ascii file from reports.
+const headerEnd = "#------"
+
+// field returns the columns from start to end
+// of line, without surrounding spaces.
+func field(line string, start, end int) string {
+	if start >= len(line) {
+		return ""
+	}
+	if end > len(line) {
+		end = len(line)
+	}
+	return strings.TrimSpace(line[start:end])
+}
+
+func parseNum(s string) (types.Value, error) {
+	if s == "" {
+		return types.NaN(), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return types.NaN(), err
+	}
+	if f == -888888 || f == -999999 {
+		return types.NaN(), nil
+	}
+	return types.Value(f), nil
+}
+
+// parseMeasure parses a (DATA,QC,ERROR) triplet
+// starting at column start of line.
+func parseMeasure(line string, start int, errWidth int) (Measure, error) {
+	var m Measure
+	var err error
+	if m.Value, err = parseNum(field(line, start, start+12)); err != nil {
+		return m, err
+	}
+	if qc := field(line, start+12, start+16); qc != "" {
+		if m.QC, err = strconv.Atoi(qc); err != nil {
+			return m, err
+		}
+	}
+	if e := field(line, start+16, start+16+errWidth); e != "" {
+		if m.Error, err = strconv.ParseFloat(e, 64); err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+func parseInfo(line string) (Report, int, error) {
+	var r Report
+	var err error
+	r.Platform = field(line, 0, 12)
+	if r.Date, err = time.Parse("2006-01-02_15:04:05", field(line, 13, 32)); err != nil {
+		return r, 0, err
+	}
+	r.Name = field(line, 33, 73)
+	levels, err := strconv.Atoi(field(line, 74, 80))
+	if err != nil {
+		return r, 0, err
+	}
+	coords := []*float64{&r.Lat, &r.Lon, &r.Elevation}
+	for i, c := range coords {
+		v, err := parseNum(field(line, 80+i*23, 92+i*23))
+		if err != nil {
+			return r, 0, err
+		}
+		*c = float64(v)
+	}
+	r.ID = field(line, 155, 195)
+	return r, levels, nil
+}
+
+func parseSrfc(line string, r *Report) (err error) {
+	if r.SeaLevelPressure, err = parseMeasure(line, 0, 7); err != nil {
+		return err
+	}
+	r.PrecipitableWater, err = parseMeasure(line, 23, 7)
+	return err
+}
+
+func parseEach(line string) (Level, error) {
+	var l Level
+	// columns of each measure, following EACH_FMT
+	measures := []struct {
+		m     *Measure
+		start int
+	}{
+		{&l.Pressure, 0},
+		{&l.Speed, 23},
+		{&l.Direction, 46},
+		{&l.Height, 80},
+		{&l.Temperature, 103},
+		{&l.Dewpoint, 126},
+		{&l.Humidity, 160},
+	}
+	for _, f := range measures {
+		m, err := parseMeasure(line, f.start, 7)
+		if err != nil {
+			return l, err
+		}
+		*f.m = m
+	}
+	return l, nil
+}
+
+// ReadWRFASCII parses reports from r, which contains
+// text in the WRF ob.ascii format written by ToWRFASCII.
+// The header, if present, is skipped.
+func ReadWRFASCII(r io.Reader) ([]Report, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 1024), 1024*1024)
+
+	lines := []string{}
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	start := 0
+	for i, line := range lines {
+		if strings.HasPrefix(line, headerEnd) {
+			start = i + 1
+			break
+		}
+	}
+
+	reports := []Report{}
+	for i := start; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		report, levels, err := parseInfo(lines[i])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if i+1+levels >= len(lines) {
+			return nil, fmt.Errorf("line %d: report truncated", i+1)
+		}
+		i++
+		if err := parseSrfc(lines[i], &report); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		for l := 0; l < levels; l++ {
+			i++
+			level, err := parseEach(lines[i])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			report.Levels = append(report.Levels, level)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
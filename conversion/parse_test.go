@@ -0,0 +1,108 @@
+package conversion
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadWRFASCII(t *testing.T) {
+	text := "TOTAL =      1, MISS. =-888888.,\n" +
+		"#------------------------------------------------------------------------------#\n" +
+		"FM-12 SYNOP  2020-03-30_18:01:02 FoggiaXIstitutoXAgrario                       1      41.469                 15.483               1234.000                 XXXXXXXXXXX                             \n" +
+		" 101500.000   0 100.00 -888888.000 -88 99.990\n" +
+		"       9.000   0   1.00       8.000   2   1.50       6.000   0   3.00            -888888.000 -88 999.99       7.000   0   1.00 -888888.000 -88   1.00                  5.000   0   2.00"
+
+	reports, err := ReadWRFASCII(strings.NewReader(text))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(reports))
+
+	r := reports[0]
+	assert.Equal(t, "FM-12 SYNOP", r.Platform)
+	assert.Equal(t, time.Date(2020, 3, 30, 18, 1, 2, 0, time.UTC), r.Date)
+	assert.Equal(t, "FoggiaXIstitutoXAgrario", r.Name)
+	assert.Equal(t, "XXXXXXXXXXX", r.ID)
+	assert.Equal(t, 41.469, r.Lat)
+	assert.Equal(t, 15.483, r.Lon)
+	assert.Equal(t, 1234.0, r.Elevation)
+	assert.Equal(t, Measure{Value: 101500, QC: 0, Error: 100}, r.SeaLevelPressure)
+	assert.True(t, r.PrecipitableWater.Value.IsNaN())
+	assert.Equal(t, -88, r.PrecipitableWater.QC)
+
+	assert.Equal(t, 1, len(r.Levels))
+	l := r.Levels[0]
+	assert.Equal(t, Measure{Value: 9, QC: 0, Error: 1}, l.Pressure)
+	assert.Equal(t, Measure{Value: 8, QC: 2, Error: 1.5}, l.Speed)
+	assert.Equal(t, Measure{Value: 6, QC: 0, Error: 3}, l.Direction)
+	assert.True(t, l.Height.Value.IsNaN())
+	assert.Equal(t, 999.99, l.Height.Error)
+	assert.Equal(t, types.Value(7), l.Temperature.Value)
+	assert.True(t, l.Dewpoint.Value.IsNaN())
+	assert.Equal(t, Measure{Value: 5, QC: 0, Error: 2}, l.Humidity)
+
+	obs := r.Observation()
+	assert.Equal(t, types.Unknown, obs.Group)
+	assert.Equal(t, types.Value(9), obs.Metric.Pressure)
+	assert.Equal(t, types.Value(8), obs.Metric.WindspeedAvg)
+	assert.Equal(t, types.Value(6), obs.WinddirAvg)
+	assert.Equal(t, types.Value(7), obs.Metric.TempAvg)
+	assert.Equal(t, types.Value(5), obs.HumidityAvg)
+	assert.Equal(t, types.Value(101500), obs.Metric.SeaLevelPressure)
+}
+
+func TestWRFASCIIRoundTrip(t *testing.T) {
+	second := testobs
+	second.StationID = "ILIGURIA42"
+	second.StationName = "ILIGURIA42"
+	second.Platform = types.PlatformMetar
+	second.Metric.TempAvg = types.NaN()
+
+	written := []string{ToWRFASCII(testobs), ToWRFASCII(second)}
+
+	reports, err := ReadWRFASCII(strings.NewReader(strings.Join(written, "\n")))
+	assert.NoError(t, err)
+	assert.Equal(t, len(written), len(reports))
+
+	for i, r := range reports {
+		assert.Equal(t, written[i], ToWRFASCII(r.Observation()))
+	}
+}
+
+func TestReadWRFASCIITruncated(t *testing.T) {
+	text := "FM-12 SYNOP  2020-03-30_18:01:02 FoggiaXIstitutoXAgrario                       1      41.469                 15.483               1234.000                 XXXXXXXXXXX                             \n" +
+		" -888888.000 -88  99.99 -888888.000 -88 99.990\n"
+	_, err := ReadWRFASCII(strings.NewReader(text))
+	assert.EqualError(t, err, "line 1: report truncated")
+}
+
+func TestWRFASCIIMultiLevelRoundTrip(t *testing.T) {
+	sounding := testobs
+	sounding.Platform = types.PlatformTemp
+	sounding.Levels = []types.Level{
+		{Pressure: 100400, Height: 1234, TempAvg: 282.15, DewptAvg: 275.15, WindspeedAvg: 2, WinddirAvg: 90, HumidityAvg: 62},
+		{Pressure: 85000, Height: 1500, TempAvg: 275.15, DewptAvg: types.NaN(), WindspeedAvg: 10, WinddirAvg: 250, HumidityAvg: types.NaN()},
+		{Pressure: 50000, Height: 5600, TempAvg: 252.15, DewptAvg: types.NaN(), WindspeedAvg: 25, WinddirAvg: 270, HumidityAvg: types.NaN()},
+	}
+
+	written := ToWRFASCII(sounding)
+	lines := strings.Split(written, "\n")
+	assert.Equal(t, 5, len(lines))
+	assert.Equal(t, "FM-35 TEMP  ", lines[0][:12])
+	assert.Equal(t, "     3", lines[0][74:80])
+	assert.Equal(t, "   85000.000   0   1.00      10.000   0   1.00     250.000   0   3.00               1500.000   0 100.00     275.150   0   1.00 -888888.000 -88   1.00            -888888.000 -88   2.00", lines[3])
+
+	reports, err := ReadWRFASCII(strings.NewReader(written))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(reports))
+	assert.Equal(t, 3, len(reports[0].Levels))
+
+	obs := reports[0].Observation()
+	assert.Equal(t, types.PlatformTemp, obs.PlatformType())
+	assert.Equal(t, 3, len(obs.Levels))
+	assert.Equal(t, types.Value(50000), obs.Levels[2].Pressure)
+	assert.Equal(t, types.Value(270), obs.Levels[2].WinddirAvg)
+	assert.Equal(t, written, ToWRFASCII(obs))
+}
@@ -0,0 +1,86 @@
+package conversion
+
+import (
+	"io"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/internal/ncdf"
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// WritePrecipitationCSV writes to w the precipitation of
+// observations, to verify its accumulation: a header line
+// is followed by a line for each observation, with its
+// station, network, the start and end of the accumulation
+// period and the amount in mm. The start is empty when
+// the period is unknown.
+func WritePrecipitationCSV(w io.Writer, observations []types.Observation) {
+	writeCols(w, []string{"station_id", "network", "lat", "lon", "elevation", "start", "end", "precipitation"})
+	for _, obs := range observations {
+		start := ""
+		if obs.PrecipPeriod > 0 {
+			start = obs.ObsTimeUtc.Add(-obs.PrecipPeriod).Format(time.RFC3339)
+		}
+		writeCols(w, []string{
+			obs.StationID,
+			obs.Group.String(),
+			num(types.Value(obs.Lat), 12.3),
+			num(types.Value(obs.Lon), 12.3),
+			num(types.Value(obs.Elevation), 12.3),
+			start,
+			obs.ObsTimeUtc.Format(time.RFC3339),
+			num(obs.Metric.PrecipTotal, 12.3),
+		})
+	}
+}
+
+// WritePrecipitationNetCDF writes the precipitation of
+// observations to filename, with the same layout used by
+// WriteNetCDF. The accumulation period of each observation,
+// ending at its time, is saved in accumulation_period.
+func WritePrecipitationNetCDF(filename string, observations []types.Observation) error {
+	f, writeTimeSeries := createTimeSeries(filename, "Weather stations precipitation", observations)
+
+	period := f.AddVar("accumulation_period", ncdf.Float64, "obs")
+	period.SetAttrib("long_name", "period of accumulation, ending at time")
+	period.SetAttrib("units", "hours")
+	period.SetAttribFloat64s("_FillValue", missingValue)
+
+	amount := f.AddVar("precipitation_amount", ncdf.Float64, "obs")
+	amount.SetAttrib("standard_name", "precipitation_amount")
+	amount.SetAttrib("units", "kg m-2")
+	amount.SetAttrib("cell_methods", "time: sum")
+	amount.SetAttrib("coordinates", "time lat lon alt")
+	amount.SetAttrib("ancillary_variables", "precipitation_amount_qc accumulation_period")
+	amount.SetAttribFloat64s("_FillValue", missingValue)
+
+	amountQC := f.AddVar("precipitation_amount_qc", ncdf.Int32, "obs")
+	amountQC.SetAttrib("long_name", "quality control flag of precipitation_amount")
+	amountQC.SetAttrib("standard_name", "precipitation_amount status_flag")
+	amountQC.SetAttribInt32s("flag_values", qcMissing, qc)
+	amountQC.SetAttrib("flag_meanings", "missing good")
+
+	f.EndDef()
+	writeTimeSeries()
+
+	periods := make([]float64, len(observations))
+	amounts := make([]float64, len(observations))
+	qcs := make([]int32, len(observations))
+	for i, obs := range observations {
+		periods[i] = missingValue
+		if obs.PrecipPeriod > 0 {
+			periods[i] = obs.PrecipPeriod.Hours()
+		}
+		amounts[i] = netcdfValue(obs.Metric.PrecipTotal)
+		qcs[i] = qcFlag(obs.Metric.PrecipTotal)
+	}
+	period.WriteFloat64s(periods)
+	amount.WriteFloat64s(amounts)
+	amountQC.WriteInt32s(qcs)
+
+	if err := f.Error(); err != nil {
+		return err
+	}
+	f.Close()
+	return f.Error()
+}
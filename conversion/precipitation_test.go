@@ -0,0 +1,56 @@
+package conversion
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/internal/ncdf"
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePrecipitationCSV(t *testing.T) {
+	accumulated := testobs
+	accumulated.PrecipPeriod = 3 * time.Hour
+	unknown := testobs
+	unknown.Metric.PrecipTotal = types.NaN()
+
+	var buf strings.Builder
+	WritePrecipitationCSV(&buf, []types.Observation{accumulated, unknown})
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "station_id,network,lat,lon,elevation,start,end,precipitation", lines[0])
+	assert.Equal(t, "210329130_2,Wunderground,      41.469,      15.483,    1234.000,2020-03-30T15:01:02Z,2020-03-30T18:01:02Z,      10.000", lines[1])
+	assert.Equal(t, "210329130_2,Wunderground,      41.469,      15.483,    1234.000,,2020-03-30T18:01:02Z, -888888.000", lines[2])
+}
+
+func TestWritePrecipitationNetCDF(t *testing.T) {
+	first := testobs
+	first.PrecipPeriod = 24 * time.Hour
+	second := testobs
+	second.StationID = "ILIGURIA42"
+	second.Metric.PrecipTotal = types.NaN()
+
+	file := filepath.Join(t.TempDir(), "precip.nc")
+	assert.NoError(t, WritePrecipitationNetCDF(file, []types.Observation{first, second}))
+
+	f := ncdf.OpenFile(file)
+	defer f.Close()
+	assert.Equal(t, "timeSeries", f.Attrib("featureType"))
+	assert.Equal(t, []int32{0, 1}, f.Var("station_index").ValuesInt32())
+	assert.Equal(t, []float64{10, -888888}, f.Var("precipitation_amount").ValuesFloat64())
+	assert.Equal(t, []int32{0, -88}, f.Var("precipitation_amount_qc").ValuesInt32())
+	assert.Equal(t, []float64{24, -888888}, f.Var("accumulation_period").ValuesFloat64())
+	assert.NoError(t, f.Error())
+}
+
+func TestWritePrecipitationNetCDFEmpty(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "precip.nc")
+	assert.NoError(t, WritePrecipitationNetCDF(file, []types.Observation{}))
+
+	f := ncdf.OpenFile(file)
+	defer f.Close()
+	assert.Equal(t, []uint64{0}, f.Var("precipitation_amount").Dims())
+	assert.NoError(t, f.Error())
+}
@@ -0,0 +1,104 @@
+package elevations
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// openASCIIGrid reads a DEM from an ESRI ASCII grid
+// file, in geographic coordinates. Since the file must
+// be parsed sequentially, the grid is kept in memory.
+func openASCIIGrid(filename string) (*raster, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Split(bufio.ScanWords)
+
+	header := map[string]float64{}
+	var word string
+	for scanner.Scan() {
+		word = scanner.Text()
+		key := strings.ToLower(word)
+		if key == "" || (key[0] < 'a' || key[0] > 'z') {
+			break
+		}
+		if !scanner.Scan() {
+			break
+		}
+		header[key], err = strconv.ParseFloat(scanner.Text(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid %s: %w", filename, word, err)
+		}
+		word = ""
+	}
+	if scanner.Err() != nil {
+		return nil, fmt.Errorf("%s: %w", filename, scanner.Err())
+	}
+
+	for _, key := range []string{"ncols", "nrows", "cellsize"} {
+		if _, ok := header[key]; !ok {
+			return nil, fmt.Errorf("%s: missing %s in header", filename, key)
+		}
+	}
+
+	r := &raster{
+		width:  int(header["ncols"]),
+		height: int(header["nrows"]),
+		dx:     header["cellsize"],
+		dy:     header["cellsize"],
+		nodata: math.NaN(),
+	}
+	if nodata, ok := header["nodata_value"]; ok {
+		r.nodata = nodata
+	}
+
+	if xll, ok := header["xllcorner"]; ok {
+		r.x0 = xll
+	} else if xll, ok := header["xllcenter"]; ok {
+		r.x0 = xll - r.dx/2
+	} else {
+		return nil, fmt.Errorf("%s: missing xllcorner in header", filename)
+	}
+	if yll, ok := header["yllcorner"]; ok {
+		r.y0 = yll + float64(r.height)*r.dy
+	} else if yll, ok := header["yllcenter"]; ok {
+		r.y0 = yll - r.dy/2 + float64(r.height)*r.dy
+	} else {
+		return nil, fmt.Errorf("%s: missing yllcorner in header", filename)
+	}
+
+	z := make([]float32, 0, r.width*r.height)
+	for word != "" {
+		val, err := strconv.ParseFloat(word, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid value: %w", filename, err)
+		}
+		z = append(z, float32(val))
+		word = ""
+		if scanner.Scan() {
+			word = scanner.Text()
+		}
+	}
+	if scanner.Err() != nil {
+		return nil, fmt.Errorf("%s: %w", filename, scanner.Err())
+	}
+	if len(z) != r.width*r.height {
+		return nil, fmt.Errorf("%s: expected %d values, found %d", filename, r.width*r.height, len(z))
+	}
+
+	// ASCII grids can't be read by windows,
+	// so the whole grid is kept as a single tile.
+	r.tiles = newTileCache(r.width, r.height, r.width, r.height, 1, func(int) ([]float32, error) {
+		return z, nil
+	})
+
+	return r, nil
+}
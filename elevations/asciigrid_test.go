@@ -0,0 +1,59 @@
+package elevations
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestASCIIGrid(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dem.asc")
+	content := `ncols        4
+nrows        3
+xllcorner    8.0
+yllcorner    45.0
+cellsize     0.5
+NODATA_value -9999
+10 20 30 40
+50 60 70 80
+-9999 100 110 120.5
+`
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(content), 0644))
+
+	dem, err := Open(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, 10.0, dem.At(46.4, 8.1))
+	assert.Equal(t, 40.0, dem.At(46.4, 9.9))
+	assert.Equal(t, 70.0, dem.At(45.75, 9.25))
+	assert.Equal(t, 120.5, dem.At(45.1, 9.9))
+	assert.Equal(t, 0.0, dem.At(45.1, 8.1))
+	assert.True(t, math.IsNaN(dem.At(44.9, 8.1)))
+	assert.True(t, math.IsNaN(dem.At(45.1, 10.1)))
+}
+
+func TestASCIIGridCenter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dem.asc")
+	content := "ncols 2\nnrows 2\nxllcenter 8.25\nyllcenter 45.25\ncellsize 0.5\n1 2\n3 4\n"
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(content), 0644))
+
+	dem, err := Open(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, dem.At(45.9, 8.1))
+	assert.Equal(t, 4.0, dem.At(45.1, 8.9))
+}
+
+func TestASCIIGridErrors(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dem.asc")
+	content := "ncols 2\nnrows 2\nxllcorner 8\nyllcorner 45\ncellsize 0.5\n1 2\n3\n"
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(content), 0644))
+	_, err := Open(filename)
+	assert.EqualError(t, err, filename+": expected 4 values, found 3")
+
+	content = "ncols 2\nnrows 2\nxllcorner 8\nyllcorner 45\n1 2\n3 4\n"
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(content), 0644))
+	_, err = Open(filename)
+	assert.EqualError(t, err, filename+": missing cellsize in header")
+}
@@ -0,0 +1,154 @@
+package elevations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// coordsTolerance is the difference in degrees above
+// which coordinates of a station are considered changed.
+const coordsTolerance = 1e-6
+
+// CachedStation is the metadata of a station
+// saved in a StationCache.
+type CachedStation struct {
+	Lat       float64   `json:"lat"`
+	Lon       float64   `json:"lon"`
+	Elevation float64   `json:"elevation"`
+	DEMSource string    `json:"demSource"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// StationCache is a persistent cache of the DEM elevation
+// of stations, keyed by station ID. Cached elevations are
+// discarded when coordinates of the station, or the DEM
+// in use, change.
+type StationCache struct {
+	filename string
+	date     time.Time
+	mu       sync.Mutex
+	changed  bool
+	Stations map[string]CachedStation
+}
+
+// OpenStationCache reads the station cache saved in
+// filename, or returns an empty one if the file doesn't
+// exist. Stations looked up in the cache are marked
+// as seen at date.
+func OpenStationCache(filename string, date time.Time) (*StationCache, error) {
+	c := &StationCache{
+		filename: filename,
+		date:     date,
+		Stations: map[string]CachedStation{},
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &c.Stations); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return c, nil
+}
+
+// Elevation returns the elevation of the station with given
+// id and coordinates read from DEM demSource, calling dem
+// and caching its result when the station is not cached yet.
+func (c *StationCache) Elevation(id string, lat, lon float64, demSource string, dem func() float64) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.Stations[id]
+	if ok && cached.DEMSource == demSource &&
+		math.Abs(cached.Lat-lat) <= coordsTolerance &&
+		math.Abs(cached.Lon-lon) <= coordsTolerance {
+		if cached.LastSeen.Before(c.date) {
+			cached.LastSeen = c.date
+			c.Stations[id] = cached
+			c.changed = true
+		}
+		return cached.Elevation
+	}
+
+	elevation := dem()
+	// NaN can't be saved in JSON, and
+	// is returned only outside the DEM.
+	if math.IsNaN(elevation) {
+		return elevation
+	}
+	c.Stations[id] = CachedStation{
+		Lat:       lat,
+		Lon:       lon,
+		Elevation: elevation,
+		DEMSource: demSource,
+		LastSeen:  c.date,
+	}
+	c.changed = true
+	return elevation
+}
+
+// Save writes the cache to its file, if it changed.
+func (c *StationCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.changed {
+		return nil
+	}
+	content, err := json.MarshalIndent(c.Stations, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so
+	// that the cache is never left truncated.
+	tmp, err := ioutil.TempFile(filepath.Dir(c.filename), filepath.Base(c.filename)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.filename); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	c.changed = false
+	return nil
+}
+
+var stationCache *StationCache
+
+// UseStationCache sets the cache used by
+// StationElevation. A nil cache disables it.
+func UseStationCache(cache *StationCache) {
+	stationCache = cache
+}
+
+// StationElevation returns the DEM elevation at the
+// coordinates of the station with given id, reading it
+// from the cache set by UseStationCache when possible.
+func StationElevation(id string, lat, lon float64) float64 {
+	if stationCache == nil || id == "" {
+		return GetFromCoord(lat, lon)
+	}
+	return stationCache.Elevation(id, lat, lon, DEMSource(), func() float64 {
+		return GetFromCoord(lat, lon)
+	})
+}
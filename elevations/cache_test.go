@@ -0,0 +1,67 @@
+package elevations
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStationCache(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "stations.json")
+	day1 := time.Date(2020, 3, 30, 18, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+
+	calls := 0
+	dem := func(elevation float64) func() float64 {
+		return func() float64 {
+			calls++
+			return elevation
+		}
+	}
+
+	c, err := OpenStationCache(filename, day1)
+	assert.NoError(t, err)
+	assert.Equal(t, 234.0, c.Elevation("LIMC", 45.63, 8.723, "orog.nc", dem(234)))
+	assert.Equal(t, 234.0, c.Elevation("LIMC", 45.63, 8.723, "orog.nc", dem(0)))
+	assert.True(t, math.IsNaN(c.Elevation("SEA", 40, 0, "orog.nc", dem(math.NaN()))))
+	assert.Equal(t, 2, calls)
+	assert.NoError(t, c.Save())
+
+	c, err = OpenStationCache(filename, day2)
+	assert.NoError(t, err)
+	assert.Equal(t, day1, c.Stations["LIMC"].LastSeen)
+	assert.NotContains(t, c.Stations, "SEA")
+
+	calls = 0
+	assert.Equal(t, 234.0, c.Elevation("LIMC", 45.63, 8.723, "orog.nc", dem(0)))
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, day2, c.Stations["LIMC"].LastSeen)
+
+	// station moved
+	assert.Equal(t, 300.0, c.Elevation("LIMC", 45.64, 8.723, "orog.nc", dem(300)))
+	// another DEM
+	assert.Equal(t, 310.0, c.Elevation("LIMC", 45.64, 8.723, "tinitaly.tif", dem(310)))
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, CachedStation{
+		Lat:       45.64,
+		Lon:       8.723,
+		Elevation: 310,
+		DEMSource: "tinitaly.tif",
+		LastSeen:  day2,
+	}, c.Stations["LIMC"])
+}
+
+func TestStationElevation(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "stations.json")
+	c, err := OpenStationCache(filename, time.Now())
+	assert.NoError(t, err)
+	c.Stations["LIMC"] = CachedStation{Lat: 45.63, Lon: 8.723, Elevation: -1, DEMSource: DEMSource()}
+
+	UseStationCache(c)
+	defer UseStationCache(nil)
+	assert.Equal(t, -1.0, StationElevation("LIMC", 45.63, 8.723))
+	assert.Equal(t, GetFromCoord(45.63, 8.723), StationElevation("", 45.63, 8.723))
+}
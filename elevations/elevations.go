@@ -1,58 +1,176 @@
 // package elevations contains a single function
 // that returns elevation at specified latitude:longitude
-// according to an orografy dataset contained at
+// according to an orografy dataset, by default contained at
 // ~/.dewetra2wrf/orog.nc
 package elevations
 
 import (
+	"fmt"
+	"io"
+	"log"
 	"math"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	//"github.com/RobinRCM/sklearn/interpolate"
-	"github.com/meteocima/dewetra2wrf/elevations/internal/ncdf"
+	"github.com/meteocima/dewetra2wrf/internal/ncdf"
 )
 
 //var interp func(x float64, y float64) float64
 
-type elevationsFile struct {
-	xs, ys []float64
-	zs     []float64
+// DEM is a digital elevation model.
+type DEM interface {
+	// At returns elevation in meters at specified lat:lon,
+	// 0 where the DEM has no data (e.g. on sea), and NaN
+	// when lat:lon fall outside the DEM.
+	At(lat, lon float64) float64
+}
+
+// Open returns the DEM contained in filename. The format
+// is chosen by file extension: .nc for NetCDF files with
+// x, y, z variables, .tif or .tiff for GeoTIFF files, and
+// .asc for ESRI ASCII grid files.
+func Open(filename string) (DEM, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".nc" {
+		return openElevationsFile(filename)
+	}
+	if ext == ".tif" || ext == ".tiff" {
+		return openGeoTIFF(filename)
+	}
+	if ext == ".asc" {
+		return openASCIIGrid(filename)
+	}
+	return nil, fmt.Errorf("%s: unsupported DEM format `%s`", filename, ext)
 }
 
-var elev *elevationsFile = openElevationsFile()
+var (
+	elev     DEM
+	elevFile string
+	elevOnce sync.Once
+)
 
-func openElevationsFile() *elevationsFile {
+// UseFile sets the DEM used by GetFromCoord
+// to the one contained in filename, instead
+// of ~/.dewetra2wrf/orog.nc.
+func UseFile(filename string) error {
+	dem, err := Open(filename)
+	if err != nil {
+		return err
+	}
+	if closer, ok := elev.(io.Closer); ok {
+		closer.Close()
+	}
+	elev = dem
+	elevFile = filename
+	return nil
+}
+
+// DEMSource returns the path of
+// the DEM used by GetFromCoord.
+func DEMSource() string {
+	if elevFile != "" {
+		return elevFile
+	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		panic(err)
 	}
-	orog := path.Join(home, ".dewetra2wrf", "orog.nc")
-	f := ncdf.File{}
+	return path.Join(home, ".dewetra2wrf", "orog.nc")
+}
+
+func defaultElevationsFile() DEM {
+	e, err := openElevationsFile(DEMSource())
+	if err != nil {
+		panic(err)
+	}
+	return e
+
+}
+
+// elevationsFile is a DEM read from a NetCDF file
+// with x, y, z variables. Values of z are read by
+// hyperslabs of tileSize*tileSize pixels, when
+// first used.
+type elevationsFile struct {
+	file   *ncdf.File
+	xs, ys []float64
+	zs     *tileCache
+}
+
+func openElevationsFile(orog string) (*elevationsFile, error) {
+	f := &ncdf.File{}
 	f.Open(orog)
-	defer f.Close()
 	x := f.Var("x")
 	y := f.Var("y")
 	z := f.Var("z")
 
 	e := &elevationsFile{
-		xs: x.ValuesFloat64(),
-		ys: y.ValuesFloat64(),
-		zs: z.ValuesFloat64(),
+		file: f,
+		xs:   x.ValuesFloat64(),
+		ys:   y.ValuesFloat64(),
 	}
+	dims := z.Dims()
 
 	//interp = interpolate.Interp2d(e.xs, e.ys, e.zs)
 
 	if f.Error() != nil {
-		panic(err)
+		err := fmt.Errorf("%s: %w", orog, f.Error())
+		f.Close()
+		return nil, err
+	}
+	if len(dims) != 2 || dims[0] != uint64(len(e.ys)) || dims[1] != uint64(len(e.xs)) {
+		f.Close()
+		return nil, fmt.Errorf("%s: z size doesn't match x and y", orog)
 	}
 
-	return e
+	width, height := len(e.xs), len(e.ys)
+	e.zs = newTileCache(width, height, tileSize, tileSize, cachedTiles, func(n int) ([]float32, error) {
+		col, row := e.zs.tileOrigin(n)
+		cols, rows := tileSize, tileSize
+		if col+cols > width {
+			cols = width - col
+		}
+		if row+rows > height {
+			rows = height - row
+		}
+		slab := z.SliceFloat32([]uint64{uint64(row), uint64(col)}, []uint64{uint64(rows), uint64(cols)})
+		if f.Error() != nil {
+			return nil, fmt.Errorf("%s: %w", orog, f.Error())
+		}
+
+		values := make([]float32, tileSize*tileSize)
+		for j := 0; j < rows; j++ {
+			copy(values[j*tileSize:j*tileSize+cols], slab[j*cols:(j+1)*cols])
+		}
+		return values, nil
+	})
+
+	return e, nil
+
+}
 
+// Close releases the NetCDF file read by elev.
+func (elev *elevationsFile) Close() error {
+	elev.file.Close()
+	return elev.file.Error()
 }
 
 // GetFromCoord returns elevation at specified lat:lon
 func GetFromCoord(lat, lon float64) float64 {
+	elevOnce.Do(func() {
+		if elev == nil {
+			elev = defaultElevationsFile()
+		}
+	})
+	return elev.At(lat, lon)
+}
+
+// At implements DEM for elevationsFile
+func (elev *elevationsFile) At(lat, lon float64) float64 {
 	// coordinates for borders of our DEM file
 	minlon := elev.xs[0]
 	maxlon := elev.xs[len(elev.xs)-1]
@@ -86,8 +204,17 @@ func GetFromCoord(lat, lon float64) float64 {
 	xpos := int(math.Round(xposF))
 	ypos := int(math.Round(yposF))
 
+	if xpos < 0 || ypos < 0 || xpos >= len(elev.xs) || ypos >= len(elev.ys) {
+		return math.NaN()
+	}
+
 	//fmt.Println("xpos", xpos, "ypos", ypos)
-	val := elev.zs[xpos+ypos*len(elev.xs)]
+	z, err := elev.zs.value(xpos, ypos)
+	if err != nil {
+		log.Printf("cannot read DEM: %s", err)
+		return math.NaN()
+	}
+	val := float64(z)
 
 	// Missing values means lat:lon fall on sea
 	// so return 0 as altitude
@@ -0,0 +1,456 @@
+package elevations
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TIFF tags used to read DEMs
+const (
+	tagImageWidth      = 256
+	tagImageLength     = 257
+	tagBitsPerSample   = 258
+	tagCompression     = 259
+	tagStripOffsets    = 273
+	tagSamplesPerPixel = 277
+	tagRowsPerStrip    = 278
+	tagStripByteCounts = 279
+	tagPredictor       = 317
+	tagTileWidth       = 322
+	tagTileLength      = 323
+	tagTileOffsets     = 324
+	tagTileByteCounts  = 325
+	tagSampleFormat    = 339
+	tagModelPixelScale = 33550
+	tagModelTiepoint   = 33922
+	tagModelTransform  = 34264
+	tagGeoKeyDirectory = 34735
+	tagGDALNoData      = 42113
+)
+
+// GeoTIFF keys used to read DEMs
+const (
+	keyModelType     = 1024
+	keyRasterType    = 1025
+	keyProjectedCRS  = 3072
+	modelProjected   = 1
+	rasterPixelPoint = 2
+)
+
+// TIFF compressions
+const (
+	compressionNone    = 1
+	compressionLZW     = 5
+	compressionDeflate = 8
+	compressionZip     = 32946
+)
+
+// TIFF sample formats
+const (
+	sampleUint  = 1
+	sampleInt   = 2
+	sampleFloat = 3
+)
+
+// sizes in bytes of TIFF field types
+var fieldSizes = map[uint16]uint32{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// ifdEntry is a field of a TIFF
+// image file directory.
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+// tiffFile is the first image of a TIFF file.
+type tiffFile struct {
+	r      io.ReaderAt
+	order  binary.ByteOrder
+	fields map[uint16]ifdEntry
+}
+
+// geoTIFF reads pixels of a GeoTIFF file,
+// stored in strips or tiles.
+type geoTIFF struct {
+	*tiffFile
+	width, height   int
+	blockW, blockH  int
+	blocksAcross    int
+	offsets, counts []uint64
+	compression     int
+	predictor       int
+	bits, format    int
+	bytesPerSample  int
+}
+
+// openGeoTIFF opens a DEM from a single band GeoTIFF file,
+// either in geographic coordinates or in a UTM projection.
+// Strips or tiles of the file are read when first used.
+func openGeoTIFF(filename string) (*raster, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := readGeoTIFF(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	r.file = f
+	return r, nil
+}
+
+func readGeoTIFF(f io.ReaderAt) (*raster, error) {
+	tf, err := readTIFF(f)
+	if err != nil {
+		return nil, err
+	}
+	img, err := tf.image()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &raster{
+		width:  img.width,
+		height: img.height,
+		nodata: math.NaN(),
+	}
+	if err := tf.georeference(r); err != nil {
+		return nil, err
+	}
+	if nodata, ok := tf.fields[tagGDALNoData]; ok {
+		s := strings.Trim(string(nodata.data), "\x00 ")
+		r.nodata, err = strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GDAL_NODATA: %w", err)
+		}
+	}
+
+	r.tiles = newTileCache(img.width, img.height, img.blockW, img.blockH, cachedTiles, img.block)
+
+	return r, nil
+}
+
+func readTIFF(r io.ReaderAt) (*tiffFile, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("cannot read TIFF header: %w", err)
+	}
+
+	tf := &tiffFile{r: r, fields: map[uint16]ifdEntry{}}
+	if string(header[:2]) == "II" {
+		tf.order = binary.LittleEndian
+	} else if string(header[:2]) == "MM" {
+		tf.order = binary.BigEndian
+	} else {
+		return nil, errors.New("not a TIFF file")
+	}
+	version := tf.order.Uint16(header[2:])
+	if version == 43 {
+		return nil, errors.New("BigTIFF files are not supported")
+	}
+	if version != 42 {
+		return nil, errors.New("not a TIFF file")
+	}
+
+	ifd := int64(tf.order.Uint32(header[4:]))
+	buf := make([]byte, 2)
+	if _, err := r.ReadAt(buf, ifd); err != nil {
+		return nil, fmt.Errorf("cannot read TIFF directory: %w", err)
+	}
+	entries := make([]byte, 12*int(tf.order.Uint16(buf)))
+	if _, err := r.ReadAt(entries, ifd+2); err != nil {
+		return nil, fmt.Errorf("cannot read TIFF directory: %w", err)
+	}
+
+	for e := 0; e < len(entries); e += 12 {
+		tag := tf.order.Uint16(entries[e:])
+		entry := ifdEntry{
+			typ:   tf.order.Uint16(entries[e+2:]),
+			count: tf.order.Uint32(entries[e+4:]),
+		}
+		size, ok := fieldSizes[entry.typ]
+		if !ok {
+			continue
+		}
+		entry.data = make([]byte, size*entry.count)
+		if len(entry.data) <= 4 {
+			copy(entry.data, entries[e+8:e+12])
+		} else if _, err := r.ReadAt(entry.data, int64(tf.order.Uint32(entries[e+8:]))); err != nil {
+			return nil, fmt.Errorf("cannot read TIFF tag %d: %w", tag, err)
+		}
+		tf.fields[tag] = entry
+	}
+
+	return tf, nil
+}
+
+// uints returns the values of an integer field.
+func (tf *tiffFile) uints(tag uint16) []uint64 {
+	entry := tf.fields[tag]
+	values := make([]uint64, entry.count)
+	for i := range values {
+		if entry.typ == 1 || entry.typ == 7 {
+			values[i] = uint64(entry.data[i])
+		} else if entry.typ == 3 {
+			values[i] = uint64(tf.order.Uint16(entry.data[2*i:]))
+		} else if entry.typ == 4 {
+			values[i] = uint64(tf.order.Uint32(entry.data[4*i:]))
+		}
+	}
+	return values
+}
+
+// uint returns the first value of an integer field,
+// or def when the field is missing.
+func (tf *tiffFile) uint(tag uint16, def int) int {
+	values := tf.uints(tag)
+	if len(values) == 0 {
+		return def
+	}
+	return int(values[0])
+}
+
+// floats returns the values of a DOUBLE field.
+func (tf *tiffFile) floats(tag uint16) []float64 {
+	entry := tf.fields[tag]
+	if entry.typ != 12 {
+		return nil
+	}
+	values := make([]float64, entry.count)
+	for i := range values {
+		values[i] = math.Float64frombits(tf.order.Uint64(entry.data[8*i:]))
+	}
+	return values
+}
+
+// geoKeys returns the short values of
+// the GeoKeyDirectory field.
+func (tf *tiffFile) geoKeys() map[int]int {
+	keys := map[int]int{}
+	dir := tf.uints(tagGeoKeyDirectory)
+	for k := 4; k+3 < len(dir); k += 4 {
+		// values stored in other tags are
+		// not used, only shorts are read.
+		if dir[k+1] == 0 {
+			keys[int(dir[k])] = int(dir[k+3])
+		}
+	}
+	return keys
+}
+
+// georeference sets position, pixel size
+// and projection of r.
+func (tf *tiffFile) georeference(r *raster) error {
+	scale := tf.floats(tagModelPixelScale)
+	tiepoint := tf.floats(tagModelTiepoint)
+	transform := tf.floats(tagModelTransform)
+
+	if len(scale) >= 2 && len(tiepoint) >= 6 {
+		r.dx, r.dy = scale[0], scale[1]
+		r.x0 = tiepoint[3] - tiepoint[0]*r.dx
+		r.y0 = tiepoint[4] + tiepoint[1]*r.dy
+	} else if len(transform) >= 8 && transform[1] == 0 && transform[4] == 0 {
+		r.dx, r.dy = transform[0], -transform[5]
+		r.x0, r.y0 = transform[3], transform[7]
+	} else {
+		return errors.New("missing or unsupported georeferencing")
+	}
+
+	keys := tf.geoKeys()
+	if keys[keyRasterType] == rasterPixelPoint {
+		r.x0 -= r.dx / 2
+		r.y0 += r.dy / 2
+	}
+	if keys[keyModelType] == modelProjected {
+		project, err := utmProjection(keys[keyProjectedCRS])
+		if err != nil {
+			return err
+		}
+		r.project = project
+	}
+	return nil
+}
+
+// image returns the layout of pixels of tf.
+func (tf *tiffFile) image() (*geoTIFF, error) {
+	img := &geoTIFF{
+		tiffFile:    tf,
+		width:       tf.uint(tagImageWidth, 0),
+		height:      tf.uint(tagImageLength, 0),
+		compression: tf.uint(tagCompression, compressionNone),
+		predictor:   tf.uint(tagPredictor, 1),
+		bits:        tf.uint(tagBitsPerSample, 1),
+		format:      tf.uint(tagSampleFormat, sampleUint),
+	}
+	if img.width == 0 || img.height == 0 {
+		return nil, errors.New("missing image size")
+	}
+	if samples := tf.uint(tagSamplesPerPixel, 1); samples != 1 {
+		return nil, fmt.Errorf("%d samples per pixel are not supported", samples)
+	}
+	if img.bits != 8 && img.bits != 16 && img.bits != 32 && img.bits != 64 {
+		return nil, fmt.Errorf("%d bits per sample are not supported", img.bits)
+	}
+	if img.format == sampleFloat && img.bits < 32 {
+		return nil, fmt.Errorf("%d bits floating point samples are not supported", img.bits)
+	}
+	if img.format != sampleUint && img.format != sampleInt && img.format != sampleFloat {
+		return nil, fmt.Errorf("sample format %d is not supported", img.format)
+	}
+	if img.compression != compressionNone && img.compression != compressionLZW &&
+		img.compression != compressionDeflate && img.compression != compressionZip {
+		return nil, fmt.Errorf("compression %d is not supported", img.compression)
+	}
+	if img.predictor < 1 || img.predictor > 3 || (img.predictor == 2 && img.format == sampleFloat) {
+		return nil, fmt.Errorf("predictor %d is not supported", img.predictor)
+	}
+	img.bytesPerSample = img.bits / 8
+
+	if _, tiled := tf.fields[tagTileWidth]; tiled {
+		img.blockW = tf.uint(tagTileWidth, 0)
+		img.blockH = tf.uint(tagTileLength, 0)
+		img.offsets = tf.uints(tagTileOffsets)
+		img.counts = tf.uints(tagTileByteCounts)
+	} else {
+		img.blockW = img.width
+		img.blockH = tf.uint(tagRowsPerStrip, img.height)
+		if img.blockH > img.height {
+			img.blockH = img.height
+		}
+		img.offsets = tf.uints(tagStripOffsets)
+		img.counts = tf.uints(tagStripByteCounts)
+	}
+	if img.blockW == 0 || img.blockH == 0 {
+		return nil, errors.New("invalid tile size")
+	}
+	img.blocksAcross = (img.width + img.blockW - 1) / img.blockW
+	blocksDown := (img.height + img.blockH - 1) / img.blockH
+	if len(img.offsets) != img.blocksAcross*blocksDown || len(img.counts) != len(img.offsets) {
+		return nil, errors.New("invalid strip or tile offsets")
+	}
+
+	return img, nil
+}
+
+// block reads and decodes the n-th strip or tile
+// of img, returning blockW*blockH pixel values.
+func (img *geoTIFF) block(n int) ([]float32, error) {
+	data := make([]byte, img.counts[n])
+	if _, err := img.r.ReadAt(data, int64(img.offsets[n])); err != nil {
+		return nil, fmt.Errorf("cannot read block %d: %w", n, err)
+	}
+
+	var err error
+	if img.compression == compressionLZW {
+		data, err = decodeLZW(data)
+	} else if img.compression == compressionDeflate || img.compression == compressionZip {
+		var zr io.ReadCloser
+		zr, err = zlib.NewReader(bytes.NewReader(data))
+		if err == nil {
+			data, err = ioutil.ReadAll(zr)
+			zr.Close()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress block %d: %w", n, err)
+	}
+
+	rowSize := img.blockW * img.bytesPerSample
+	rows := len(data) / rowSize
+	if rows > img.blockH {
+		rows = img.blockH
+	}
+	values := make([]float32, img.blockW*img.blockH)
+	for i := range values {
+		values[i] = float32(math.NaN())
+	}
+
+	raw := make([]uint64, img.blockW)
+	for j := 0; j < rows; j++ {
+		row := data[j*rowSize : (j+1)*rowSize]
+		order := img.order
+		if img.predictor == 3 {
+			row = unshuffleFloats(row, img.bytesPerSample)
+			order = binary.BigEndian
+		}
+		for i := range raw {
+			raw[i] = readUint(order, row[i*img.bytesPerSample:], img.bytesPerSample)
+		}
+		if img.predictor == 2 {
+			mask := uint64(1)<<uint(img.bits) - 1
+			if img.bits == 64 {
+				mask = math.MaxUint64
+			}
+			for i := 1; i < len(raw); i++ {
+				raw[i] = (raw[i] + raw[i-1]) & mask
+			}
+		}
+		for i, u := range raw {
+			values[j*img.blockW+i] = img.sample(u)
+		}
+	}
+	return values, nil
+}
+
+// sample converts the raw bits of a
+// sample to its value.
+func (img *geoTIFF) sample(u uint64) float32 {
+	if img.format == sampleFloat {
+		if img.bits == 32 {
+			return math.Float32frombits(uint32(u))
+		}
+		return float32(math.Float64frombits(u))
+	}
+	if img.format == sampleInt {
+		shift := uint(64 - img.bits)
+		return float32(int64(u<<shift) >> shift)
+	}
+	return float32(u)
+}
+
+func readUint(order binary.ByteOrder, b []byte, size int) uint64 {
+	if size == 1 {
+		return uint64(b[0])
+	}
+	if size == 2 {
+		return uint64(order.Uint16(b))
+	}
+	if size == 4 {
+		return uint64(order.Uint32(b))
+	}
+	return order.Uint64(b)
+}
+
+// unshuffleFloats reverses the TIFF floating point
+// predictor on a row: bytes are differenced, and stored
+// with the most significant bytes of all samples first.
+func unshuffleFloats(row []byte, size int) []byte {
+	diff := make([]byte, len(row))
+	copy(diff, row)
+	for i := 1; i < len(diff); i++ {
+		diff[i] += diff[i-1]
+	}
+
+	n := len(row) / size
+	out := make([]byte, len(row))
+	for i := 0; i < n; i++ {
+		for b := 0; b < size; b++ {
+			out[i*size+b] = diff[b*n+i]
+		}
+	}
+	return out
+}
@@ -0,0 +1,382 @@
+package elevations
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeLZW compresses src as TIFF LZW
+func encodeLZW(src []byte) []byte {
+	out := []byte{}
+	var bits uint64
+	nbits, width, next := 0, 9, lzwFirst
+	emit := func(code int) {
+		bits = bits<<uint(width) | uint64(code)
+		nbits += width
+		for nbits >= 8 {
+			out = append(out, byte(bits>>uint(nbits-8)))
+			nbits -= 8
+		}
+	}
+	grow := func() {
+		next++
+		if next >= 1<<uint(width) {
+			width++
+		}
+	}
+
+	dict := map[string]int{}
+	codeOf := func(w []byte) int {
+		if len(w) == 1 {
+			return int(w[0])
+		}
+		return dict[string(w)]
+	}
+
+	emit(lzwClear)
+	w := []byte{}
+	for _, c := range src {
+		wc := append(append([]byte{}, w...), c)
+		if _, ok := dict[string(wc)]; ok || len(wc) == 1 {
+			w = wc
+			continue
+		}
+		emit(codeOf(w))
+		dict[string(wc)] = next
+		grow()
+		w = []byte{c}
+	}
+	if len(w) > 0 {
+		emit(codeOf(w))
+		grow()
+	}
+	emit(lzwEOI)
+	if nbits > 0 {
+		out = append(out, byte(bits<<uint(8-nbits)))
+	}
+	return out
+}
+
+func TestDecodeLZW(t *testing.T) {
+	src := make([]byte, 3000)
+	for i := range src {
+		src[i] = byte((i * i / 7) % 23)
+	}
+	out, err := decodeLZW(encodeLZW(src))
+	assert.NoError(t, err)
+	assert.Equal(t, src, out)
+
+	_, err = decodeLZW([]byte{0x80, 0x7f, 0xff})
+	assert.Equal(t, errInvalidLZW, err)
+}
+
+// testTIFF describes a single band
+// GeoTIFF file written by tests.
+type testTIFF struct {
+	width, height  int
+	blockW, blockH int
+	tiled          bool
+	compression    int
+	predictor      int
+	bits, format   int
+	scale          []float64
+	tiepoint       []float64
+	geoKeys        []uint16
+	nodata         string
+	values         []float64
+}
+
+func (tt testTIFF) encodeRow(row []float64) []byte {
+	size := tt.bits / 8
+	buf := make([]byte, len(row)*size)
+	order := binary.ByteOrder(binary.LittleEndian)
+	if tt.predictor == 3 {
+		order = binary.BigEndian
+	}
+	raw := make([]uint64, len(row))
+	for i, v := range row {
+		if tt.format == sampleFloat {
+			raw[i] = uint64(math.Float32bits(float32(v)))
+		} else {
+			raw[i] = uint64(int64(v)) & (1<<uint(tt.bits) - 1)
+		}
+	}
+	if tt.predictor == 2 {
+		for i := len(raw) - 1; i > 0; i-- {
+			raw[i] = (raw[i] - raw[i-1]) & (1<<uint(tt.bits) - 1)
+		}
+	}
+	for i, u := range raw {
+		if size == 2 {
+			order.PutUint16(buf[i*size:], uint16(u))
+		} else {
+			order.PutUint32(buf[i*size:], uint32(u))
+		}
+	}
+	if tt.predictor != 3 {
+		return buf
+	}
+
+	shuffled := make([]byte, len(buf))
+	for i := range row {
+		for b := 0; b < size; b++ {
+			shuffled[b*len(row)+i] = buf[i*size+b]
+		}
+	}
+	for i := len(shuffled) - 1; i > 0; i-- {
+		shuffled[i] -= shuffled[i-1]
+	}
+	return shuffled
+}
+
+func (tt testTIFF) encodeBlock(bx, by int) []byte {
+	data := []byte{}
+	for j := by; j < by+tt.blockH && (tt.tiled || j < tt.height); j++ {
+		row := make([]float64, tt.blockW)
+		for i := range row {
+			if bx+i < tt.width && j < tt.height {
+				row[i] = tt.values[j*tt.width+bx+i]
+			}
+		}
+		data = append(data, tt.encodeRow(row)...)
+	}
+
+	if tt.compression == compressionLZW {
+		return encodeLZW(data)
+	}
+	if tt.compression == compressionDeflate {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(data)
+		zw.Close()
+		return buf.Bytes()
+	}
+	return data
+}
+
+func (tt testTIFF) write(t *testing.T, filename string) {
+	le := binary.LittleEndian
+	file := []byte{'I', 'I', 42, 0, 0, 0, 0, 0}
+
+	offsets, counts := []uint32{}, []uint32{}
+	for by := 0; by < tt.height; by += tt.blockH {
+		for bx := 0; bx < tt.width; bx += tt.blockW {
+			block := tt.encodeBlock(bx, by)
+			offsets = append(offsets, uint32(len(file)))
+			counts = append(counts, uint32(len(block)))
+			file = append(file, block...)
+		}
+	}
+
+	type field struct {
+		tag, typ uint16
+		count    int
+		data     []byte
+	}
+	fields := []field{}
+	shorts := func(tag uint16, values ...uint16) {
+		data := make([]byte, 2*len(values))
+		for i, v := range values {
+			le.PutUint16(data[2*i:], v)
+		}
+		fields = append(fields, field{tag, 3, len(values), data})
+	}
+	longs := func(tag uint16, values ...uint32) {
+		data := make([]byte, 4*len(values))
+		for i, v := range values {
+			le.PutUint32(data[4*i:], v)
+		}
+		fields = append(fields, field{tag, 4, len(values), data})
+	}
+	doubles := func(tag uint16, values ...float64) {
+		data := make([]byte, 8*len(values))
+		for i, v := range values {
+			le.PutUint64(data[8*i:], math.Float64bits(v))
+		}
+		fields = append(fields, field{tag, 12, len(values), data})
+	}
+
+	longs(tagImageWidth, uint32(tt.width))
+	longs(tagImageLength, uint32(tt.height))
+	shorts(tagBitsPerSample, uint16(tt.bits))
+	shorts(tagCompression, uint16(tt.compression))
+	shorts(tagSamplesPerPixel, 1)
+	shorts(tagSampleFormat, uint16(tt.format))
+	if tt.predictor != 0 {
+		shorts(tagPredictor, uint16(tt.predictor))
+	}
+	if tt.tiled {
+		longs(tagTileWidth, uint32(tt.blockW))
+		longs(tagTileLength, uint32(tt.blockH))
+		longs(tagTileOffsets, offsets...)
+		longs(tagTileByteCounts, counts...)
+	} else {
+		longs(tagRowsPerStrip, uint32(tt.blockH))
+		longs(tagStripOffsets, offsets...)
+		longs(tagStripByteCounts, counts...)
+	}
+	doubles(tagModelPixelScale, tt.scale...)
+	doubles(tagModelTiepoint, tt.tiepoint...)
+	if len(tt.geoKeys) > 0 {
+		shorts(tagGeoKeyDirectory, tt.geoKeys...)
+	}
+	if tt.nodata != "" {
+		fields = append(fields, field{tagGDALNoData, 2, len(tt.nodata) + 1, append([]byte(tt.nodata), 0)})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].tag < fields[j].tag })
+
+	for i := range fields {
+		if len(fields[i].data) > 4 {
+			offset := len(file)
+			file = append(file, fields[i].data...)
+			fields[i].data = make([]byte, 4)
+			le.PutUint32(fields[i].data, uint32(offset))
+		}
+	}
+
+	le.PutUint32(file[4:], uint32(len(file)))
+	ifd := make([]byte, 2+12*len(fields)+4)
+	le.PutUint16(ifd, uint16(len(fields)))
+	for i, f := range fields {
+		e := ifd[2+12*i:]
+		le.PutUint16(e, f.tag)
+		le.PutUint16(e[2:], f.typ)
+		le.PutUint32(e[4:], uint32(f.count))
+		copy(e[8:12], f.data)
+	}
+	file = append(file, ifd...)
+
+	assert.NoError(t, ioutil.WriteFile(filename, file, 0644))
+}
+
+func testValues(width, height int) []float64 {
+	values := make([]float64, width*height)
+	for i := range values {
+		values[i] = float64(i*37%211) - 50
+	}
+	return values
+}
+
+func TestGeoTIFF(t *testing.T) {
+	dir := t.TempDir()
+	geographic := []uint16{1, 1, 0, 2, keyModelType, 0, 1, 2, keyRasterType, 0, 1, 1}
+
+	tiffs := map[string]testTIFF{
+		"strips.tif": {
+			width: 7, height: 5, blockW: 7, blockH: 2,
+			compression: compressionNone, predictor: 1, bits: 16, format: sampleInt,
+			scale: []float64{0.5, 0.25, 0}, tiepoint: []float64{0, 0, 0, 8, 46, 0},
+			geoKeys: geographic,
+		},
+		"lzw.tif": {
+			width: 7, height: 5, blockW: 7, blockH: 3,
+			compression: compressionLZW, predictor: 2, bits: 16, format: sampleInt,
+			scale: []float64{0.5, 0.25, 0}, tiepoint: []float64{0, 0, 0, 8, 46, 0},
+			geoKeys: geographic,
+		},
+		"tiles.tiff": {
+			width: 7, height: 5, blockW: 4, blockH: 4, tiled: true,
+			compression: compressionDeflate, predictor: 3, bits: 32, format: sampleFloat,
+			scale: []float64{0.5, 0.25, 0}, tiepoint: []float64{1, 1, 0, 8.5, 45.75, 0},
+			geoKeys: geographic,
+		},
+	}
+
+	for name, tt := range tiffs {
+		tt.values = testValues(tt.width, tt.height)
+		filename := filepath.Join(dir, name)
+		tt.write(t, filename)
+
+		dem, err := Open(filename)
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		for j := 0; j < tt.height; j++ {
+			for i := 0; i < tt.width; i++ {
+				lat := 46 - (float64(j)+0.5)*0.25
+				lon := 8 + (float64(i)+0.5)*0.5
+				assert.Equal(t, tt.values[j*tt.width+i], dem.At(lat, lon), "%s at %d,%d", name, i, j)
+			}
+		}
+		assert.True(t, math.IsNaN(dem.At(46.1, 9)), name)
+		assert.True(t, math.IsNaN(dem.At(45, 11.6)), name)
+	}
+}
+
+func TestGeoTIFFNoDataAndUTM(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "utm.tif")
+	tt := testTIFF{
+		width: 10, height: 10, blockW: 10, blockH: 10,
+		compression: compressionNone, predictor: 1, bits: 16, format: sampleInt,
+		scale:    []float64{10, 10, 0},
+		tiepoint: []float64{0, 0, 0, 499950, 4983000, 0},
+		geoKeys:  []uint16{1, 1, 0, 2, keyModelType, 0, 1, 1, keyProjectedCRS, 0, 1, 32632},
+		nodata:   "-9999",
+		values:   testValues(10, 10),
+	}
+	// lat 45, lon 9 is at 500000, 4982950.4
+	tt.values[4*10+5] = 1234
+	tt.write(t, filename)
+	dem, err := Open(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, 1234.0, dem.At(45, 9))
+	assert.True(t, math.IsNaN(dem.At(45, 8)))
+
+	tt.values[4*10+5] = -9999
+	tt.write(t, filename)
+	dem, err = Open(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, dem.At(45, 9))
+}
+
+func TestGeoTIFFErrors(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "bad.tif")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("II+\x00\x10\x00\x00\x00"), 0644))
+	_, err := Open(filename)
+	assert.EqualError(t, err, filename+": BigTIFF files are not supported")
+
+	tt := testTIFF{
+		width: 2, height: 2, blockW: 2, blockH: 2,
+		compression: 7, predictor: 1, bits: 16, format: sampleInt,
+		scale: []float64{1, 1, 0}, tiepoint: []float64{0, 0, 0, 8, 46, 0},
+		values: testValues(2, 2),
+	}
+	tt.write(t, filename)
+	_, err = Open(filename)
+	assert.EqualError(t, err, filename+": compression 7 is not supported")
+
+	tt.compression = compressionNone
+	tt.geoKeys = []uint16{1, 1, 0, 2, keyModelType, 0, 1, 1, keyProjectedCRS, 0, 1, 3003}
+	tt.write(t, filename)
+	_, err = Open(filename)
+	assert.EqualError(t, err, filename+": unsupported projected CRS EPSG:3003")
+
+	_, err = Open(filepath.Join(dir, "dem.hgt"))
+	assert.EqualError(t, err, filepath.Join(dir, "dem.hgt")+": unsupported DEM format `.hgt`")
+}
+
+func TestTransverseMercator(t *testing.T) {
+	x, y := transverseMercator(45, 9, 9, 0.9996)
+	assert.InDelta(t, 0, x, 1e-6)
+	assert.InDelta(t, 4982950.40, y, 0.01)
+
+	project, err := utmProjection(32632)
+	assert.NoError(t, err)
+	x, y = project(0, 6)
+	assert.InDelta(t, 166021.44, x, 0.01)
+	assert.InDelta(t, 0, y, 1e-6)
+
+	project, err = utmProjection(32733)
+	assert.NoError(t, err)
+	_, y = project(-0.000001, 15)
+	assert.InDelta(t, 10000000, y, 1)
+}
@@ -0,0 +1,67 @@
+package elevations
+
+import "errors"
+
+const (
+	lzwClear = 256
+	lzwEOI   = 257
+	lzwFirst = 258
+	lzwMax   = 4096
+)
+
+var errInvalidLZW = errors.New("invalid LZW code")
+
+// decodeLZW decompresses src as compressed by TIFF LZW,
+// which differs from compress/lzw since codes widen one
+// code earlier.
+func decodeLZW(src []byte) ([]byte, error) {
+	out := []byte{}
+	table := make([][]byte, lzwMax)
+	for i := 0; i < 256; i++ {
+		table[i] = []byte{byte(i)}
+	}
+
+	width, next := 9, lzwFirst
+	var prev []byte
+	var bits uint32
+	nbits, pos := 0, 0
+	for {
+		for nbits < width {
+			if pos >= len(src) {
+				return out, nil
+			}
+			bits = bits<<8 | uint32(src[pos])
+			pos++
+			nbits += 8
+		}
+		code := int(bits>>uint(nbits-width)) & (1<<uint(width) - 1)
+		nbits -= width
+
+		if code == lzwClear {
+			width, next, prev = 9, lzwFirst, nil
+			continue
+		}
+		if code == lzwEOI {
+			return out, nil
+		}
+
+		var entry []byte
+		if code < next && table[code] != nil {
+			entry = table[code]
+		} else if code == next && prev != nil {
+			entry = append(prev[:len(prev):len(prev)], prev[0])
+		} else {
+			return nil, errInvalidLZW
+		}
+		out = append(out, entry...)
+
+		if prev != nil && next < lzwMax {
+			table[next] = append(prev[:len(prev):len(prev)], entry[0])
+			next++
+		}
+		prev = entry
+		if next+1 >= 1<<uint(width) && width < 12 {
+			width++
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package elevations
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+)
+
+// raster is a DEM stored as a regular grid of
+// width*height pixels, in rows going from north
+// to south.
+type raster struct {
+	width, height int
+	// x0, y0 are the coordinates of the
+	// north-west corner of the first pixel,
+	// dx, dy the size of pixels.
+	x0, y0 float64
+	dx, dy float64
+	// project translates lat, lon into
+	// the coordinates of the grid. It's nil
+	// for grids in geographic coordinates.
+	project func(lat, lon float64) (float64, float64)
+	nodata  float64
+	tiles   *tileCache
+	// file, when not nil, is closed
+	// by Close.
+	file io.Closer
+}
+
+// Close releases the file read by r.
+func (r *raster) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// At implements DEM for raster, returning
+// the value of the nearest pixel.
+func (r *raster) At(lat, lon float64) float64 {
+	x, y := lon, lat
+	if r.project != nil {
+		x, y = r.project(lat, lon)
+	}
+	col := int(math.Floor((x - r.x0) / r.dx))
+	row := int(math.Floor((r.y0 - y) / r.dy))
+	if col < 0 || row < 0 || col >= r.width || row >= r.height {
+		return math.NaN()
+	}
+
+	z, err := r.tiles.value(col, row)
+	if err != nil {
+		log.Printf("cannot read DEM: %s", err)
+		return math.NaN()
+	}
+	if math.IsNaN(float64(z)) || z == float32(r.nodata) {
+		return 0
+	}
+	return float64(z)
+}
+
+// WGS84 ellipsoid
+const (
+	wgs84A = 6378137.0
+	wgs84F = 1 / 298.257223563
+)
+
+// utmProjection returns the projection of given UTM zone,
+// as used by EPSG codes 326zz (WGS84 UTM north),
+// 327zz (WGS84 UTM south) and 258zz (ETRS89 UTM).
+func utmProjection(epsg int) (func(lat, lon float64) (float64, float64), error) {
+	zone, falseNorthing := 0, 0.0
+	if epsg > 32600 && epsg <= 32660 {
+		zone = epsg - 32600
+	} else if epsg > 32700 && epsg <= 32760 {
+		zone = epsg - 32700
+		falseNorthing = 10000000
+	} else if epsg >= 25828 && epsg <= 25838 {
+		zone = epsg - 25800
+	} else {
+		return nil, fmt.Errorf("unsupported projected CRS EPSG:%d", epsg)
+	}
+
+	lon0 := float64(zone)*6 - 183
+	return func(lat, lon float64) (float64, float64) {
+		x, y := transverseMercator(lat, lon, lon0, 0.9996)
+		return x + 500000, y + falseNorthing
+	}, nil
+}
+
+// transverseMercator projects lat, lon on the WGS84 ellipsoid
+// using the series expansion in Snyder, "Map projections:
+// a working manual", p. 61.
+func transverseMercator(lat, lon, lon0, k0 float64) (float64, float64) {
+	e2 := wgs84F * (2 - wgs84F)
+	e4, e6 := e2*e2, e2*e2*e2
+	ep2 := e2 / (1 - e2)
+
+	phi := lat * math.Pi / 180
+	sin, cos, tan := math.Sin(phi), math.Cos(phi), math.Tan(phi)
+	n := wgs84A / math.Sqrt(1-e2*sin*sin)
+	t := tan * tan
+	c := ep2 * cos * cos
+	a := cos * (lon - lon0) * math.Pi / 180
+
+	m := wgs84A * ((1-e2/4-3*e4/64-5*e6/256)*phi -
+		(3*e2/8+3*e4/32+45*e6/1024)*math.Sin(2*phi) +
+		(15*e4/256+45*e6/1024)*math.Sin(4*phi) -
+		(35*e6/3072)*math.Sin(6*phi))
+
+	x := k0 * n * (a +
+		(1-t+c)*math.Pow(a, 3)/6 +
+		(5-18*t+t*t+72*c-58*ep2)*math.Pow(a, 5)/120)
+	y := k0 * (m + n*tan*(a*a/2+
+		(5-t+9*c+4*c*c)*math.Pow(a, 4)/24+
+		(61-58*t+t*t+600*c-330*ep2)*math.Pow(a, 6)/720))
+	return x, y
+}
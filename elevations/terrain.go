@@ -0,0 +1,71 @@
+package elevations
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/meteocima/dewetra2wrf/internal/ncdf"
+	"github.com/meteocima/dewetra2wrf/wrfdomain"
+)
+
+// Terrain is the terrain height of a WRF
+// domain, as read from HGT_M variable
+// of a geo_em file.
+type Terrain struct {
+	grid *wrfdomain.Grid
+	hgt  []float64
+}
+
+// OpenTerrain reads the model terrain
+// height from a geo_em file.
+func OpenTerrain(geoEm string) (*Terrain, error) {
+	grid, err := wrfdomain.Open(geoEm, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	f := ncdf.OpenFile(geoEm)
+	defer f.Close()
+	hgt := f.Var("HGT_M").ValuesFloat32()
+	if f.Error() != nil {
+		return nil, fmt.Errorf("%s: %w", geoEm, f.Error())
+	}
+	if len(hgt) < grid.Nx*grid.Ny {
+		return nil, fmt.Errorf("%s: HGT_M size doesn't match the grid", geoEm)
+	}
+
+	t := &Terrain{grid: grid, hgt: make([]float64, grid.Nx*grid.Ny)}
+	for i := range t.hgt {
+		t.hgt[i] = float64(hgt[i])
+	}
+	return t, nil
+}
+
+// NewTerrain returns a Terrain on grid, with
+// heights given in the same order of its points.
+func NewTerrain(grid *wrfdomain.Grid, hgt []float64) *Terrain {
+	return &Terrain{grid: grid, hgt: hgt}
+}
+
+// At returns the model terrain height at lat, lon,
+// bilinearly interpolated between the four surrounding
+// mass points, or NaN if the point is outside the domain.
+func (t *Terrain) At(lat, lon float64) float64 {
+	i, j := t.grid.IJ(lat, lon)
+	maxI, maxJ := float64(t.grid.Nx-1), float64(t.grid.Ny-1)
+	if i < 0 || j < 0 || i > maxI || j > maxJ {
+		return math.NaN()
+	}
+
+	i0 := math.Min(math.Floor(i), maxI-1)
+	j0 := math.Min(math.Floor(j), maxJ-1)
+	di, dj := i-i0, j-j0
+
+	at := func(i, j float64) float64 {
+		return t.hgt[int(j)*t.grid.Nx+int(i)]
+	}
+	return at(i0, j0)*(1-di)*(1-dj) +
+		at(i0+1, j0)*di*(1-dj) +
+		at(i0, j0+1)*(1-di)*dj +
+		at(i0+1, j0+1)*di*dj
+}
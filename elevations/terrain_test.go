@@ -0,0 +1,25 @@
+package elevations
+
+import (
+	"math"
+	"testing"
+
+	"github.com/meteocima/dewetra2wrf/wrfdomain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerrainAt(t *testing.T) {
+	// a regular lat-lon grid of 3x2 points
+	// from 44N 8E to 45N 10E.
+	proj := wrfdomain.Projection{MapProj: wrfdomain.LatLon, PoleLat: 90, PoleLon: 0, StandLon: 180}
+	lats := []float64{44, 44, 44, 45, 45, 45}
+	lons := []float64{8, 9, 10, 8, 9, 10}
+	grid, err := wrfdomain.NewGrid(proj, lats, lons, 3, 2, 0)
+	assert.NoError(t, err)
+
+	terrain := NewTerrain(grid, []float64{100, 200, 300, 1100, 1200, 1300})
+	assert.InDelta(t, 100, terrain.At(44, 8), 1e-6)
+	assert.InDelta(t, 1300, terrain.At(45, 10), 1e-6)
+	assert.InDelta(t, 750, terrain.At(44.5, 9.5), 1e-6)
+	assert.True(t, math.IsNaN(terrain.At(46, 9)))
+}
@@ -0,0 +1,88 @@
+package elevations
+
+import (
+	"container/list"
+	"sync"
+)
+
+const (
+	// tileSize is the size in pixels of tiles
+	// read from DEMs that aren't tiled on disk.
+	tileSize = 256
+	// cachedTiles is the number of tiles kept
+	// in memory by each DEM.
+	cachedTiles = 64
+)
+
+// tileCache reads values of a grid of width*height
+// pixels split in tiles of tileW*tileH pixels,
+// keeping the most recently used tiles in memory.
+type tileCache struct {
+	width, height int
+	tileW, tileH  int
+	across        int
+	// read returns the pixels of the n-th tile,
+	// counting tiles by rows from the north-west
+	// corner of the grid.
+	read func(n int) ([]float32, error)
+
+	mu    sync.Mutex
+	size  int
+	tiles map[int]*list.Element
+	lru   *list.List
+}
+
+type cachedTile struct {
+	n      int
+	values []float32
+}
+
+func newTileCache(width, height, tileW, tileH, size int, read func(n int) ([]float32, error)) *tileCache {
+	return &tileCache{
+		width:  width,
+		height: height,
+		tileW:  tileW,
+		tileH:  tileH,
+		across: (width + tileW - 1) / tileW,
+		read:   read,
+		size:   size,
+		tiles:  map[int]*list.Element{},
+		lru:    list.New(),
+	}
+}
+
+// tileOrigin returns column and row of the
+// north-west pixel of the n-th tile.
+func (c *tileCache) tileOrigin(n int) (int, int) {
+	return (n % c.across) * c.tileW, (n / c.across) * c.tileH
+}
+
+// value returns the pixel at col, row.
+func (c *tileCache) value(col, row int) (float32, error) {
+	values, err := c.tile((row/c.tileH)*c.across + col/c.tileW)
+	if err != nil {
+		return 0, err
+	}
+	return values[(row%c.tileH)*c.tileW+col%c.tileW], nil
+}
+
+func (c *tileCache) tile(n int) ([]float32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.tiles[n]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*cachedTile).values, nil
+	}
+
+	values, err := c.read(n)
+	if err != nil {
+		return nil, err
+	}
+	c.tiles[n] = c.lru.PushFront(&cachedTile{n, values})
+	if c.lru.Len() > c.size {
+		last := c.lru.Remove(c.lru.Back()).(*cachedTile)
+		delete(c.tiles, last.n)
+	}
+	return values, nil
+}
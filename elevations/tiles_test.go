@@ -0,0 +1,105 @@
+package elevations
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/meteocima/dewetra2wrf/internal/ncdf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTileCache(t *testing.T) {
+	reads := []int{}
+	c := newTileCache(10, 7, 4, 3, 2, func(n int) ([]float32, error) {
+		reads = append(reads, n)
+		if n == 8 {
+			return nil, errors.New("broken tile")
+		}
+		values := make([]float32, 12)
+		for i := range values {
+			values[i] = float32(n*100 + i)
+		}
+		return values, nil
+	})
+
+	v, err := c.value(5, 4)
+	assert.NoError(t, err)
+	// tile 4 is second column, second row
+	assert.Equal(t, float32(4*100+1*4+1), v)
+	_, err = c.value(0, 0)
+	assert.NoError(t, err)
+	_, err = c.value(6, 5)
+	assert.NoError(t, err)
+	// tile 4 is still cached, tile 0 is evicted
+	// when tile 2 is read.
+	_, err = c.value(9, 0)
+	assert.NoError(t, err)
+	_, err = c.value(1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{4, 0, 2, 0}, reads)
+
+	_, err = c.value(9, 6)
+	assert.EqualError(t, err, "broken tile")
+
+	col, row := c.tileOrigin(5)
+	assert.Equal(t, 8, col)
+	assert.Equal(t, 3, row)
+}
+
+func TestElevationsFileTiles(t *testing.T) {
+	const nx, ny = 300, 4
+	xs := make([]float64, nx)
+	for i := range xs {
+		xs[i] = float64(i) * 0.01
+	}
+	ys := []float64{45.03, 45.02, 45.01, 45}
+	zs := make([]float64, nx*ny)
+	for i := range zs {
+		zs[i] = float64(i)
+	}
+	zs[3*nx+299] = -9999
+
+	filename := filepath.Join(t.TempDir(), "orog.nc")
+	f := ncdf.CreateFile(filename)
+	f.AddDim("x", nx)
+	f.AddDim("y", ny)
+	x := f.AddVar("x", ncdf.Float64, "x")
+	y := f.AddVar("y", ncdf.Float64, "y")
+	z := f.AddVar("z", ncdf.Float64, "y", "x")
+	f.EndDef()
+	x.WriteFloat64s(xs)
+	y.WriteFloat64s(ys)
+	z.WriteFloat64s(zs)
+	f.Close()
+	assert.NoError(t, f.Error())
+
+	dem, err := Open(filename)
+	assert.NoError(t, err)
+	e := dem.(*elevationsFile)
+	val, err := e.zs.value(1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(1), val)
+	val, err = e.zs.value(270, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(2*nx+270), val)
+	assert.Equal(t, 0.0, dem.At(45.0075, 2.98))
+	assert.Equal(t, float64(nx+5), dem.At(45.0225, 0.05))
+	assert.NoError(t, e.Close())
+}
+
+func TestElevationsFileErrors(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "orog.nc")
+	f := ncdf.CreateFile(filename)
+	f.AddDim("x", 2)
+	x := f.AddVar("x", ncdf.Float64, "x")
+	f.EndDef()
+	x.WriteFloat64s([]float64{0, 1})
+	f.Close()
+	assert.NoError(t, f.Error())
+
+	// y and z variables are missing
+	_, err := Open(filename)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), filename)
+}
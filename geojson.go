@@ -0,0 +1,72 @@
+package dewetra2wrf
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/meteocima/dewetra2wrf/conversion"
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// worldDomain is used to read observations
+// regardless of their position.
+var worldDomain = types.Domain{MinLat: -90, MaxLat: 90, MinLon: -180, MaxLon: 180}
+
+// splitByDomain returns observations within domain
+// and, separately, the ones outside it.
+func splitByDomain(observations []types.Observation, domain types.Region) (inside, outside []types.Observation) {
+	inside = []types.Observation{}
+	outside = []types.Observation{}
+	for _, obs := range observations {
+		if domain.Contains(obs.Lat, obs.Lon) {
+			inside = append(inside, obs)
+		} else {
+			outside = append(outside, obs)
+		}
+	}
+	return inside, outside
+}
+
+// stationsStatus returns the status of stations: included ones
+// are given in output, discarded ones in outsideDomain, rejectedQC
+// and rejectedElevation. Each station is returned once, with
+// the first status found for it.
+func stationsStatus(output, outsideDomain, rejectedQC, rejectedElevation []types.Observation) []conversion.StationStatus {
+	statuses := []conversion.StationStatus{}
+	seen := map[string]bool{}
+
+	add := func(observations []types.Observation, filter conversion.Filter) {
+		for _, obs := range observations {
+			key := fmt.Sprintf("%s:%05f:%05f", obs.StationID, obs.Lat, obs.Lon)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			statuses = append(statuses, conversion.StationStatus{Observation: obs, Filter: filter})
+		}
+	}
+
+	add(output, conversion.NotFiltered)
+	add(rejectedQC, conversion.FilteredByQC)
+	add(rejectedElevation, conversion.FilteredByElevation)
+	add(outsideDomain, conversion.FilteredByDomain)
+
+	return statuses
+}
+
+// writeGeoJSON saves to file a GeoJSON map of
+// stations, classified as in stationsStatus.
+func writeGeoJSON(file string, output, outsideDomain, rejectedQC, rejectedElevation []types.Observation) error {
+	statuses := stationsStatus(output, outsideDomain, rejectedQC, rejectedElevation)
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := conversion.WriteGeoJSON(f, statuses); err != nil {
+		return err
+	}
+	return f.Close()
+}
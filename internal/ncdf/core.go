@@ -1,5 +1,6 @@
 // package ncdf implements a netcdf file readers
-// that can cache variables.
+// that can cache variables, and a writer
+// of netcdf files.
 package ncdf
 
 import (
@@ -22,6 +23,7 @@ type File struct {
 	ds    *netcdf.Dataset
 	err   error
 	vars  Vars
+	dims  map[string]netcdf.Dim
 }
 
 // Variable ...
@@ -53,17 +55,21 @@ func (data *File) IsOpen() bool {
 	return data.ds != nil
 }
 
-// Close ...
+// Close releases the file, even when an error
+// occurred reading it: that error is kept.
 func (data *File) Close() {
-	if data.err != nil {
-		return
-	}
 	if data.ds == nil {
+		if data.err != nil {
+			return
+		}
 		panic("File closed")
 	}
 
-	data.err = data.ds.Close()
+	err := data.ds.Close()
 	data.ds = nil
+	if data.err == nil {
+		data.err = err
+	}
 }
 
 // AllVars ...
@@ -165,6 +171,50 @@ func (data *File) Attrib(name string) string {
 	return data.Attrs[name]
 }
 
+// AttribFloat64 returns the first value of a numeric
+// global attribute, converted to float64.
+// Attributes of types other than double, float
+// or int are reported as errors.
+func (data *File) AttribFloat64(name string) float64 {
+	if data.err != nil {
+		return math.NaN()
+	}
+	if data.ds == nil {
+		data.err = fmt.Errorf("no file opened")
+		return math.NaN()
+	}
+
+	attr := data.ds.Attr(name)
+	t, err := attr.Type()
+	if err != nil {
+		data.err = fmt.Errorf("attribute %s: %w", name, err)
+		return math.NaN()
+	}
+	l, err := attr.Len()
+	if err != nil || l == 0 {
+		data.err = fmt.Errorf("attribute %s: empty value", name)
+		return math.NaN()
+	}
+
+	switch t {
+	case netcdf.DOUBLE:
+		values := make([]float64, l)
+		data.err = attr.ReadFloat64s(values)
+		return values[0]
+	case netcdf.FLOAT:
+		values := make([]float32, l)
+		data.err = attr.ReadFloat32s(values)
+		return float64(values[0])
+	case netcdf.INT:
+		values := make([]int32, l)
+		data.err = attr.ReadInt32s(values)
+		return float64(values[0])
+	}
+
+	data.err = fmt.Errorf("attribute %s: not a numeric value", name)
+	return math.NaN()
+}
+
 // AllAttribs ...
 func (data *File) AllAttribs() map[string]string {
 	if data.err != nil {
@@ -355,6 +405,28 @@ func (v *Variable) ValuesFloat32() []float32 {
 	return varval
 }
 
+// SliceFloat32 reads the hyperslab of the variable
+// starting at start and with count values along
+// each dimension, without caching it.
+func (v *Variable) SliceFloat32(start, count []uint64) []float32 {
+	if v.file.err != nil {
+		return []float32{}
+	}
+	n := uint64(1)
+	for _, c := range count {
+		n *= c
+	}
+	varval := make([]float32, n)
+
+	err := v.variable.ReadFloat32Slice(varval, start, count)
+	if err != nil {
+		v.file.err = err
+		return nil
+	}
+
+	return varval
+}
+
 // Dims ...
 func (v *Variable) Dims() (dims []uint64) {
 	dims = []uint64{}
@@ -0,0 +1,227 @@
+package ncdf
+
+import (
+	"fmt"
+
+	"github.com/fhs/go-netcdf/netcdf"
+)
+
+// Type is the type of values of a variable
+type Type = netcdf.Type
+
+// Type values supported by AddVar
+const (
+	Char    Type = netcdf.CHAR
+	Int32   Type = netcdf.INT
+	Float32 Type = netcdf.FLOAT
+	Float64 Type = netcdf.DOUBLE
+)
+
+// CreateFile ...
+func CreateFile(filename string) *File {
+	f := &File{}
+	f.Create(filename)
+	return f
+}
+
+// Create creates a new file, replacing
+// existing one if any, and put it in
+// define mode.
+func (data *File) Create(filename string) {
+	if data.err != nil {
+		return
+	}
+	if data.ds != nil {
+		data.err = fmt.Errorf("file already open")
+		return
+	}
+
+	ds, err := netcdf.CreateFile(filename, netcdf.CLOBBER|netcdf.NETCDF4)
+	data.ds, data.err = &ds, err
+	if err != nil {
+		data.ds = nil
+		return
+	}
+	data.Attrs = Attrs{}
+	data.vars = Vars{}
+	data.dims = map[string]netcdf.Dim{}
+}
+
+// AddDim ...
+func (data *File) AddDim(name string, len uint64) {
+	if data.err != nil {
+		return
+	}
+	if data.ds == nil {
+		data.err = fmt.Errorf("no file opened")
+		return
+	}
+	data.dims[name], data.err = data.ds.AddDim(name, len)
+}
+
+// AddVar ...
+func (data *File) AddVar(name string, t Type, dims ...string) *Variable {
+	if data.err != nil {
+		return &Variable{file: data}
+	}
+	if data.ds == nil {
+		data.err = fmt.Errorf("no file opened")
+		return &Variable{file: data}
+	}
+
+	res := Variable{
+		file:  data,
+		Name:  name,
+		Type:  t.String(),
+		Len:   1,
+		Attrs: Attrs{},
+	}
+
+	ncdims := make([]netcdf.Dim, len(dims))
+	for i, dimName := range dims {
+		dim, ok := data.dims[dimName]
+		if !ok {
+			data.err = fmt.Errorf("unknown dimension %s", dimName)
+			return &Variable{file: data}
+		}
+		var dimLen uint64
+		dimLen, data.err = dim.Len()
+		if data.err != nil {
+			return &Variable{file: data}
+		}
+		res.Len *= dimLen
+		ncdims[i] = dim
+	}
+
+	res.variable, data.err = data.ds.AddVar(name, t, ncdims)
+	if data.err != nil {
+		return &Variable{file: data}
+	}
+	data.vars[name] = &res
+	return &res
+}
+
+// SetAttrib ...
+func (data *File) SetAttrib(name, value string) {
+	if data.err != nil {
+		return
+	}
+	if data.ds == nil {
+		data.err = fmt.Errorf("no file opened")
+		return
+	}
+	data.err = data.ds.Attr(name).WriteBytes([]byte(value))
+	data.Attrs[name] = value
+}
+
+// SetAttribFloat32s ...
+func (data *File) SetAttribFloat32s(name string, values ...float32) {
+	if data.err != nil {
+		return
+	}
+	if data.ds == nil {
+		data.err = fmt.Errorf("no file opened")
+		return
+	}
+	data.err = data.ds.Attr(name).WriteFloat32s(values)
+}
+
+// SetAttribInt32s ...
+func (data *File) SetAttribInt32s(name string, values ...int32) {
+	if data.err != nil {
+		return
+	}
+	if data.ds == nil {
+		data.err = fmt.Errorf("no file opened")
+		return
+	}
+	data.err = data.ds.Attr(name).WriteInt32s(values)
+}
+
+// EndDef leaves define mode, so
+// that values of variables can be written.
+func (data *File) EndDef() {
+	if data.err != nil {
+		return
+	}
+	if data.ds == nil {
+		data.err = fmt.Errorf("no file opened")
+		return
+	}
+	data.err = data.ds.EndDef()
+}
+
+// SetAttrib ...
+func (v *Variable) SetAttrib(name, value string) {
+	if v.file.err != nil {
+		return
+	}
+	v.file.err = v.variable.Attr(name).WriteBytes([]byte(value))
+	v.Attrs[name] = value
+}
+
+// SetAttribFloat64s ...
+func (v *Variable) SetAttribFloat64s(name string, values ...float64) {
+	if v.file.err != nil {
+		return
+	}
+	v.file.err = v.variable.Attr(name).WriteFloat64s(values)
+}
+
+// SetAttribInt32s ...
+func (v *Variable) SetAttribInt32s(name string, values ...int32) {
+	if v.file.err != nil {
+		return
+	}
+	v.file.err = v.variable.Attr(name).WriteInt32s(values)
+}
+
+// WriteFloat64s ...
+// Like the other Write methods, it writes
+// nothing when values is empty, since
+// netcdf needs the address of the first one.
+func (v *Variable) WriteFloat64s(values []float64) {
+	if v.file.err != nil || len(values) == 0 {
+		return
+	}
+	v.file.err = v.variable.WriteFloat64s(values)
+}
+
+// WriteFloat32s ...
+func (v *Variable) WriteFloat32s(values []float32) {
+	if v.file.err != nil || len(values) == 0 {
+		return
+	}
+	v.file.err = v.variable.WriteFloat32s(values)
+}
+
+// WriteInt32s ...
+func (v *Variable) WriteInt32s(values []int32) {
+	if v.file.err != nil || len(values) == 0 {
+		return
+	}
+	v.file.err = v.variable.WriteInt32s(values)
+}
+
+// WriteStrings writes values into a bidimensional
+// Char variable, padding each of them with zeroes
+// up to the length of the last dimension.
+func (v *Variable) WriteStrings(values []string) {
+	if v.file.err != nil || len(values) == 0 {
+		return
+	}
+	dims := v.Dims()
+	if v.file.err != nil {
+		return
+	}
+	if len(dims) != 2 {
+		v.file.err = fmt.Errorf("%s: strings need a bidimensional variable", v.Name)
+		return
+	}
+	strlen := int(dims[1])
+	buf := make([]byte, len(values)*strlen)
+	for i, s := range values {
+		copy(buf[i*strlen:(i+1)*strlen], s)
+	}
+	v.file.err = v.variable.WriteBytes(buf)
+}
@@ -8,15 +8,19 @@
 package dewetra2wrf
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/meteocima/dewetra2wrf/conversion"
+	"github.com/meteocima/dewetra2wrf/elevations"
 	"github.com/meteocima/dewetra2wrf/obsreader"
 	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/meteocima/dewetra2wrf/wrfdomain"
 )
 
 // InputFormat is an enum that
@@ -32,29 +36,229 @@ const (
 	DewetraFormat InputFormat = iota
 	WundergroundFormat
 	WunderHistFormat
+	MetarFormat
+	SynopFormat
+	BufrFormat
+	CSVFormat
+	NetatmoFormat
+	WRFASCIIFormat
+	LittleRFormat
+	WyomingFormat
 )
 
+// Options contains optional settings
+// used by ConvertWithOptions.
+type Options struct {
+	// StationsFile is the path of a CSV stations table
+	// used by formats that don't contain stations
+	// coordinates (e.g. MetarFormat and SynopFormat).
+	StationsFile string
+	// BufrTablesDir is the directory containing
+	// BUFR table B and table D CSV files, used
+	// by BufrFormat.
+	BufrTablesDir string
+	// CSVMappingFile is the path of the JSON file
+	// describing the layout of files read by CSVFormat.
+	// When empty, files are expected to follow
+	// the layout of conversion.WriteCSVObservation.
+	CSVMappingFile string
+	// OutputFormat is the format of
+	// the converted file.
+	OutputFormat OutputFormat
+	// GeoJSONFile, when not empty, is the path where
+	// a GeoJSON map of stations is saved, telling
+	// which ones were included in the converted file.
+	GeoJSONFile string
+	// Buffer is a distance in kilometers: when greater
+	// than zero, stations within that distance from the
+	// border of the domain are included too.
+	Buffer float64
+	// DomainMargin is the number of grid cells along
+	// each border of a WRF domain whose stations are
+	// excluded, when the domain is read from a geo_em
+	// or wrfinput file.
+	DomainMargin float64
+	// TerrainFile, when not empty, is the path of a geo_em
+	// file whose model terrain height (HGT_M) is compared
+	// with elevation of surface stations.
+	TerrainFile string
+	// MaxElevationGap is the maximum difference in meters
+	// between station elevation and model terrain height.
+	// Stations above it are handled according to
+	// ElevationGapPolicy. When not greater than zero,
+	// the gap is only recorded.
+	MaxElevationGap float64
+	// ElevationGapPolicy tells how stations above
+	// MaxElevationGap are handled.
+	ElevationGapPolicy ElevationGapPolicy
+	// ElevationSource tells whether station elevation is
+	// the one reported by input files, the one read from
+	// the DEM, or the reported one with DEM as fallback.
+	ElevationSource obsreader.ElevationSource
+	// DEMFile, when not empty, is the path of the DEM
+	// used to read stations elevation, instead of
+	// ~/.dewetra2wrf/orog.nc. It could be a NetCDF,
+	// GeoTIFF or ESRI ASCII grid file.
+	DEMFile string
+	// StationCacheFile, when not empty, is the path of a
+	// JSON file where DEM elevation of stations is cached
+	// between runs, so that the DEM is read only for new
+	// stations or when coordinates change.
+	StationCacheFile string
+	// PrecipPeriod, when greater than zero, is the period
+	// over which precipitation is accumulated, ending at
+	// observation time. Precipitation of observations
+	// that can't be accumulated over it is missing.
+	// Only formats whose AccumulatesPrecipitation method
+	// returns true support it.
+	PrecipPeriod time.Duration
+	// PrecipitationFile, when not empty, is the path where
+	// precipitation of stations is saved, to verify its
+	// accumulation: as NetCDF when it ends with .nc,
+	// otherwise as CSV.
+	PrecipitationFile string
+	// Temperature12h, when true, makes observations of
+	// 12 hours before read from the input too, to reduce
+	// pressure with the 12 hours mean temperature of each
+	// station instead of the current one.
+	Temperature12h bool
+	// WindWindow, when greater than zero, is the time window,
+	// ending at observation time, over which wind is averaged
+	// as a vector by formats that read wind timelines
+	// (DewetraFormat).
+	WindWindow time.Duration
+}
+
+// OutputFormat is an enum that
+// contains all format supported for write
+// of converted observations.
+type OutputFormat int
+
+// OutputFormat values ...
+const (
+	WRFASCIIOutput OutputFormat = iota
+	NetCDFOutput
+)
+
+// FromString returns a new OutputFormat
+// for the format represented in given code
+func (f *OutputFormat) FromString(code string) {
+	if code == "WRFASCII" {
+		*f = WRFASCIIOutput
+	} else if code == "NETCDF" {
+		*f = NetCDFOutput
+	} else {
+		panic("Unknown output format " + code)
+	}
+}
+
+// String implements fmt.Stringer for OutputFormat
+func (f OutputFormat) String() string {
+	if f == WRFASCIIOutput {
+		return "WRFASCIIOutput"
+	}
+
+	if f == NetCDFOutput {
+		return "NetCDFOutput"
+	}
+
+	return fmt.Sprintf("%d", int(f))
+}
+
 // NewReader returns a obsreader.ObsReader that
 // read observations stored in this format.
 func (f InputFormat) NewReader() obsreader.ObsReader {
-	if f == DewetraFormat {
-		return obsreader.WebdropsObsReader{}
+	return f.newReader(Options{})
+}
 
+func (f InputFormat) newReader(opts Options) obsreader.ObsReader {
+	if f == DewetraFormat {
+		return obsreader.WebdropsObsReader{
+			ElevationSource: opts.ElevationSource,
+			PrecipPeriod:    opts.PrecipPeriod,
+			WindWindow:      opts.WindWindow,
+		}
 	}
 
 	if f == WundergroundFormat {
-		return obsreader.WundCurrentObsReader{}
-
+		return obsreader.WundCurrentObsReader{
+			ElevationSource: opts.ElevationSource,
+			PrecipPeriod:    opts.PrecipPeriod,
+		}
 	}
 
 	if f == WunderHistFormat {
-		return obsreader.WundHistObsReader{}
+		return obsreader.WundHistObsReader{
+			ElevationSource: opts.ElevationSource,
+			PrecipPeriod:    opts.PrecipPeriod,
+		}
+	}
+
+	if f == MetarFormat {
+		return obsreader.MetarObsReader{
+			StationsFile:    opts.StationsFile,
+			ElevationSource: opts.ElevationSource,
+		}
+	}
 
+	if f == SynopFormat {
+		return obsreader.SynopObsReader{
+			StationsFile:    opts.StationsFile,
+			ElevationSource: opts.ElevationSource,
+			PrecipPeriod:    opts.PrecipPeriod,
+		}
+	}
+
+	if f == BufrFormat {
+		return obsreader.BufrObsReader{
+			TablesDir:       opts.BufrTablesDir,
+			ElevationSource: opts.ElevationSource,
+		}
+	}
+
+	if f == CSVFormat {
+		return obsreader.CSVObsReader{
+			MappingFile:     opts.CSVMappingFile,
+			StationsFile:    opts.StationsFile,
+			ElevationSource: opts.ElevationSource,
+		}
+	}
+
+	if f == NetatmoFormat {
+		return obsreader.NetatmoObsReader{
+			ElevationSource: opts.ElevationSource,
+			PrecipPeriod:    opts.PrecipPeriod,
+		}
+	}
+
+	if f == WRFASCIIFormat {
+		return obsreader.WRFASCIIObsReader{ElevationSource: opts.ElevationSource}
+	}
+
+	if f == LittleRFormat {
+		return obsreader.LittleRObsReader{ElevationSource: opts.ElevationSource}
+	}
+
+	if f == WyomingFormat {
+		return obsreader.WyomingObsReader{ElevationSource: opts.ElevationSource}
 	}
 	panic("Unknown format " + f.String())
 
 }
 
+// AccumulatesPrecipitation returns whether readers of
+// the format can accumulate precipitation over the period
+// given in Options.PrecipPeriod: DewetraFormat,
+// WundergroundFormat, WunderHistFormat, SynopFormat
+// and NetatmoFormat.
+func (f InputFormat) AccumulatesPrecipitation() bool {
+	return f == DewetraFormat ||
+		f == WundergroundFormat ||
+		f == WunderHistFormat ||
+		f == SynopFormat ||
+		f == NetatmoFormat
+}
+
 // FromString returns a new InputFormat
 // for the format represented in given code
 func (f *InputFormat) FromString(code string) {
@@ -64,6 +268,22 @@ func (f *InputFormat) FromString(code string) {
 		*f = DewetraFormat
 	} else if code == "WUNDERHIST" {
 		*f = WunderHistFormat
+	} else if code == "METAR" {
+		*f = MetarFormat
+	} else if code == "SYNOP" {
+		*f = SynopFormat
+	} else if code == "BUFR" {
+		*f = BufrFormat
+	} else if code == "CSV" {
+		*f = CSVFormat
+	} else if code == "NETATMO" {
+		*f = NetatmoFormat
+	} else if code == "WRFASCII" {
+		*f = WRFASCIIFormat
+	} else if code == "LITTLER" {
+		*f = LittleRFormat
+	} else if code == "WYOMING" {
+		*f = WyomingFormat
 	} else {
 		panic("Unknown format " + code)
 	}
@@ -79,50 +299,216 @@ func (f InputFormat) String() string {
 		return "WundergroundFormat"
 	}
 
-	if f == DewetraFormat {
+	if f == WunderHistFormat {
 		return "WunderHistFormat"
 	}
 
+	if f == MetarFormat {
+		return "MetarFormat"
+	}
+
+	if f == SynopFormat {
+		return "SynopFormat"
+	}
+
+	if f == BufrFormat {
+		return "BufrFormat"
+	}
+
+	if f == CSVFormat {
+		return "CSVFormat"
+	}
+
+	if f == NetatmoFormat {
+		return "NetatmoFormat"
+	}
+
+	if f == WRFASCIIFormat {
+		return "WRFASCIIFormat"
+	}
+
+	if f == LittleRFormat {
+		return "LittleRFormat"
+	}
+
+	if f == WyomingFormat {
+		return "WyomingFormat"
+	}
+
 	return fmt.Sprintf("%d", int(f))
 }
 
+// regionFromS returns the region described by domainS:
+// the grid of a WRF domain when it's the path of a geo_em
+// or wrfinput file, otherwise the one returned by
+// types.RegionFromS.
+func regionFromS(domainS string, opts Options) (types.Region, error) {
+	base := filepath.Base(domainS)
+	if strings.HasPrefix(base, "geo_em") || strings.HasPrefix(base, "wrfinput") {
+		return wrfdomain.Open(domainS, opts.DomainMargin)
+	}
+	return types.RegionFromS(domainS)
+}
+
 // Convert converts a set of observations, saved in
 // format, contained in inputpath directory or file,
 // reading only data for stations contained in geographicval area
-// defined by domain arg, and skipping observation not occurred
+// defined by domain arg (see types.RegionFromS), and skipping observation not occurred
 // within 15 minutes from date.
 // Converted file is saved to outputpath, replacing existing file
 // if any, and using os.FileMode(0644) if the file has to be created.
 func Convert(format InputFormat, inputpath string, domainS string, date time.Time, outputpath string) error {
-	domainP, err := types.DomainFromS(domainS)
+	return ConvertWithOptions(format, inputpath, domainS, date, outputpath, Options{})
+}
+
+// ConvertWithOptions works like Convert, but allows
+// to tune the conversion using opts.
+func ConvertWithOptions(format InputFormat, inputpath string, domainS string, date time.Time, outputpath string, opts Options) error {
+	if opts.PrecipPeriod > 0 && !format.AccumulatesPrecipitation() {
+		return fmt.Errorf("%s can't accumulate precipitation over a period", format)
+	}
+
+	region, err := regionFromS(domainS, opts)
+	if err != nil {
+		return err
+	}
+	domain, err := types.NewBuffer(region, opts.Buffer*1000)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	domain := *domainP
 
-	sensorsObservations, err := format.NewReader().ReadAll(inputpath, domain, date)
+	if opts.DEMFile != "" {
+		if err := elevations.UseFile(opts.DEMFile); err != nil {
+			return err
+		}
+	}
+
+	var cache *elevations.StationCache
+	if opts.StationCacheFile != "" {
+		cache, err = elevations.OpenStationCache(opts.StationCacheFile, date)
+		if err != nil {
+			return err
+		}
+		elevations.UseStationCache(cache)
+		defer elevations.UseStationCache(nil)
+	}
+
+	reader := format.newReader(opts)
+	readDomain := domain
+	if opts.GeoJSONFile != "" {
+		// stations outside the domain
+		// are read too, to map them.
+		readDomain = worldDomain
+	}
+	readObservations, err := reader.ReadAll(inputpath, readDomain, date)
 	if err != nil {
 		return err
 	}
+	readObservations, outsideDomain := splitByDomain(readObservations, domain)
+
+	if cache != nil {
+		if err := cache.Save(); err != nil {
+			return err
+		}
+	}
+
+	if opts.PrecipPeriod > 0 {
+		readObservations = normalizePrecipitation(readObservations, opts.PrecipPeriod)
+	}
+
+	readObservations, err = completePressure(reader, inputpath, domain, date, readObservations, opts.Temperature12h)
+	if err != nil {
+		return err
+	}
+	readObservations = normalizeWind(readObservations)
+
+	rejectedElevation := []types.Observation{}
+	if opts.TerrainFile != "" {
+		terrain, err := elevations.OpenTerrain(opts.TerrainFile)
+		if err != nil {
+			return err
+		}
+		readObservations, rejectedElevation = checkElevations(readObservations, terrain, opts)
+	} else {
+		unknownModelElevations(readObservations)
+	}
+
+	if opts.PrecipitationFile != "" {
+		if err := writePrecipitation(opts.PrecipitationFile, readObservations); err != nil {
+			return err
+		}
+	}
+
+	sensorsObservations := []types.Observation{}
+	rejectedQC := []types.Observation{}
+	for _, obs := range readObservations {
+		if conversion.PassesQC(obs) {
+			sensorsObservations = append(sensorsObservations, obs)
+		} else {
+			rejectedQC = append(rejectedQC, obs)
+		}
+	}
+
+	if opts.GeoJSONFile != "" {
+		err = writeGeoJSON(opts.GeoJSONFile, sensorsObservations, outsideDomain, rejectedQC, rejectedElevation)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.OutputFormat == NetCDFOutput {
+		return conversion.WriteNetCDF(outputpath, sensorsObservations)
+	}
 
 	results := make([]string, len(sensorsObservations))
+	platforms := map[string]int{}
 	for i, result := range sensorsObservations {
 		results[i] = conversion.ToWRFASCII(result)
+		platforms[result.PlatformType()]++
 	}
 
 	resultsS := strings.Join(results, "\n")
 
-	header := fmt.Sprintf(headerFormat, len(results), len(results))
+	header := fmt.Sprintf(headerFormat, len(results),
+		platforms[types.PlatformSynop], platforms[types.PlatformMetar], platforms[types.PlatformTemp],
+		platforms[types.PlatformPilot], platforms[types.PlatformProfiler],
+	)
 
 	return ioutil.WriteFile(outputpath, []byte(header+resultsS), os.FileMode(0644))
 
 }
 
+// normalizePrecipitation sets precipitation of observations
+// to NaN, with an unknown period, when it's missing or not
+// accumulated over period, so that amounts over different
+// periods are never mixed.
+func normalizePrecipitation(observations []types.Observation, period time.Duration) []types.Observation {
+	for i, obs := range observations {
+		if obs.PrecipPeriod != period || obs.Metric.PrecipTotal.IsNaN() {
+			observations[i].Metric.PrecipTotal = types.NaN()
+			observations[i].PrecipPeriod = 0
+		}
+	}
+	return observations
+}
+
+// writePrecipitation saves precipitation of observations
+// to filename, as NetCDF when its extension is .nc,
+// otherwise as CSV.
+func writePrecipitation(filename string, observations []types.Observation) error {
+	if strings.EqualFold(filepath.Ext(filename), ".nc") {
+		return conversion.WritePrecipitationNetCDF(filename, observations)
+	}
+	var buf bytes.Buffer
+	conversion.WritePrecipitationCSV(&buf, observations)
+	return ioutil.WriteFile(filename, buf.Bytes(), os.FileMode(0644))
+}
+
 var headerFormat = "TOTAL = %6d, MISS. =-888888.,\n" +
-	"SYNOP = %6d, METAR =      0, SHIP  =      0, BUOY  =      0, BOGUS =      0, TEMP  =      0,\n" +
-	"AMDAR =      0, AIREP =      0, TAMDAR=      0, PILOT =      0, SATEM =      0, SATOB =      0,\n" +
+	"SYNOP = %6d, METAR = %6d, SHIP  =      0, BUOY  =      0, BOGUS =      0, TEMP  = %6d,\n" +
+	"AMDAR =      0, AIREP =      0, TAMDAR=      0, PILOT = %6d, SATEM =      0, SATOB =      0,\n" +
 	"GPSPW =      0, GPSZD =      0, GPSRF =      0, GPSEP =      0, SSMT1 =      0, SSMT2 =      0,\n" +
-	"TOVS  =      0, QSCAT =      0, PROFL =      0, AIRSR =      0, OTHER =      0,\n" +
+	"TOVS  =      0, QSCAT =      0, PROFL = %6d, AIRSR =      0, OTHER =      0,\n" +
 	"PHIC  =  40.00, XLONC = -95.00, TRUE1 =  30.00, TRUE2 =  60.00, XIM11 =   1.00, XJM11 =   1.00,\n" +
 	"base_temp= 290.00, base_lapse=  50.00, PTOP  =  5000., base_pres=100000., base_tropo_pres= 20000., base_strat_temp=   215.,\n" +
 	"IXC   =     60, JXC   =     90, IPROJ =      1, IDD   =      1, MAXNES=      1,\n" +
@@ -0,0 +1,170 @@
+package obsreader
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/obsreader/internal/bufr"
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// BufrObsReader reads observations from files
+// containing WMO BUFR surface land reports (e.g.
+// templates 307080 or 307096), edition 2, 3 or 4.
+// BUFR table B and table D are read from
+// CSV files in TablesDir.
+type BufrObsReader struct {
+	TablesDir string
+	// ElevationSource tells where the
+	// elevation of stations is taken from.
+	ElevationSource ElevationSource
+}
+
+// table B descriptors of the
+// elements read by BufrObsReader
+const (
+	bufrBlockNumber        bufr.Descriptor = 1001
+	bufrStationNumber      bufr.Descriptor = 1002
+	bufrStationName        bufr.Descriptor = 1015
+	bufrShortStationName   bufr.Descriptor = 1018
+	bufrYear               bufr.Descriptor = 4001
+	bufrMonth              bufr.Descriptor = 4002
+	bufrDay                bufr.Descriptor = 4003
+	bufrHour               bufr.Descriptor = 4004
+	bufrMinute             bufr.Descriptor = 4005
+	bufrLatitude           bufr.Descriptor = 5001
+	bufrLatitudeCoarse     bufr.Descriptor = 5002
+	bufrLongitude          bufr.Descriptor = 6001
+	bufrLongitudeCoarse    bufr.Descriptor = 6002
+	bufrStationHeight      bufr.Descriptor = 7001
+	bufrGroundHeight       bufr.Descriptor = 7030
+	bufrPressure           bufr.Descriptor = 10004
+	bufrSeaLevelPressure   bufr.Descriptor = 10051
+	bufrWindDirection      bufr.Descriptor = 11001
+	bufrWindSpeed          bufr.Descriptor = 11002
+	bufrTemperature        bufr.Descriptor = 12101
+	bufrTemperatureV3      bufr.Descriptor = 12001
+	bufrDewpoint           bufr.Descriptor = 12103
+	bufrDewpointV3         bufr.Descriptor = 12003
+	bufrRelativeHumidity   bufr.Descriptor = 13003
+	bufrTotalPrecipitation bufr.Descriptor = 13011
+	bufrVisibility         bufr.Descriptor = 20001
+)
+
+// ReadAll implements ObsReader for BufrObsReader.
+// dataPath could be a single file or a directory
+// containing many of them. For each station, only the
+// report closest to date within 30 minutes is returned.
+func (r BufrObsReader) ReadAll(dataPath string, domain types.Region, date time.Time) ([]types.Observation, error) {
+	if r.TablesDir == "" {
+		return nil, errors.New("BUFR reader requires a directory containing BUFR tables")
+	}
+	tables, err := bufr.LoadTables(r.TablesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := inputFiles(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	observations := []types.Observation{}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		messages, err := bufr.DecodeAll(content, tables)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, msg := range messages {
+			for _, subset := range msg.Subsets {
+				obs, ok := bufrObservation(msg, subset)
+				if !ok {
+					continue
+				}
+				if domain.Contains(obs.Lat, obs.Lon) {
+					obs.Elevation = r.ElevationSource.stationElevation(obs.StationID, obs.Elevation, obs.Lat, obs.Lon)
+					observations = append(observations, obs)
+				}
+			}
+		}
+	}
+
+	return closestToDate(observations, date, 30*time.Minute), nil
+}
+
+// bufrNumber returns the first not missing value of the
+// first descriptor in descs found in subset, or NaN.
+func bufrNumber(subset bufr.Subset, descs ...bufr.Descriptor) float64 {
+	for _, desc := range descs {
+		if v, ok := subset.First(desc); ok {
+			return v.Number
+		}
+	}
+	return math.NaN()
+}
+
+// bufrObservation converts a data subset into
+// a types.Observation. It returns false if the
+// subset lacks station coordinates or identifier.
+func bufrObservation(msg *bufr.Message, subset bufr.Subset) (types.Observation, bool) {
+	obs := missingObservation(types.PlatformSynop)
+	obs.Group = types.WMOStations
+
+	block := bufrNumber(subset, bufrBlockNumber)
+	number := bufrNumber(subset, bufrStationNumber)
+	if !math.IsNaN(block) && !math.IsNaN(number) {
+		obs.StationID = fmt.Sprintf("%02d%03d", int(block), int(number))
+	}
+	if v, ok := subset.First(bufrStationName); ok {
+		obs.StationName = v.Text
+	} else if v, ok := subset.First(bufrShortStationName); ok {
+		obs.StationName = v.Text
+	}
+	if obs.StationID == "" {
+		obs.StationID = obs.StationName
+	}
+	if obs.StationName == "" {
+		obs.StationName = obs.StationID
+	}
+
+	obs.Lat = bufrNumber(subset, bufrLatitude, bufrLatitudeCoarse)
+	obs.Lon = bufrNumber(subset, bufrLongitude, bufrLongitudeCoarse)
+	if obs.StationID == "" || math.IsNaN(obs.Lat) || math.IsNaN(obs.Lon) {
+		return obs, false
+	}
+	obs.Elevation = bufrNumber(subset, bufrGroundHeight, bufrStationHeight)
+
+	obs.ObsTimeUtc = msg.Time
+	year := bufrNumber(subset, bufrYear)
+	month := bufrNumber(subset, bufrMonth)
+	day := bufrNumber(subset, bufrDay)
+	hour := bufrNumber(subset, bufrHour)
+	if !math.IsNaN(year) && !math.IsNaN(month) && !math.IsNaN(day) && !math.IsNaN(hour) {
+		minute := bufrNumber(subset, bufrMinute)
+		if math.IsNaN(minute) {
+			minute = 0
+		}
+		obs.ObsTimeUtc = time.Date(int(year), time.Month(month), int(day), int(hour), int(minute), 0, 0, time.UTC)
+	}
+
+	// BUFR values are already in °K, m/s and Pa
+	obs.Metric.Pressure = types.Value(bufrNumber(subset, bufrPressure))
+	obs.Metric.SeaLevelPressure = types.Value(bufrNumber(subset, bufrSeaLevelPressure))
+	obs.Metric.TempAvg = types.Value(bufrNumber(subset, bufrTemperature, bufrTemperatureV3))
+	obs.Metric.DewptAvg = types.Value(bufrNumber(subset, bufrDewpoint, bufrDewpointV3))
+	obs.Metric.WindspeedAvg = types.Value(bufrNumber(subset, bufrWindSpeed))
+	// precipitation in kg/m² is equal to mm
+	obs.Metric.PrecipTotal = types.Value(bufrNumber(subset, bufrTotalPrecipitation))
+	obs.WinddirAvg = types.Value(bufrNumber(subset, bufrWindDirection))
+	obs.HumidityAvg = types.Value(bufrNumber(subset, bufrRelativeHumidity))
+	obs.Visibility = types.Value(bufrNumber(subset, bufrVisibility))
+
+	return obs, true
+}
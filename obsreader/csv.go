@@ -0,0 +1,541 @@
+package obsreader
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/meteocima/dewetra2wrf/units"
+	"github.com/meteocima/dewetra2wrf/wind"
+)
+
+// CSVColumn identifies a column of a CSV file,
+// by its name in the header line or by its
+// zero based index, together with the unit
+// of measure of the values it contains.
+type CSVColumn struct {
+	Name  string `json:"name"`
+	Index *int   `json:"index"`
+	Unit  string `json:"unit"`
+}
+
+// CSVMapping describes the layout of CSV files
+// read by CSVObsReader.
+type CSVMapping struct {
+	// Separator is the fields separator, "," if empty.
+	Separator string `json:"separator"`
+	// Decimal is the decimal separator, "." if empty.
+	Decimal string `json:"decimal"`
+	// Header tells whether the first line
+	// of the file contains columns names.
+	Header bool `json:"header"`
+	// SkipLines is the number of lines to skip
+	// at the beginning of the file, before the header.
+	SkipLines int `json:"skipLines"`
+	// TimeFormat is the layout of times, as
+	// accepted by time.Parse, or "unix" for
+	// seconds since epoch.
+	TimeFormat string `json:"timeFormat"`
+	// TimeZone is the IANA name of the time zone
+	// of times lacking an offset, UTC if empty.
+	TimeZone string `json:"timeZone"`
+	// Missing contains the values that
+	// represent a missing measure.
+	Missing []string `json:"missing"`
+	// StationsFile is the path of a stations table
+	// used to locate stations when the file lacks
+	// coordinates columns. Relative paths are
+	// resolved from the directory of the mapping file.
+	StationsFile string `json:"stationsFile"`
+
+	StationID   CSVColumn `json:"stationId"`
+	StationName CSVColumn `json:"stationName"`
+	Lat         CSVColumn `json:"lat"`
+	Lon         CSVColumn `json:"lon"`
+	Elevation   CSVColumn `json:"elevation"`
+	// Time contains one or more columns whose values,
+	// joined by a space, contain the time of the observation.
+	Time             []CSVColumn `json:"time"`
+	Temperature      CSVColumn   `json:"temperature"`
+	Dewpoint         CSVColumn   `json:"dewpoint"`
+	Humidity         CSVColumn   `json:"humidity"`
+	WindSpeed        CSVColumn   `json:"windSpeed"`
+	WindDirection    CSVColumn   `json:"windDirection"`
+	WindGust         CSVColumn   `json:"windGust"`
+	Pressure         CSVColumn   `json:"pressure"`
+	SeaLevelPressure CSVColumn   `json:"seaLevelPressure"`
+	Precipitation    CSVColumn   `json:"precipitation"`
+	PrecipRate       CSVColumn   `json:"precipRate"`
+	Visibility       CSVColumn   `json:"visibility"`
+	// WindU and WindV are the eastward and northward
+	// components of wind, used for speed and direction
+	// when they are not mapped or missing.
+	WindU CSVColumn `json:"windU"`
+	WindV CSVColumn `json:"windV"`
+}
+
+func csvIndex(i int) CSVColumn {
+	return CSVColumn{Index: &i}
+}
+
+// DefaultCSVMapping is the mapping of CSV files
+// written by conversion.WriteCSVObservation.
+var DefaultCSVMapping = CSVMapping{
+	TimeFormat:    time.RFC3339,
+	Missing:       []string{"-888888.000"},
+	StationID:     csvIndex(0),
+	Lat:           csvIndex(1),
+	Lon:           csvIndex(2),
+	Elevation:     csvIndex(3),
+	Time:          []CSVColumn{csvIndex(4)},
+	Pressure:      csvIndex(5),
+	Precipitation: csvIndex(6),
+	Humidity:      csvIndex(7),
+	Temperature:   csvIndex(8),
+	WindSpeed:     csvIndex(9),
+	WindGust:      csvIndex(10),
+	PrecipRate:    csvIndex(11),
+	WindDirection: csvIndex(12),
+}
+
+// ReadCSVMapping reads a CSVMapping from a JSON file.
+func ReadCSVMapping(file string) (CSVMapping, error) {
+	var mapping CSVMapping
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return mapping, err
+	}
+	err = json.Unmarshal(content, &mapping)
+	if err != nil {
+		return mapping, fmt.Errorf("%s: %w", file, err)
+	}
+	if mapping.StationsFile != "" && !filepath.IsAbs(mapping.StationsFile) {
+		mapping.StationsFile = filepath.Join(filepath.Dir(file), mapping.StationsFile)
+	}
+	return mapping, nil
+}
+
+// CSVObsReader reads observations from CSV
+// files, whose layout is described by the
+// JSON mapping file at MappingFile.
+// When MappingFile is empty, DefaultCSVMapping
+// is used. When StationsFile is not empty, it
+// overrides the stations table of the mapping.
+type CSVObsReader struct {
+	MappingFile  string
+	StationsFile string
+	// ElevationSource tells where the
+	// elevation of stations is taken from.
+	ElevationSource ElevationSource
+}
+
+// ReadAll implements ObsReader for CSVObsReader.
+// dataPath could be a single file or a directory
+// containing many of them. For each station, only the
+// observation closest to date within 30 minutes is returned.
+func (r CSVObsReader) ReadAll(dataPath string, domain types.Region, date time.Time) ([]types.Observation, error) {
+	mapping := DefaultCSVMapping
+	if r.MappingFile != "" {
+		var err error
+		mapping, err = ReadCSVMapping(r.MappingFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.StationsFile != "" {
+		mapping.StationsFile = r.StationsFile
+	}
+
+	parser, err := newCSVParser(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := inputFiles(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	observations := []types.Observation{}
+	for _, file := range files {
+		fileObs, err := parser.readFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, obs := range fileObs {
+			if parser.stations != nil {
+				elevation := obs.Elevation
+				if !parser.stations.locate(&obs, domain) {
+					continue
+				}
+				if !math.IsNaN(elevation) {
+					obs.Elevation = elevation
+				}
+			} else if math.IsNaN(obs.Lat) || math.IsNaN(obs.Lon) ||
+				!domain.Contains(obs.Lat, obs.Lon) {
+				continue
+			}
+			obs.Elevation = r.ElevationSource.stationElevation(obs.StationID, obs.Elevation, obs.Lat, obs.Lon)
+			observations = append(observations, obs)
+		}
+	}
+
+	return closestToDate(observations, date, 30*time.Minute), nil
+}
+
+// csvField is a mapped column of
+// numeric values, already resolved
+// to its index and unit.
+type csvField struct {
+	column CSVColumn
+	index  int
+	unit   units.Unit
+}
+
+type csvParser struct {
+	mapping  CSVMapping
+	location *time.Location
+	stations stationsTable
+	missing  map[string]bool
+	// missingValues contains numeric values
+	// of Missing, compared after parsing.
+	missingValues map[float64]bool
+
+	stationID, stationName, lat, lon, elevation csvField
+	times                                       []csvField
+	temperature, dewpoint, humidity             csvField
+	windSpeed, windDirection, windGust          csvField
+	windU, windV                                csvField
+	pressure, seaLevelPressure                  csvField
+	precipitation, precipRate, visibility       csvField
+}
+
+func newCSVParser(mapping CSVMapping) (*csvParser, error) {
+	p := &csvParser{
+		mapping:  mapping,
+		location: time.UTC,
+		missing:  map[string]bool{"": true},
+
+		missingValues: map[float64]bool{},
+	}
+	for _, m := range mapping.Missing {
+		p.missing[m] = true
+		if val, err := strconv.ParseFloat(m, 64); err == nil {
+			p.missingValues[val] = true
+		}
+	}
+
+	if mapping.TimeZone != "" {
+		var err error
+		if p.location, err = time.LoadLocation(mapping.TimeZone); err != nil {
+			return nil, err
+		}
+	}
+	if mapping.StationsFile != "" {
+		var err error
+		if p.stations, err = openStationsTable(mapping.StationsFile, nil); err != nil {
+			return nil, err
+		}
+	}
+	if mapping.TimeFormat == "" {
+		p.mapping.TimeFormat = time.RFC3339
+	}
+
+	field := func(col CSVColumn, defaultUnit units.Unit) (csvField, error) {
+		f := csvField{column: col, index: -1, unit: defaultUnit}
+		if col.Unit == "" || defaultUnit == (units.Unit{}) {
+			return f, nil
+		}
+		var err error
+		f.unit, err = units.Parse(defaultUnit.Quantity, col.Unit)
+		return f, err
+	}
+
+	var err error
+	if p.stationID, err = field(mapping.StationID, units.Unit{}); err != nil {
+		return nil, err
+	}
+	if p.stationName, err = field(mapping.StationName, units.Unit{}); err != nil {
+		return nil, err
+	}
+	if p.lat, err = field(mapping.Lat, units.Degree); err != nil {
+		return nil, err
+	}
+	if p.lon, err = field(mapping.Lon, units.Degree); err != nil {
+		return nil, err
+	}
+	if p.elevation, err = field(mapping.Elevation, units.Meter); err != nil {
+		return nil, err
+	}
+	for _, col := range mapping.Time {
+		f, _ := field(col, units.Unit{})
+		p.times = append(p.times, f)
+	}
+	if p.temperature, err = field(mapping.Temperature, units.Kelvin); err != nil {
+		return nil, err
+	}
+	if p.dewpoint, err = field(mapping.Dewpoint, units.Kelvin); err != nil {
+		return nil, err
+	}
+	if p.humidity, err = field(mapping.Humidity, units.Percent); err != nil {
+		return nil, err
+	}
+	if p.windSpeed, err = field(mapping.WindSpeed, units.MetersPerSecond); err != nil {
+		return nil, err
+	}
+	if p.windDirection, err = field(mapping.WindDirection, units.Degree); err != nil {
+		return nil, err
+	}
+	if p.pressure, err = field(mapping.Pressure, units.Pascal); err != nil {
+		return nil, err
+	}
+	if p.seaLevelPressure, err = field(mapping.SeaLevelPressure, units.Pascal); err != nil {
+		return nil, err
+	}
+	if p.precipitation, err = field(mapping.Precipitation, units.Millimeter); err != nil {
+		return nil, err
+	}
+	if p.windGust, err = field(mapping.WindGust, units.MetersPerSecond); err != nil {
+		return nil, err
+	}
+	if p.windU, err = field(mapping.WindU, units.MetersPerSecond); err != nil {
+		return nil, err
+	}
+	if p.windV, err = field(mapping.WindV, units.MetersPerSecond); err != nil {
+		return nil, err
+	}
+	if p.precipRate, err = field(mapping.PrecipRate, units.Millimeter); err != nil {
+		return nil, err
+	}
+	if p.visibility, err = field(mapping.Visibility, units.Meter); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *csvParser) fields() []*csvField {
+	fields := []*csvField{
+		&p.stationID, &p.stationName, &p.lat, &p.lon, &p.elevation,
+		&p.temperature, &p.dewpoint, &p.humidity, &p.windSpeed, &p.windDirection,
+		&p.windGust, &p.windU, &p.windV, &p.pressure, &p.seaLevelPressure, &p.precipitation,
+		&p.precipRate, &p.visibility,
+	}
+	for i := range p.times {
+		fields = append(fields, &p.times[i])
+	}
+	return fields
+}
+
+// resolve sets the index of all
+// mapped columns, using header to
+// find columns by name.
+func (p *csvParser) resolve(header []string) error {
+	names := map[string]int{}
+	for idx, name := range header {
+		names[strings.TrimSpace(name)] = idx
+	}
+
+	for _, f := range p.fields() {
+		f.index = -1
+		if f.column.Index != nil {
+			f.index = *f.column.Index
+		} else if f.column.Name != "" {
+			idx, ok := names[f.column.Name]
+			if !ok {
+				return fmt.Errorf("column `%s` not found", f.column.Name)
+			}
+			f.index = idx
+		}
+	}
+
+	if p.stationID.index == -1 {
+		return fmt.Errorf("station ID column is not mapped")
+	}
+	if len(p.times) == 0 {
+		return fmt.Errorf("time column is not mapped")
+	}
+	if p.stations == nil && (p.lat.index == -1 || p.lon.index == -1) {
+		return fmt.Errorf("coordinates columns are not mapped, and no stations table is given")
+	}
+	return nil
+}
+
+func (p *csvParser) text(record []string, f csvField) string {
+	if f.index < 0 || f.index >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[f.index])
+}
+
+func (p *csvParser) number(record []string, f csvField) (types.Value, error) {
+	raw := p.text(record, f)
+	if f.index < 0 || p.missing[raw] {
+		return types.NaN(), nil
+	}
+	if p.mapping.Decimal != "" && p.mapping.Decimal != "." {
+		raw = strings.ReplaceAll(raw, p.mapping.Decimal, ".")
+	}
+	val, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return types.NaN(), err
+	}
+	if p.missingValues[val] {
+		return types.NaN(), nil
+	}
+	return types.Value(f.unit.ToBase(val)), nil
+}
+
+func (p *csvParser) time(record []string) (time.Time, error) {
+	parts := make([]string, len(p.times))
+	for i, f := range p.times {
+		parts[i] = p.text(record, f)
+	}
+	raw := strings.Join(parts, " ")
+
+	if p.mapping.TimeFormat == "unix" {
+		secs, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(secs, 0).UTC(), nil
+	}
+
+	at, err := time.ParseInLocation(p.mapping.TimeFormat, raw, p.location)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return at.UTC(), nil
+}
+
+func (p *csvParser) readFile(file string) ([]types.Observation, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if p.mapping.Separator != "" {
+		if p.mapping.Separator == "\\t" {
+			reader.Comma = '\t'
+		} else {
+			reader.Comma = []rune(p.mapping.Separator)[0]
+		}
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) <= p.mapping.SkipLines {
+		return nil, nil
+	}
+	records = records[p.mapping.SkipLines:]
+
+	var header []string
+	if p.mapping.Header && len(records) > 0 {
+		header = records[0]
+		records = records[1:]
+	}
+	if err := p.resolve(header); err != nil {
+		return nil, err
+	}
+
+	observations := []types.Observation{}
+	for line, record := range records {
+		obs, err := p.observation(record)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", line+1, err)
+		}
+		observations = append(observations, obs)
+	}
+
+	return observations, nil
+}
+
+func (p *csvParser) observation(record []string) (types.Observation, error) {
+	obs := missingObservation(types.PlatformSynop)
+	// CSV files come from regional agencies networks
+	obs.Group = types.DPCTrusted
+	obs.Elevation = math.NaN()
+	obs.StationID = p.text(record, p.stationID)
+	obs.StationName = p.text(record, p.stationName)
+	if obs.StationName == "" {
+		obs.StationName = obs.StationID
+	}
+
+	var err error
+	if obs.ObsTimeUtc, err = p.time(record); err != nil {
+		return obs, err
+	}
+
+	values := []struct {
+		field  csvField
+		target *types.Value
+	}{
+		{p.temperature, &obs.Metric.TempAvg},
+		{p.dewpoint, &obs.Metric.DewptAvg},
+		{p.humidity, &obs.HumidityAvg},
+		{p.windSpeed, &obs.Metric.WindspeedAvg},
+		{p.windDirection, &obs.WinddirAvg},
+		{p.windGust, &obs.Metric.WindgustAvg},
+		{p.pressure, &obs.Metric.Pressure},
+		{p.seaLevelPressure, &obs.Metric.SeaLevelPressure},
+		{p.precipitation, &obs.Metric.PrecipTotal},
+		{p.precipRate, &obs.Metric.PrecipRate},
+		{p.visibility, &obs.Visibility},
+	}
+	for _, v := range values {
+		if *v.target, err = p.number(record, v.field); err != nil {
+			return obs, err
+		}
+	}
+
+	u, err := p.number(record, p.windU)
+	if err != nil {
+		return obs, err
+	}
+	v, err := p.number(record, p.windV)
+	if err != nil {
+		return obs, err
+	}
+	if !u.IsNaN() && !v.IsNaN() {
+		speed, dir := wind.FromComponents(u.AsFloat(), v.AsFloat())
+		if obs.Metric.WindspeedAvg.IsNaN() {
+			obs.Metric.WindspeedAvg = types.Value(speed)
+		}
+		if obs.WinddirAvg.IsNaN() {
+			obs.WinddirAvg = types.Value(dir)
+		}
+	}
+
+	if p.stations == nil {
+		lat, err := p.number(record, p.lat)
+		if err != nil {
+			return obs, err
+		}
+		lon, err := p.number(record, p.lon)
+		if err != nil {
+			return obs, err
+		}
+		obs.Lat, obs.Lon = lat.AsFloat(), lon.AsFloat()
+	}
+	elevation, err := p.number(record, p.elevation)
+	if err != nil {
+		return obs, err
+	}
+	obs.Elevation = elevation.AsFloat()
+
+	return obs, nil
+}
@@ -0,0 +1,133 @@
+package obsreader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/conversion"
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var allDomain = types.Domain{MinLat: -90, MaxLat: 90, MinLon: -180, MaxLon: 180}
+
+func TestCSVRoundTrip(t *testing.T) {
+	written := []types.Observation{
+		{
+			StationID:   "210329130_2",
+			Lat:         41.469,
+			Lon:         15.483,
+			Elevation:   74,
+			ObsTimeUtc:  time.Date(2020, 3, 30, 18, 0, 0, 0, time.UTC),
+			HumidityAvg: 75,
+			WinddirAvg:  275,
+			Metric: types.ObservationMetric{
+				Pressure:     101300,
+				PrecipTotal:  types.NaN(),
+				TempAvg:      286.15,
+				WindspeedAvg: 0.6,
+			},
+		},
+		{
+			StationID:   "ILIGURIA42",
+			Lat:         44.405,
+			Lon:         8.67,
+			Elevation:   12,
+			ObsTimeUtc:  time.Date(2020, 3, 30, 18, 5, 0, 0, time.UTC),
+			HumidityAvg: types.NaN(),
+			WinddirAvg:  types.NaN(),
+			Metric: types.ObservationMetric{
+				Pressure:     types.NaN(),
+				PrecipTotal:  1.2,
+				TempAvg:      283.5,
+				WindspeedAvg: types.NaN(),
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	for _, obs := range written {
+		conversion.WriteCSVObservation(&buf, obs)
+	}
+	file := filepath.Join(t.TempDir(), "obs.csv")
+	assert.NoError(t, ioutil.WriteFile(file, buf.Bytes(), 0644))
+
+	read, err := CSVObsReader{}.ReadAll(file, allDomain, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, len(written), len(read))
+
+	for i, obs := range read {
+		var rewritten bytes.Buffer
+		conversion.WriteCSVObservation(&rewritten, obs)
+		var expected bytes.Buffer
+		conversion.WriteCSVObservation(&expected, written[i])
+		assert.Equal(t, expected.String(), rewritten.String())
+		assert.Equal(t, written[i].StationID, obs.StationName)
+	}
+	// speed and direction are read as written,
+	// not from rounded wind components
+	assert.Equal(t, types.Value(0.6), read[0].Metric.WindspeedAvg)
+	assert.Equal(t, types.Value(275), read[0].WinddirAvg)
+}
+
+func TestCSVMapping(t *testing.T) {
+	dir := t.TempDir()
+	mapping := `{
+		"separator": ";",
+		"decimal": ",",
+		"header": true,
+		"skipLines": 1,
+		"timeFormat": "02/01/2006 15:04",
+		"timeZone": "Europe/Rome",
+		"missing": ["-9999", "N/D"],
+		"stationsFile": "stations.csv",
+		"stationId": {"name": "CODICE"},
+		"time": [{"name": "DATA"}, {"name": "ORA"}],
+		"temperature": {"name": "TEMP", "unit": "°C"},
+		"windSpeed": {"name": "VV", "unit": "km/h"},
+		"pressure": {"name": "PRESS", "unit": "hPa"},
+		"humidity": {"index": 6}
+	}`
+	data := "Dati ARPA\n" +
+		"CODICE;DATA;ORA;TEMP;VV;PRESS;UR\n" +
+		"A001;30/03/2020;20:00;12,5;36;1013,2;80\n" +
+		"A002;30/03/2020;20:00;N/D;-9999,0;-9999;75\n" +
+		"A003;30/03/2020;20:00;10;0;1000;50\n"
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "mapping.json"), []byte(mapping), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "stations.csv"), []byte("A001,44.1,8.2,120,Station 1\nA002,44.2,8.3,,Station 2\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "data.csv"), []byte(data), 0644))
+
+	reader := CSVObsReader{MappingFile: filepath.Join(dir, "mapping.json")}
+	observations, err := reader.ReadAll(filepath.Join(dir, "data.csv"), allDomain, time.Date(2020, 3, 30, 18, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	// A003 is not in stations table
+	assert.Equal(t, 2, len(observations))
+
+	obs := observations[0]
+	assert.Equal(t, "A001", obs.StationID)
+	assert.Equal(t, "Station 1", obs.StationName)
+	assert.Equal(t, 44.1, obs.Lat)
+	assert.Equal(t, 120.0, obs.Elevation)
+	// 20:00 CEST is 18:00 UTC
+	assert.Equal(t, time.Date(2020, 3, 30, 18, 0, 0, 0, time.UTC), obs.ObsTimeUtc)
+	assert.InDelta(t, 285.65, obs.Metric.TempAvg.AsFloat(), 1e-6)
+	assert.InDelta(t, 10, obs.Metric.WindspeedAvg.AsFloat(), 1e-3)
+	assert.InDelta(t, 101320, obs.Metric.Pressure.AsFloat(), 1e-6)
+	assert.Equal(t, types.Value(80), obs.HumidityAvg)
+	assert.True(t, obs.Metric.DewptAvg.IsNaN())
+
+	obs = observations[1]
+	assert.True(t, obs.Metric.TempAvg.IsNaN())
+	assert.True(t, obs.Metric.WindspeedAvg.IsNaN())
+	assert.True(t, obs.Metric.Pressure.IsNaN())
+	assert.Equal(t, types.Value(75), obs.HumidityAvg)
+}
+
+func TestCSVMappingUnknownUnit(t *testing.T) {
+	_, err := newCSVParser(CSVMapping{Temperature: CSVColumn{Name: "T", Unit: "°R"}})
+	assert.EqualError(t, err, "unknown temperature unit `°R`")
+}
@@ -0,0 +1,86 @@
+package obsreader
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/meteocima/dewetra2wrf/elevations"
+)
+
+// ElevationSource tells where readers take
+// the elevation of stations from.
+type ElevationSource int
+
+// ElevationSource values
+const (
+	// ReportedOrDEM uses the elevation reported by the
+	// source, reading it from the DEM when it's missing.
+	ReportedOrDEM ElevationSource = iota
+	// Reported uses only the elevation reported by
+	// the source, leaving it missing when absent.
+	Reported
+	// DEM always reads elevation from the DEM,
+	// ignoring the reported one.
+	DEM
+)
+
+// elevationDiscrepancy is the difference in meters
+// between reported and DEM elevation above which a
+// station is logged, since it usually means that
+// its coordinates are wrong.
+const elevationDiscrepancy = 200.0
+
+// stationElevation returns the elevation of the station
+// with given id and coordinates, accordingly to source.
+// reported is the elevation reported by the source,
+// or NaN when missing.
+func (source ElevationSource) stationElevation(id string, reported, lat, lon float64) float64 {
+	if source == Reported {
+		return reported
+	}
+
+	dem := elevations.StationElevation(id, lat, lon)
+	if math.IsNaN(reported) {
+		return dem
+	}
+	if math.Abs(reported-dem) > elevationDiscrepancy {
+		log.Printf("station %s at %.4f,%.4f: reported elevation %.0f m differs from DEM elevation %.0f m",
+			id, lat, lon, reported, dem)
+	}
+	if source == DEM {
+		return dem
+	}
+	return reported
+}
+
+// FromString returns a new ElevationSource
+// for the source represented in given code
+func (source *ElevationSource) FromString(code string) {
+	if code == "REPORTED_OR_DEM" {
+		*source = ReportedOrDEM
+	} else if code == "REPORTED" {
+		*source = Reported
+	} else if code == "DEM" {
+		*source = DEM
+	} else {
+		panic("Unknown elevation source " + code)
+	}
+}
+
+// String implements fmt.Stringer for ElevationSource
+func (source ElevationSource) String() string {
+	if source == ReportedOrDEM {
+		return "ReportedOrDEM"
+	}
+
+	if source == Reported {
+		return "Reported"
+	}
+
+	if source == DEM {
+		return "DEM"
+	}
+
+	return fmt.Sprintf("%d", int(source))
+}
@@ -0,0 +1,33 @@
+package obsreader
+
+import (
+	"math"
+	"testing"
+
+	"github.com/meteocima/dewetra2wrf/elevations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStationElevation(t *testing.T) {
+	dem := elevations.GetFromCoord(45.63, 8.72)
+
+	assert.Equal(t, 234.0, Reported.stationElevation("LIMC", 234, 45.63, 8.72))
+	assert.True(t, math.IsNaN(Reported.stationElevation("LIMC", math.NaN(), 45.63, 8.72)))
+
+	assert.Equal(t, 234.0, ReportedOrDEM.stationElevation("LIMC", 234, 45.63, 8.72))
+	assert.Equal(t, dem, ReportedOrDEM.stationElevation("LIMC", math.NaN(), 45.63, 8.72))
+
+	assert.Equal(t, dem, DEM.stationElevation("LIMC", 234, 45.63, 8.72))
+	assert.Equal(t, dem, DEM.stationElevation("LIMC", math.NaN(), 45.63, 8.72))
+}
+
+func TestElevationSourceFromString(t *testing.T) {
+	var source ElevationSource
+	source.FromString("DEM")
+	assert.Equal(t, DEM, source)
+	source.FromString("REPORTED")
+	assert.Equal(t, Reported, source)
+	source.FromString("REPORTED_OR_DEM")
+	assert.Equal(t, ReportedOrDEM, source)
+	assert.Panics(t, func() { source.FromString("GPS") })
+}
@@ -0,0 +1,508 @@
+// package bufr implements a decoder for WMO
+// BUFR messages of edition 2, 3 and 4,
+// using table B and table D read from local files.
+package bufr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Value is a single decoded element
+// of a BUFR data subset.
+type Value struct {
+	Element
+	// Number contains the value of numeric
+	// elements, or NaN if it is missing.
+	Number float64
+	// Text contains the value of
+	// CCITT IA5 elements.
+	Text string
+}
+
+// Missing returns whether the value is missing.
+func (v Value) Missing() bool {
+	if v.IsText() {
+		return v.Text == ""
+	}
+	return math.IsNaN(v.Number)
+}
+
+// Subset contains the values of a BUFR data
+// subset, in the order they were decoded.
+type Subset []Value
+
+// First returns the first value with
+// descriptor desc that is not missing.
+func (s Subset) First(desc Descriptor) (Value, bool) {
+	for _, v := range s {
+		if v.Descriptor == desc && !v.Missing() {
+			return v, true
+		}
+	}
+	return Value{}, false
+}
+
+// Message is a decoded BUFR message.
+type Message struct {
+	Edition     int
+	Centre      int
+	Category    int
+	Time        time.Time
+	Descriptors []Descriptor
+	Subsets     []Subset
+}
+
+// DecodeAll decodes all BUFR messages
+// contained in data. Bytes outside messages, as
+// bulletin headers and envelopes, are ignored.
+func DecodeAll(data []byte, tables *Tables) ([]*Message, error) {
+	messages := []*Message{}
+	for {
+		start := bytes.Index(data, []byte("BUFR"))
+		if start == -1 {
+			return messages, nil
+		}
+		data = data[start:]
+		if len(data) < 8 {
+			return nil, errors.New("truncated BUFR message")
+		}
+
+		length := int(uint24(data[4:7]))
+		if length < 8 || length > len(data) {
+			return nil, errors.New("truncated BUFR message")
+		}
+
+		msg, err := Decode(data[:length], tables)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+		data = data[length:]
+	}
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
+
+// section returns the section starting at
+// the beginning of data, and the remaining data.
+func section(data []byte, name string) ([]byte, []byte, error) {
+	if len(data) < 3 {
+		return nil, nil, fmt.Errorf("truncated section %s", name)
+	}
+	length := int(uint24(data))
+	if length < 3 || length > len(data) {
+		return nil, nil, fmt.Errorf("invalid length of section %s", name)
+	}
+	return data[:length], data[length:], nil
+}
+
+// Decode decodes a single BUFR message.
+func Decode(data []byte, tables *Tables) (*Message, error) {
+	if len(data) < 8 || string(data[:4]) != "BUFR" {
+		return nil, errors.New("not a BUFR message")
+	}
+	msg := &Message{Edition: int(data[7])}
+	if msg.Edition < 2 || msg.Edition > 4 {
+		return nil, fmt.Errorf("unsupported BUFR edition %d", msg.Edition)
+	}
+
+	sec1, rest, err := section(data[8:], "1")
+	if err != nil {
+		return nil, err
+	}
+
+	optionalSection := false
+	if msg.Edition == 4 {
+		if len(sec1) < 22 {
+			return nil, errors.New("truncated section 1")
+		}
+		msg.Centre = int(sec1[4])<<8 | int(sec1[5])
+		optionalSection = sec1[9]&0x80 != 0
+		msg.Category = int(sec1[10])
+		year := int(sec1[15])<<8 | int(sec1[16])
+		msg.Time = time.Date(year, time.Month(sec1[17]), int(sec1[18]), int(sec1[19]), int(sec1[20]), int(sec1[21]), 0, time.UTC)
+	} else {
+		if len(sec1) < 17 {
+			return nil, errors.New("truncated section 1")
+		}
+		msg.Centre = int(sec1[5])
+		optionalSection = sec1[7]&0x80 != 0
+		msg.Category = int(sec1[8])
+		year := int(sec1[12])
+		if year <= 100 {
+			year += 1900
+			if year < 1950 {
+				year += 100
+			}
+		}
+		msg.Time = time.Date(year, time.Month(sec1[13]), int(sec1[14]), int(sec1[15]), int(sec1[16]), 0, 0, time.UTC)
+	}
+
+	if optionalSection {
+		_, rest, err = section(rest, "2")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sec3, rest, err := section(rest, "3")
+	if err != nil {
+		return nil, err
+	}
+	if len(sec3) < 7 {
+		return nil, errors.New("truncated section 3")
+	}
+	nsubsets := int(sec3[4])<<8 | int(sec3[5])
+	compressed := sec3[6]&0x40 != 0
+	for i := 7; i+1 < len(sec3); i += 2 {
+		f := int(sec3[i] >> 6)
+		x := int(sec3[i] & 0x3f)
+		y := int(sec3[i+1])
+		msg.Descriptors = append(msg.Descriptors, NewDescriptor(f, x, y))
+	}
+
+	sec4, _, err := section(rest, "4")
+	if err != nil {
+		return nil, err
+	}
+	if len(sec4) < 4 {
+		return nil, errors.New("truncated section 4")
+	}
+
+	dec := &decoder{
+		tables: tables,
+		r:      &bitReader{data: sec4[4:]},
+	}
+
+	if compressed {
+		dec.subsets = make([]Subset, nsubsets)
+		dec.compressed = true
+		if err := dec.expand(msg.Descriptors); err != nil {
+			return nil, err
+		}
+		msg.Subsets = dec.subsets
+		return msg, nil
+	}
+
+	for i := 0; i < nsubsets; i++ {
+		dec.subsets = make([]Subset, 1)
+		dec.resetOperators()
+		if err := dec.expand(msg.Descriptors); err != nil {
+			return nil, fmt.Errorf("subset %d: %w", i, err)
+		}
+		msg.Subsets = append(msg.Subsets, dec.subsets[0])
+	}
+
+	return msg, nil
+}
+
+// bitReader reads big endian
+// bit fields from a byte slice.
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+var errTruncated = errors.New("truncated data section")
+
+func (r *bitReader) read(nbits int) (uint64, error) {
+	if nbits > 64 {
+		return 0, fmt.Errorf("cannot read %d bits number", nbits)
+	}
+	if r.pos+nbits > len(r.data)*8 {
+		return 0, errTruncated
+	}
+	var val uint64
+	for i := 0; i < nbits; i++ {
+		bit := r.data[(r.pos+i)/8] >> (7 - uint((r.pos+i)%8)) & 1
+		val = val<<1 | uint64(bit)
+	}
+	r.pos += nbits
+	return val, nil
+}
+
+func (r *bitReader) readText(nbits int) (string, error) {
+	buf := make([]byte, nbits/8)
+	for i := range buf {
+		ch, err := r.read(8)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = byte(ch)
+	}
+	if _, err := r.read(nbits % 8); err != nil {
+		return "", err
+	}
+	missing := true
+	for _, ch := range buf {
+		if ch != 0xff {
+			missing = false
+			break
+		}
+	}
+	if missing {
+		// all bits set to 1 means missing
+		return "", nil
+	}
+	return strings.TrimSpace(strings.Trim(string(buf), "\x00")), nil
+}
+
+func allOnes(val uint64, nbits int) bool {
+	if nbits == 0 || nbits >= 64 {
+		return false
+	}
+	return val == 1<<uint(nbits)-1
+}
+
+// decoder keeps the state of the decoding
+// of section 4 of a message. When data
+// is not compressed, a single subset at a time
+// is decoded.
+type decoder struct {
+	tables     *Tables
+	r          *bitReader
+	compressed bool
+	subsets    []Subset
+
+	// state changed by table C operators
+	widthDelta    int
+	scaleDelta    int
+	increaseScale int
+	textWidth     int
+	localWidth    int
+}
+
+func (dec *decoder) resetOperators() {
+	dec.widthDelta = 0
+	dec.scaleDelta = 0
+	dec.increaseScale = 0
+	dec.textWidth = 0
+	dec.localWidth = 0
+}
+
+// expand decodes values of descs,
+// expanding replications and sequences.
+func (dec *decoder) expand(descs []Descriptor) error {
+	for i := 0; i < len(descs); i++ {
+		desc := descs[i]
+		switch desc.F() {
+		case 0:
+			if _, err := dec.element(desc); err != nil {
+				return err
+			}
+
+		case 1:
+			count := desc.Y()
+			start := i + 1
+			if count == 0 {
+				if start >= len(descs) {
+					return fmt.Errorf("missing delayed replication factor after %s", desc)
+				}
+				factor := descs[start]
+				if factor.F() != 0 || factor.X() != 31 {
+					return fmt.Errorf("invalid delayed replication factor %s", factor)
+				}
+				if factor.Y() == 11 || factor.Y() == 12 {
+					return fmt.Errorf("unsupported delayed repetition factor %s", factor)
+				}
+				val, err := dec.element(factor)
+				if err != nil {
+					return err
+				}
+				count = int(val)
+				start++
+			}
+			end := start + desc.X()
+			if end > len(descs) {
+				return fmt.Errorf("replication %s exceeds descriptors", desc)
+			}
+			for n := 0; n < count; n++ {
+				if err := dec.expand(descs[start:end]); err != nil {
+					return err
+				}
+			}
+			i = end - 1
+
+		case 2:
+			if err := dec.operator(desc); err != nil {
+				return err
+			}
+
+		case 3:
+			seq, ok := dec.tables.D[desc]
+			if !ok {
+				return fmt.Errorf("unknown sequence descriptor %s", desc)
+			}
+			if err := dec.expand(seq); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// operator applies the table C operator desc.
+func (dec *decoder) operator(desc Descriptor) error {
+	y := desc.Y()
+	switch desc.X() {
+	case 1:
+		dec.widthDelta = 0
+		if y != 0 {
+			dec.widthDelta = y - 128
+		}
+	case 2:
+		dec.scaleDelta = 0
+		if y != 0 {
+			dec.scaleDelta = y - 128
+		}
+	case 5:
+		// y characters inserted in data
+		return dec.text(Element{Descriptor: desc, Unit: "CCITT IA5", Width: y * 8})
+	case 6:
+		dec.localWidth = y
+	case 7:
+		dec.increaseScale = y
+	case 8:
+		dec.textWidth = y * 8
+	case 23, 24, 25, 32:
+		if y == 255 {
+			// marker operators: the values they
+			// stand for are not decoded.
+			return fmt.Errorf("unsupported operator %s", desc)
+		}
+		// bitmap definitions: following elements are
+		// decoded as they are, with their own descriptors.
+	case 22, 35, 36, 37:
+		// quality information, bitmaps and their
+		// cancellation: following elements are decoded
+		// as they are, with their own descriptors.
+	default:
+		return fmt.Errorf("unsupported operator %s", desc)
+	}
+	return nil
+}
+
+// element decodes a table B element and appends it
+// to the subsets, returning its value in the first subset.
+func (dec *decoder) element(desc Descriptor) (float64, error) {
+	el, ok := dec.tables.B[desc]
+	if !ok {
+		if dec.localWidth == 0 {
+			return 0, fmt.Errorf("unknown element descriptor %s", desc)
+		}
+		// element of unknown local descriptor
+		// with width declared by operator 206
+		el = Element{Descriptor: desc, Width: dec.localWidth}
+		dec.localWidth = 0
+		err := dec.number(el)
+		return 0, err
+	}
+
+	if el.IsText() {
+		if dec.textWidth != 0 {
+			el.Width = dec.textWidth
+		}
+		return 0, dec.text(el)
+	}
+
+	if !el.IsCodeOrFlag() {
+		el.Width += dec.widthDelta
+		el.Scale += dec.scaleDelta
+		if dec.increaseScale != 0 {
+			el.Scale += dec.increaseScale
+			el.Reference *= int64(math.Pow10(dec.increaseScale))
+			el.Width += (10*dec.increaseScale + 2) / 3
+		}
+	}
+
+	if err := dec.number(el); err != nil {
+		return 0, err
+	}
+	first := dec.subsets[0]
+	return first[len(first)-1].Number, nil
+}
+
+// number decodes a numeric element.
+func (dec *decoder) number(el Element) error {
+	scale := math.Pow10(-el.Scale)
+	value := func(raw uint64) float64 {
+		return float64(int64(raw)+el.Reference) * scale
+	}
+	// 1 bit elements and delayed replication
+	// factors have no missing value.
+	canBeMissing := el.Width > 1 && el.Descriptor.X() != 31
+
+	raw, err := dec.r.read(el.Width)
+	if err != nil {
+		return err
+	}
+
+	if !dec.compressed {
+		v := Value{Element: el, Number: value(raw)}
+		if canBeMissing && allOnes(raw, el.Width) {
+			v.Number = math.NaN()
+		}
+		dec.subsets[0] = append(dec.subsets[0], v)
+		return nil
+	}
+
+	nbinc, err := dec.r.read(6)
+	if err != nil {
+		return err
+	}
+	for i := range dec.subsets {
+		v := Value{Element: el}
+		if nbinc == 0 {
+			v.Number = value(raw)
+			if canBeMissing && allOnes(raw, el.Width) {
+				v.Number = math.NaN()
+			}
+		} else {
+			inc, err := dec.r.read(int(nbinc))
+			if err != nil {
+				return err
+			}
+			v.Number = value(raw + inc)
+			if canBeMissing && allOnes(inc, int(nbinc)) {
+				v.Number = math.NaN()
+			}
+		}
+		dec.subsets[i] = append(dec.subsets[i], v)
+	}
+	return nil
+}
+
+// text decodes a CCITT IA5 element.
+func (dec *decoder) text(el Element) error {
+	raw, err := dec.r.readText(el.Width)
+	if err != nil {
+		return err
+	}
+
+	if !dec.compressed {
+		dec.subsets[0] = append(dec.subsets[0], Value{Element: el, Text: raw, Number: math.NaN()})
+		return nil
+	}
+
+	nbinc, err := dec.r.read(6)
+	if err != nil {
+		return err
+	}
+	for i := range dec.subsets {
+		v := Value{Element: el, Text: raw, Number: math.NaN()}
+		if nbinc != 0 {
+			if v.Text, err = dec.r.readText(int(nbinc) * 8); err != nil {
+				return err
+			}
+		}
+		dec.subsets[i] = append(dec.subsets[i], v)
+	}
+	return nil
+}
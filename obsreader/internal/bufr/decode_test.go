@@ -0,0 +1,219 @@
+package bufr
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testTableB = `ClassNo,ClassName_en,FXY,ElementName_en,BUFR_Unit,BUFR_Scale,BUFR_ReferenceValue,BUFR_DataWidth_Bits,Status
+01,Identification,001001,WMO block number,Numeric,0,0,7,Operational
+01,Identification,001002,WMO station number,Numeric,0,0,10,Operational
+01,Identification,001015,Station or site name,CCITT IA5,0,0,160,Operational
+05,Location (horizontal - 1),005001,Latitude (high accuracy),deg,5,-9000000,25,Operational
+06,Location (horizontal - 2),006001,Longitude (high accuracy),deg,5,-18000000,26,Operational
+12,Temperature,012101,Temperature/air temperature,K,2,0,16,Operational
+31,Data description operator qualifiers,031001,Delayed descriptor replication factor,Numeric,0,0,8,Operational
+`
+
+var testTableD = `Category,CategoryOfSequences_en,FXY1,Title_en,FXY2,ElementName_en,Status
+07,Surface report sequences (land),307250,Test sequence,001001,WMO block number,Operational
+07,Surface report sequences (land),307250,Test sequence,001002,WMO station number,Operational
+07,Surface report sequences (land),307250,Test sequence,001015,Station or site name,Operational
+07,Surface report sequences (land),307250,Test sequence,005001,Latitude (high accuracy),Operational
+07,Surface report sequences (land),307250,Test sequence,006001,Longitude (high accuracy),Operational
+07,Surface report sequences (land),307250,Test sequence,101000,,Operational
+07,Surface report sequences (land),307250,Test sequence,031001,Delayed descriptor replication factor,Operational
+07,Surface report sequences (land),307250,Test sequence,012101,Temperature/air temperature,Operational
+`
+
+func testTables(t *testing.T) *Tables {
+	tables := &Tables{
+		B: map[Descriptor]Element{},
+		D: map[Descriptor][]Descriptor{},
+	}
+	assert.NoError(t, tables.ReadTableB(strings.NewReader(testTableB)))
+	assert.NoError(t, tables.ReadTableD(strings.NewReader(testTableD)))
+	return tables
+}
+
+type bitWriter struct {
+	buf   []byte
+	nbits int
+}
+
+func (w *bitWriter) write(val uint64, nbits int) {
+	for i := nbits - 1; i >= 0; i-- {
+		if w.nbits%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		bit := byte(val>>uint(i)) & 1
+		w.buf[len(w.buf)-1] |= bit << (7 - uint(w.nbits%8))
+		w.nbits++
+	}
+}
+
+func (w *bitWriter) text(s string, nbytes int) {
+	for i := 0; i < nbytes; i++ {
+		ch := byte(' ')
+		if i < len(s) {
+			ch = s[i]
+		}
+		w.write(uint64(ch), 8)
+	}
+}
+
+func uint24Bytes(n int) []byte {
+	return []byte{byte(n >> 16), byte(n >> 8), byte(n)}
+}
+
+// testMessage encodes an edition 4 BUFR message
+// with descriptor 307250 and given data section.
+func testMessage(nsubsets int, compressed bool, data []byte) []byte {
+	sec1 := append(uint24Bytes(22),
+		0, 0, 80, 0, 0, 0, 0, 0, 0, 0, 30, 0, 0x07, 0xe5, 3, 14, 12, 0, 0,
+	)
+	flags := byte(0x80)
+	if compressed {
+		flags |= 0x40
+	}
+	sec3 := append(uint24Bytes(10), 0, byte(nsubsets>>8), byte(nsubsets), flags, 3<<6|7, 250, 0)
+	if len(data)%2 != 0 {
+		data = append(data, 0)
+	}
+	sec4 := append(append(uint24Bytes(len(data)+4), 0), data...)
+
+	body := append(append(append(sec1, sec3...), sec4...), []byte("7777")...)
+	msg := append([]byte("BUFR"), uint24Bytes(len(body)+8)...)
+	msg = append(msg, 4)
+	return append(msg, body...)
+}
+
+func latRaw(lat float64) uint64 {
+	return uint64(math.Round(lat*1e5) + 9000000)
+}
+
+func lonRaw(lon float64) uint64 {
+	return uint64(math.Round(lon*1e5) + 18000000)
+}
+
+func checkTestSubsets(t *testing.T, msg *Message) {
+	assert.Equal(t, 4, msg.Edition)
+	assert.Equal(t, time.Date(2021, 3, 14, 12, 0, 0, 0, time.UTC), msg.Time)
+	assert.Equal(t, []Descriptor{307250}, msg.Descriptors)
+	assert.Equal(t, 2, len(msg.Subsets))
+
+	name, ok := msg.Subsets[0].First(1015)
+	assert.True(t, ok)
+	assert.Equal(t, "ROMA", name.Text)
+	station, _ := msg.Subsets[0].First(1002)
+	assert.Equal(t, 242.0, station.Number)
+	lat, _ := msg.Subsets[0].First(5001)
+	assert.InDelta(t, 41.8, lat.Number, 1e-6)
+	lon, _ := msg.Subsets[0].First(6001)
+	assert.InDelta(t, 12.583, lon.Number, 1e-6)
+	temp, ok := msg.Subsets[0].First(12101)
+	assert.True(t, ok)
+	assert.InDelta(t, 285.65, temp.Number, 1e-6)
+
+	name, _ = msg.Subsets[1].First(1015)
+	assert.Equal(t, "MILANO", name.Text)
+	station, _ = msg.Subsets[1].First(1002)
+	assert.Equal(t, 80.0, station.Number)
+	_, ok = msg.Subsets[1].First(12101)
+	assert.False(t, ok)
+}
+
+func TestDecodeUncompressed(t *testing.T) {
+	w := &bitWriter{}
+	// first subset
+	w.write(16, 7)
+	w.write(242, 10)
+	w.text("ROMA", 20)
+	w.write(latRaw(41.8), 25)
+	w.write(lonRaw(12.583), 26)
+	w.write(1, 8)
+	w.write(28565, 16)
+	// second subset, with no temperature
+	w.write(16, 7)
+	w.write(80, 10)
+	w.text("MILANO", 20)
+	w.write(latRaw(45.433), 25)
+	w.write(lonRaw(9.283), 26)
+	w.write(0, 8)
+
+	data := append([]byte("SMIT01 LIIB 141200\r\r\n"), testMessage(2, false, w.buf)...)
+	messages, err := DecodeAll(data, testTables(t))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(messages))
+	checkTestSubsets(t, messages[0])
+}
+
+func TestDecodeCompressed(t *testing.T) {
+	w := &bitWriter{}
+	// block number, same for all subsets
+	w.write(16, 7)
+	w.write(0, 6)
+	// station number
+	w.write(80, 10)
+	w.write(8, 6)
+	w.write(162, 8)
+	w.write(0, 8)
+	// station name
+	w.write(0, 160)
+	w.write(20, 6)
+	w.text("ROMA", 20)
+	w.text("MILANO", 20)
+	// latitude
+	w.write(latRaw(41.8), 25)
+	w.write(20, 6)
+	w.write(0, 20)
+	w.write(latRaw(45.433)-latRaw(41.8), 20)
+	// longitude
+	w.write(lonRaw(9.283), 26)
+	w.write(20, 6)
+	w.write(lonRaw(12.583)-lonRaw(9.283), 20)
+	w.write(0, 20)
+	// replication factor
+	w.write(1, 8)
+	w.write(0, 6)
+	// temperature, missing on second subset
+	w.write(28565, 16)
+	w.write(4, 6)
+	w.write(0, 4)
+	w.write(15, 4)
+
+	msg, err := Decode(testMessage(2, true, w.buf), testTables(t))
+	assert.NoError(t, err)
+	checkTestSubsets(t, msg)
+}
+
+func TestDecodeUnknownDescriptor(t *testing.T) {
+	tables := testTables(t)
+	delete(tables.B, 12101)
+
+	w := &bitWriter{}
+	w.write(16, 7)
+	w.write(242, 10)
+	w.text("ROMA", 20)
+	w.write(latRaw(41.8), 25)
+	w.write(lonRaw(12.583), 26)
+	w.write(1, 8)
+	w.write(28565, 16)
+
+	_, err := Decode(testMessage(1, false, w.buf), tables)
+	assert.EqualError(t, err, "subset 0: unknown element descriptor 012101")
+}
+
+func TestDecodeOperators(t *testing.T) {
+	dec := &decoder{}
+	for _, desc := range []Descriptor{222000, 223000, 224000, 225000, 232000, 235000, 236000, 237000, 237255} {
+		assert.NoError(t, dec.operator(desc))
+	}
+	for _, desc := range []Descriptor{223255, 224255, 225255, 232255} {
+		assert.EqualError(t, dec.operator(desc), "unsupported operator "+desc.String())
+	}
+}
@@ -0,0 +1,247 @@
+package bufr
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Descriptor is a BUFR FXY descriptor, represented
+// with its six decimal digits (e.g. 307080).
+type Descriptor int
+
+// NewDescriptor returns the Descriptor
+// with given F, X and Y parts.
+func NewDescriptor(f, x, y int) Descriptor {
+	return Descriptor(f*100000 + x*1000 + y)
+}
+
+// ParseDescriptor parses a descriptor
+// written as six digits (e.g. "012101").
+func ParseDescriptor(s string) (Descriptor, error) {
+	s = strings.TrimSpace(s)
+	if len(s) != 6 {
+		return 0, fmt.Errorf("invalid descriptor `%s`", s)
+	}
+	val, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid descriptor `%s`: %w", s, err)
+	}
+	return Descriptor(val), nil
+}
+
+// F returns the F part of the descriptor.
+func (d Descriptor) F() int {
+	return int(d) / 100000
+}
+
+// X returns the X part of the descriptor.
+func (d Descriptor) X() int {
+	return int(d) / 1000 % 100
+}
+
+// Y returns the Y part of the descriptor.
+func (d Descriptor) Y() int {
+	return int(d) % 1000
+}
+
+// String implements fmt.Stringer for Descriptor
+func (d Descriptor) String() string {
+	return fmt.Sprintf("%06d", int(d))
+}
+
+// Element is an entry of BUFR table B.
+type Element struct {
+	Descriptor Descriptor
+	Name       string
+	Unit       string
+	Scale      int
+	Reference  int64
+	Width      int
+}
+
+// IsText returns whether the element
+// contains characters instead of numbers.
+func (el Element) IsText() bool {
+	return el.Unit == "CCITT IA5" || el.Unit == "CCITT_IA5"
+}
+
+// IsCodeOrFlag returns whether the element
+// value is a code or flag table entry.
+func (el Element) IsCodeOrFlag() bool {
+	unit := strings.ToLower(el.Unit)
+	return strings.Contains(unit, "code table") || strings.Contains(unit, "flag table")
+}
+
+// Tables contains BUFR table B and table D
+// entries used to decode messages.
+type Tables struct {
+	B map[Descriptor]Element
+	D map[Descriptor][]Descriptor
+}
+
+// LoadTables reads all table B and table D
+// files contained in dir. Files are recognized
+// by name, containing `TableB` or `TableD`, and
+// must be in the CSV format distributed by WMO
+// (e.g. BUFRCREX_TableB_en.csv and BUFR_TableD_en.csv).
+// Local tables could be placed in the same
+// directory, following the same format.
+func LoadTables(dir string) (*Tables, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := &Tables{
+		B: map[Descriptor]Element{},
+		D: map[Descriptor][]Descriptor{},
+	}
+
+	for _, f := range files {
+		name := strings.ToLower(f.Name())
+		var loader func(io.Reader) error
+		if strings.Contains(name, "tableb") {
+			loader = tables.ReadTableB
+		} else if strings.Contains(name, "tabled") {
+			loader = tables.ReadTableD
+		} else {
+			continue
+		}
+
+		err = loadTableFile(filepath.Join(dir, f.Name()), loader)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(tables.B) == 0 {
+		return nil, fmt.Errorf("no BUFR table B found in %s", dir)
+	}
+
+	return tables, nil
+}
+
+func loadTableFile(file string, loader func(io.Reader) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = loader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+// readCSVTable reads a CSV table with an header
+// line, and returns its records together with
+// a function that returns the value of a
+// column of a record by column name.
+func readCSVTable(r io.Reader, columns ...string) ([][]string, func(record []string, column string) string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("empty table")
+	}
+
+	colIdx := map[string]int{}
+	for idx, name := range records[0] {
+		colIdx[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = idx
+	}
+	for _, col := range columns {
+		if _, ok := colIdx[col]; !ok {
+			return nil, nil, fmt.Errorf("missing column %s", col)
+		}
+	}
+
+	get := func(record []string, column string) string {
+		idx := colIdx[column]
+		if idx >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[idx])
+	}
+	return records[1:], get, nil
+}
+
+// ReadTableB adds to the tables all
+// entries of a table B read from r.
+func (tables *Tables) ReadTableB(r io.Reader) error {
+	records, get, err := readCSVTable(r,
+		"FXY", "ElementName_en", "BUFR_Unit", "BUFR_Scale",
+		"BUFR_ReferenceValue", "BUFR_DataWidth_Bits",
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		desc, err := ParseDescriptor(get(record, "FXY"))
+		if err != nil {
+			return err
+		}
+		el := Element{
+			Descriptor: desc,
+			Name:       get(record, "ElementName_en"),
+			Unit:       get(record, "BUFR_Unit"),
+		}
+		if el.Scale, err = strconv.Atoi(get(record, "BUFR_Scale")); err != nil {
+			return fmt.Errorf("descriptor %s: invalid scale: %w", desc, err)
+		}
+		if el.Reference, err = strconv.ParseInt(get(record, "BUFR_ReferenceValue"), 10, 64); err != nil {
+			return fmt.Errorf("descriptor %s: invalid reference value: %w", desc, err)
+		}
+		if el.Width, err = strconv.Atoi(get(record, "BUFR_DataWidth_Bits")); err != nil {
+			return fmt.Errorf("descriptor %s: invalid data width: %w", desc, err)
+		}
+		tables.B[desc] = el
+	}
+
+	return nil
+}
+
+// ReadTableD adds to the tables all
+// entries of a table D read from r.
+// Each record contains a sequence descriptor
+// and one of the descriptors it expands to.
+func (tables *Tables) ReadTableD(r io.Reader) error {
+	records, get, err := readCSVTable(r, "FXY1", "FXY2")
+	if err != nil {
+		return err
+	}
+
+	loaded := map[Descriptor]bool{}
+	for _, record := range records {
+		seq, err := ParseDescriptor(get(record, "FXY1"))
+		if err != nil {
+			return err
+		}
+		desc, err := ParseDescriptor(get(record, "FXY2"))
+		if err != nil {
+			return err
+		}
+		if !loaded[seq] {
+			// a sequence redefined in a following
+			// table replaces the previous one.
+			tables.D[seq] = nil
+			loaded[seq] = true
+		}
+		tables.D[seq] = append(tables.D[seq], desc)
+	}
+
+	return nil
+}
@@ -0,0 +1,304 @@
+package obsreader
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// LittleRObsReader reads observations from files
+// in LITTLE_R format, as read by WRFDA obsproc.
+// Each report is made of a header record, one data
+// record for every level, an end record and a tail record.
+type LittleRObsReader struct {
+	// ElevationSource tells where the
+	// elevation of stations is taken from.
+	ElevationSource ElevationSource
+}
+
+// littleRLevel contains values of a LITTLE_R
+// data record, in Pa, m, °K, m/s, degrees and %.
+type littleRLevel struct {
+	Pressure, Height, Temperature, Dewpoint float64
+	Speed, Direction, RelativeHumidity      float64
+}
+
+// littleRReport is a report read from
+// a LITTLE_R file.
+type littleRReport struct {
+	Lat, Lon         float64
+	ID, Name         string
+	Platform         string
+	Elevation        float64
+	IsSounding       bool
+	Date             time.Time
+	SeaLevelPressure float64
+	SurfacePressure  float64
+	Precipitation    float64
+	Levels           []littleRLevel
+}
+
+// missing and end of data values used by LITTLE_R
+const (
+	littleRMissing = -888888.0
+	littleREnd     = -777777.0
+)
+
+// littleRField returns the columns from start
+// to end of line, without surrounding spaces.
+func littleRField(line string, start, end int) string {
+	if start >= len(line) {
+		return ""
+	}
+	if end > len(line) {
+		end = len(line)
+	}
+	return strings.TrimSpace(line[start:end])
+}
+
+// littleRNumber parses a F20.5 or F13.5 field,
+// returning NaN for missing values.
+func littleRNumber(line string, start, end int) (float64, error) {
+	s := littleRField(line, start, end)
+	if s == "" {
+		return math.NaN(), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN(), err
+	}
+	if f == littleRMissing {
+		return math.NaN(), nil
+	}
+	return f, nil
+}
+
+// parseLittleRHeader parses the 600 columns header record.
+func parseLittleRHeader(line string) (littleRReport, error) {
+	var r littleRReport
+	var err error
+	if r.Lat, err = littleRNumber(line, 0, 20); err != nil {
+		return r, err
+	}
+	if r.Lon, err = littleRNumber(line, 20, 40); err != nil {
+		return r, err
+	}
+	r.ID = littleRField(line, 40, 80)
+	r.Name = littleRField(line, 80, 120)
+	r.Platform = littleRField(line, 120, 160)
+	if r.Elevation, err = littleRNumber(line, 200, 220); err != nil {
+		return r, err
+	}
+	r.IsSounding = littleRField(line, 270, 280) == "T"
+	if r.Date, err = time.Parse("20060102150405", littleRField(line, 320, 340)); err != nil {
+		return r, err
+	}
+	// header values are 13 (F13.5,I7) pairs starting at
+	// column 340: slp, ref_pres, ground_t, sst, psfc, precip...
+	if r.SeaLevelPressure, err = littleRNumber(line, 340, 353); err != nil {
+		return r, err
+	}
+	if r.SurfacePressure, err = littleRNumber(line, 420, 433); err != nil {
+		return r, err
+	}
+	if r.Precipitation, err = littleRNumber(line, 440, 453); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// parseLittleRData parses a data record. It returns
+// false when the record is the end record.
+func parseLittleRData(line string) (littleRLevel, bool, error) {
+	var l littleRLevel
+	fields := []*float64{
+		&l.Pressure, &l.Height, &l.Temperature, &l.Dewpoint,
+		&l.Speed, &l.Direction, nil, nil, &l.RelativeHumidity,
+	}
+	for i, f := range fields {
+		s := littleRField(line, i*20, i*20+13)
+		if i < 2 && s != "" {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return l, false, err
+			}
+			if v == littleREnd {
+				return l, false, nil
+			}
+		}
+		if f == nil {
+			continue
+		}
+		v, err := littleRNumber(line, i*20, i*20+13)
+		if err != nil {
+			return l, false, err
+		}
+		*f = v
+	}
+	return l, true, nil
+}
+
+// readLittleR parses all reports contained in file.
+func readLittleR(file string) ([]littleRReport, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 1024), 1024*1024)
+	lineNo := 0
+	next := func() (string, bool) {
+		for scanner.Scan() {
+			lineNo++
+			line := strings.TrimRight(scanner.Text(), "\r")
+			if strings.TrimSpace(line) != "" {
+				return line, true
+			}
+		}
+		return "", false
+	}
+
+	reports := []littleRReport{}
+	for {
+		line, ok := next()
+		if !ok {
+			break
+		}
+		report, err := parseLittleRHeader(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, lineNo, err)
+		}
+		for {
+			line, ok = next()
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: report truncated", file, lineNo)
+			}
+			level, ok, err := parseLittleRData(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", file, lineNo, err)
+			}
+			if !ok {
+				break
+			}
+			report.Levels = append(report.Levels, level)
+		}
+		// tail record
+		if _, ok = next(); !ok {
+			return nil, fmt.Errorf("%s:%d: report truncated", file, lineNo)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, scanner.Err()
+}
+
+// surface returns the surface level of the report: the
+// first level for surface reports, or the one at station
+// elevation or pressure for soundings.
+func (r littleRReport) surface() (littleRLevel, bool) {
+	if len(r.Levels) == 0 {
+		return littleRLevel{}, false
+	}
+	if !r.IsSounding {
+		return r.Levels[0], true
+	}
+	for _, l := range r.Levels {
+		if l.Height == r.Elevation || l.Pressure == r.SurfacePressure {
+			return l, true
+		}
+	}
+	return littleRLevel{}, false
+}
+
+// observation converts the report into a types.Observation.
+// Soundings fill the Levels of the observation. It returns
+// false if the report has no usable level.
+func (r littleRReport) observation() (types.Observation, bool) {
+	obs := missingObservation(r.Platform)
+	obs.Group = types.WMOStations
+	obs.StationID = r.ID
+	obs.StationName = r.Name
+	if obs.StationName == "" {
+		obs.StationName = r.ID
+	}
+	obs.Lat = r.Lat
+	obs.Lon = r.Lon
+	obs.Elevation = r.Elevation
+	obs.ObsTimeUtc = r.Date
+
+	if r.IsSounding {
+		for _, l := range r.Levels {
+			obs.Levels = append(obs.Levels, types.Level{
+				Pressure:     types.Value(l.Pressure),
+				Height:       types.Value(l.Height),
+				TempAvg:      types.Value(l.Temperature),
+				DewptAvg:     types.Value(l.Dewpoint),
+				WindspeedAvg: types.Value(l.Speed),
+				WinddirAvg:   types.Value(l.Direction),
+				HumidityAvg:  types.Value(l.RelativeHumidity),
+			})
+		}
+	}
+
+	level, ok := r.surface()
+	if math.IsNaN(r.Lat) || math.IsNaN(r.Lon) {
+		return obs, false
+	}
+	if !ok {
+		return obs, len(obs.Levels) > 0
+	}
+
+	// LITTLE_R values are already in °K, m/s and Pa
+	obs.Metric.Pressure = types.Value(level.Pressure)
+	if obs.Metric.Pressure.IsNaN() {
+		obs.Metric.Pressure = types.Value(r.SurfacePressure)
+	}
+	obs.Metric.SeaLevelPressure = types.Value(r.SeaLevelPressure)
+	obs.Metric.TempAvg = types.Value(level.Temperature)
+	obs.Metric.DewptAvg = types.Value(level.Dewpoint)
+	obs.Metric.WindspeedAvg = types.Value(level.Speed)
+	obs.Metric.PrecipTotal = types.Value(r.Precipitation)
+	obs.WinddirAvg = types.Value(level.Direction)
+	obs.HumidityAvg = types.Value(level.RelativeHumidity)
+
+	return obs, true
+}
+
+// ReadAll implements ObsReader for LittleRObsReader.
+// dataPath could be a single file or a directory
+// containing many of them. For each station, only the
+// report closest to date within 30 minutes is returned.
+func (r LittleRObsReader) ReadAll(dataPath string, domain types.Region, date time.Time) ([]types.Observation, error) {
+	files, err := inputFiles(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	observations := []types.Observation{}
+	for _, file := range files {
+		reports, err := readLittleR(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, report := range reports {
+			obs, ok := report.observation()
+			if !ok {
+				continue
+			}
+			if domain.Contains(obs.Lat, obs.Lon) {
+				obs.Elevation = r.ElevationSource.stationElevation(obs.StationID, obs.Elevation, obs.Lat, obs.Lon)
+				observations = append(observations, obs)
+			}
+		}
+	}
+
+	return closestToDate(observations, date, 30*time.Minute), nil
+}
@@ -0,0 +1,109 @@
+package obsreader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func littleRHeader(lat, lon float64, id, name, platform string, elevation float64, sounding string, date string, values ...float64) string {
+	header := fmt.Sprintf("%20.5f%20.5f%-40s%-40s%-40s%-40s%20.5f%10d%10d%10d%10d%10d%10s%10s%10s%10d%10d%20s",
+		lat, lon, id, name, platform, "GTS", elevation, 7, 0, 0, 1, 0, sounding, "F", "F", -888888, -888888, date)
+	for i := 0; i < 13; i++ {
+		v := -888888.0
+		if i < len(values) {
+			v = values[i]
+		}
+		header += fmt.Sprintf("%13.5f%7d", v, 0)
+	}
+	return header
+}
+
+func littleRData(values ...float64) string {
+	data := ""
+	for i := 0; i < 10; i++ {
+		v := -888888.0
+		if i < len(values) {
+			v = values[i]
+		}
+		data += fmt.Sprintf("%13.5f%7d", v, 0)
+	}
+	return data
+}
+
+var littleREndRecord = littleRData(-777777, -777777, -888888, -888888, -888888, -888888, -888888, -888888, -888888, -888888)
+
+const littleRTail = "      7      0      0"
+
+func TestLittleR(t *testing.T) {
+	text := strings.Join([]string{
+		littleRHeader(41.8, 12.583, "16242", "ROMA CIAMPINO", "FM-12 SYNOP", 105, "F", "20200330180000", 101320, -888888, -888888, -888888, -888888, 1.5),
+		littleRData(100050, 105, 285.65, 280.15, 5, 270, -888888, -888888, 69),
+		littleREndRecord,
+		littleRTail,
+		// a sounding, whose surface level is the second one
+		littleRHeader(45.433, 9.283, "16080", "MILANO LINATE", "FM-35 TEMP", 103, "T", "20200330180500", -888888, -888888, -888888, -888888, 100400),
+		littleRData(101000, -888888, 283, -888888, -888888, -888888),
+		littleRData(100400, 103, 282.15, 275.15, 2, 90),
+		littleRData(85000, 1500, 275.15, 270.15, 10, 250),
+		littleREndRecord,
+		littleRTail,
+		// outside domain
+		littleRHeader(48.85, 2.35, "07150", "PARIS", "FM-12 SYNOP", 70, "F", "20200330180000"),
+		littleRData(100000, 70, 280),
+		littleREndRecord,
+		littleRTail,
+	}, "\n") + "\n"
+
+	file := filepath.Join(t.TempDir(), "obs.littler")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(text), 0644))
+
+	italy := types.Domain{MinLat: 36, MaxLat: 47, MinLon: 6, MaxLon: 19}
+	observations, err := LittleRObsReader{}.ReadAll(file, italy, time.Date(2020, 3, 30, 18, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(observations))
+
+	obs := observations[0]
+	assert.Equal(t, "16242", obs.StationID)
+	assert.Equal(t, "ROMA CIAMPINO", obs.StationName)
+	assert.Equal(t, types.PlatformSynop, obs.PlatformType())
+	assert.Equal(t, 105.0, obs.Elevation)
+	assert.Equal(t, time.Date(2020, 3, 30, 18, 0, 0, 0, time.UTC), obs.ObsTimeUtc)
+	assert.Equal(t, types.Value(100050), obs.Metric.Pressure)
+	assert.Equal(t, types.Value(101320), obs.Metric.SeaLevelPressure)
+	assert.Equal(t, types.Value(285.65), obs.Metric.TempAvg)
+	assert.Equal(t, types.Value(280.15), obs.Metric.DewptAvg)
+	assert.Equal(t, types.Value(5), obs.Metric.WindspeedAvg)
+	assert.Equal(t, types.Value(270), obs.WinddirAvg)
+	assert.Equal(t, types.Value(69), obs.HumidityAvg)
+	assert.Equal(t, types.Value(1.5), obs.Metric.PrecipTotal)
+
+	obs = observations[1]
+	assert.Equal(t, "16080", obs.StationID)
+	assert.Equal(t, "FM-35 TEMP", obs.PlatformType())
+	assert.Equal(t, types.Value(100400), obs.Metric.Pressure)
+	assert.Equal(t, types.Value(282.15), obs.Metric.TempAvg)
+	assert.True(t, obs.Metric.SeaLevelPressure.IsNaN())
+	assert.True(t, obs.HumidityAvg.IsNaN())
+	assert.Equal(t, 3, len(obs.Levels))
+	assert.Equal(t, types.Value(85000), obs.Levels[2].Pressure)
+	assert.Equal(t, types.Value(1500), obs.Levels[2].Height)
+	assert.Equal(t, types.Value(250), obs.Levels[2].WinddirAvg)
+	assert.Empty(t, observations[0].Levels)
+}
+
+func TestLittleRTruncated(t *testing.T) {
+	text := littleRHeader(41.8, 12.583, "16242", "ROMA", "FM-12 SYNOP", 105, "F", "20200330180000") + "\n" +
+		littleRData(100050, 105, 285.65) + "\n"
+	file := filepath.Join(t.TempDir(), "obs.littler")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(text), 0644))
+
+	_, err := LittleRObsReader{}.ReadAll(file, allDomain, time.Time{})
+	assert.EqualError(t, err, file+":2: report truncated")
+}
@@ -0,0 +1,270 @@
+package obsreader
+
+import (
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/pressure"
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/meteocima/dewetra2wrf/units"
+)
+
+// MetarObsReader reads observations from
+// text files containing raw METAR/SPECI reports,
+// one report per line or grouped in WMO bulletins.
+// Coordinates of the stations are read from the
+// CSV table at StationsFile, or from a bundled
+// table of italian airports if StationsFile is empty.
+type MetarObsReader struct {
+	StationsFile string
+	// ElevationSource tells where the
+	// elevation of stations is taken from.
+	ElevationSource ElevationSource
+}
+
+// ReadAll implements ObsReader for MetarObsReader.
+// dataPath could be a single file or a directory
+// containing many of them. For each station, only the
+// report closest to date within 30 minutes is returned.
+// Malformed reports and reports from stations missing
+// from the stations table are skipped.
+func (r MetarObsReader) ReadAll(dataPath string, domain types.Region, date time.Time) ([]types.Observation, error) {
+	stations, err := openStationsTable(r.StationsFile, icaoStations)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := inputFiles(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	refDate := date
+	if refDate.IsZero() {
+		refDate = time.Now().UTC()
+	}
+
+	observations := []types.Observation{}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, report := range splitMetarReports(string(content)) {
+			obs, err := parseMetar(report, refDate)
+			if err != nil {
+				continue
+			}
+			if stations.locate(&obs, domain) {
+				obs.Elevation = r.ElevationSource.stationElevation(obs.StationID, obs.Elevation, obs.Lat, obs.Lon)
+				observations = append(observations, obs)
+			}
+		}
+	}
+
+	return closestToDate(observations, date, 30*time.Minute), nil
+}
+
+var metarStartRe = regexp.MustCompile(`^((METAR|SPECI) )?(COR )?[A-Z][A-Z0-9]{3} \d{6}Z`)
+
+// splitMetarReports splits text into single
+// METAR reports, discarding WMO bulletin headers
+// and envelopes. Reports could span multiple lines,
+// and are terminated by a '=', an empty line or
+// the start of the following report.
+func splitMetarReports(text string) []string {
+	reports := []string{}
+	current := ""
+
+	closeReport := func() {
+		if current != "" {
+			reports = append(reports, current)
+		}
+		current = ""
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r", ""), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			closeReport()
+			continue
+		}
+
+		if metarStartRe.MatchString(line) {
+			closeReport()
+			current = line
+		} else if current != "" {
+			current += " " + line
+		} else {
+			continue
+		}
+
+		if idx := strings.Index(current, "="); idx != -1 {
+			current = strings.TrimSpace(current[:idx])
+			closeReport()
+		}
+	}
+	closeReport()
+
+	return reports
+}
+
+// metarUnits are the units of values in METAR reports.
+// Wind speed unit is given in the report itself, and
+// pressure is in inHg for altimeter settings.
+var metarUnits = units.Source{
+	Temperature: units.Celsius,
+	Pressure:    units.Hectopascal,
+}
+
+var (
+	errNilReport = errors.New("NIL report")
+
+	metarTimeRe    = regexp.MustCompile(`^(\d{2})(\d{2})(\d{2})Z$`)
+	metarWindRe    = regexp.MustCompile(`^(\d{3}|VRB)(\d{2,3})(G(\d{2,3}))?(KT|MPS|KMH)$`)
+	metarVisRe     = regexp.MustCompile(`^(\d{4})(NDV)?$`)
+	metarVisSMRe   = regexp.MustCompile(`^(P)?(\d+)SM$`)
+	metarVisFracRe = regexp.MustCompile(`^(\d+)/(\d+)SM$`)
+	metarTempRe    = regexp.MustCompile(`^(M?\d{2})/(M?\d{2})?$`)
+	metarQNHRe     = regexp.MustCompile(`^Q(\d{4})$`)
+	metarAltRe     = regexp.MustCompile(`^A(\d{4})$`)
+)
+
+// metarTime returns the time of a ddhhmmZ group,
+// taking year and month from ref. When the day
+// falls too far from ref, previous or next month
+// is used instead.
+func metarTime(day, hour, minute int, ref time.Time) time.Time {
+	at := time.Date(ref.Year(), ref.Month(), day, hour, minute, 0, 0, time.UTC)
+	if at.Sub(ref) > 15*24*time.Hour {
+		at = time.Date(ref.Year(), ref.Month()-1, day, hour, minute, 0, 0, time.UTC)
+	} else if ref.Sub(at) > 16*24*time.Hour {
+		at = time.Date(ref.Year(), ref.Month()+1, day, hour, minute, 0, 0, time.UTC)
+	}
+	return at
+}
+
+func metarTemperature(s string) float64 {
+	if strings.HasPrefix(s, "M") {
+		val, _ := strconv.ParseFloat(s[1:], 64)
+		return -val
+	}
+	val, _ := strconv.ParseFloat(s, 64)
+	return val
+}
+
+// parseMetar parses a single METAR or SPECI report.
+// Returned observation contains only station
+// identifier, time and measured values; temperatures
+// are in °K, wind speed in m/s, pressure in Pa
+// and visibility in meters. QNH and altimeter setting
+// are reduced to sea level, so they are returned
+// as SeaLevelPressure, with AltimeterSetting set.
+func parseMetar(report string, ref time.Time) (types.Observation, error) {
+	obs := missingObservation(types.PlatformMetar)
+	obs.Group = types.WMOStations
+
+	tokens := strings.Fields(report)
+	for len(tokens) > 0 && (tokens[0] == "METAR" || tokens[0] == "SPECI" || tokens[0] == "COR") {
+		tokens = tokens[1:]
+	}
+	if len(tokens) < 2 {
+		return obs, errors.New("report too short: " + report)
+	}
+
+	obs.StationID = tokens[0]
+	timeGroup := metarTimeRe.FindStringSubmatch(tokens[1])
+	if timeGroup == nil {
+		return obs, errors.New("invalid time group: " + tokens[1])
+	}
+	day, _ := strconv.Atoi(timeGroup[1])
+	hour, _ := strconv.Atoi(timeGroup[2])
+	minute, _ := strconv.Atoi(timeGroup[3])
+	obs.ObsTimeUtc = metarTime(day, hour, minute, ref)
+
+	windFound := false
+	for _, token := range tokens[2:] {
+		if token == "NIL" {
+			return obs, errNilReport
+		}
+		if token == "RMK" || token == "TEMPO" || token == "BECMG" || token == "NOSIG" {
+			// trends and remarks
+			// are not observed values
+			break
+		}
+
+		if token == "CAVOK" {
+			obs.Visibility = 10000
+			continue
+		}
+
+		if m := metarWindRe.FindStringSubmatch(token); m != nil && !windFound {
+			windFound = true
+			speed, _ := strconv.ParseFloat(m[2], 64)
+			unit, err := units.Parse(units.Speed, m[5])
+			if err != nil {
+				return obs, err
+			}
+			obs.Metric.WindspeedAvg = types.Value(unit.ToBase(speed))
+			if m[1] != "VRB" {
+				dir, _ := strconv.ParseFloat(m[1], 64)
+				obs.WinddirAvg = types.Value(dir)
+			}
+			continue
+		}
+
+		if m := metarVisRe.FindStringSubmatch(token); m != nil && obs.Visibility.IsNaN() {
+			vis, _ := strconv.ParseFloat(m[1], 64)
+			if vis == 9999 {
+				// 9999 means 10 km or more
+				vis = 10000
+			}
+			obs.Visibility = types.Value(vis)
+			continue
+		}
+
+		if m := metarVisSMRe.FindStringSubmatch(token); m != nil && obs.Visibility.IsNaN() {
+			miles, _ := strconv.ParseFloat(m[2], 64)
+			obs.Visibility = types.Value(units.Mile.ToBase(miles))
+			continue
+		}
+
+		if m := metarVisFracRe.FindStringSubmatch(token); m != nil && obs.Visibility.IsNaN() {
+			num, _ := strconv.ParseFloat(m[1], 64)
+			den, _ := strconv.ParseFloat(m[2], 64)
+			if den != 0 {
+				obs.Visibility = types.Value(units.Mile.ToBase(num / den))
+			}
+			continue
+		}
+
+		if m := metarTempRe.FindStringSubmatch(token); m != nil {
+			obs.Metric.TempAvg = types.Value(metarUnits.Temperature.ToBase(metarTemperature(m[1])))
+			if m[2] != "" {
+				obs.Metric.DewptAvg = types.Value(metarUnits.Temperature.ToBase(metarTemperature(m[2])))
+			}
+			continue
+		}
+
+		if m := metarQNHRe.FindStringSubmatch(token); m != nil {
+			qnh, _ := strconv.ParseFloat(m[1], 64)
+			setPressure(&obs.Metric, pressure.SeaLevel, types.Value(metarUnits.Pressure.ToBase(qnh)))
+			obs.AltimeterSetting = true
+			continue
+		}
+
+		if m := metarAltRe.FindStringSubmatch(token); m != nil {
+			alt, _ := strconv.ParseFloat(m[1], 64)
+			// altimeter setting is in hundredths of inHg
+			setPressure(&obs.Metric, pressure.SeaLevel, types.Value(units.InchesOfMercury.ToBase(alt/100)))
+			obs.AltimeterSetting = true
+			continue
+		}
+	}
+
+	return obs, nil
+}
@@ -0,0 +1,82 @@
+package obsreader
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var metarBulletin = `ZCZC 123
+SAIT31 LIIB 141200
+METAR
+LIRF 141150Z 24012KT 210V270 9999 FEW030 18/M02 Q1015 NOSIG=
+LIMC 141150Z VRB02KT CAVOK 12/08 Q1018
+     RMK SKC=
+LIML 141150Z NIL=
+NNNN
+`
+
+func TestSplitMetarReports(t *testing.T) {
+	reports := splitMetarReports(metarBulletin)
+	assert.Equal(t, []string{
+		"LIRF 141150Z 24012KT 210V270 9999 FEW030 18/M02 Q1015 NOSIG",
+		"LIMC 141150Z VRB02KT CAVOK 12/08 Q1018 RMK SKC",
+		"LIML 141150Z NIL",
+	}, reports)
+}
+
+func TestParseMetar(t *testing.T) {
+	ref := time.Date(2021, 3, 14, 12, 0, 0, 0, time.UTC)
+	obs, err := parseMetar("METAR LIRF 141150Z 24012G25KT 210V270 4000 -RA 18/M02 Q1015 TEMPO 0800", ref)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "LIRF", obs.StationID)
+	assert.Equal(t, types.PlatformMetar, obs.Platform)
+	assert.Equal(t, time.Date(2021, 3, 14, 11, 50, 0, 0, time.UTC), obs.ObsTimeUtc)
+	assert.Equal(t, types.Value(240), obs.WinddirAvg)
+	assert.InDelta(t, 6.173, obs.Metric.WindspeedAvg.AsFloat(), 0.001)
+	assert.Equal(t, types.Value(4000), obs.Visibility)
+	assert.InDelta(t, 291.15, obs.Metric.TempAvg.AsFloat(), 0.001)
+	assert.InDelta(t, 271.15, obs.Metric.DewptAvg.AsFloat(), 0.001)
+	assert.InDelta(t, 101500, obs.Metric.SeaLevelPressure.AsFloat(), 0.001)
+	assert.True(t, obs.Metric.Pressure.IsNaN())
+	assert.True(t, obs.AltimeterSetting)
+
+	obs, err = parseMetar("KJFK 282351Z 00000KT 1/2SM FG 05/05 A2992", time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, 2, 28, 23, 51, 0, 0, time.UTC), obs.ObsTimeUtc)
+	assert.Equal(t, types.Value(0), obs.WinddirAvg)
+	assert.Equal(t, types.Value(0), obs.Metric.WindspeedAvg)
+	assert.InDelta(t, 804.672, obs.Visibility.AsFloat(), 0.001)
+	assert.InDelta(t, 101320.8, obs.Metric.SeaLevelPressure.AsFloat(), 0.1)
+	assert.True(t, obs.AltimeterSetting)
+
+	_, err = parseMetar("LIML 141150Z NIL", ref)
+	assert.Equal(t, errNilReport, err)
+}
+
+func TestMetarReadAll(t *testing.T) {
+	dir := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(dir, "bulletin.txt"), []byte(metarBulletin), 0644)
+	assert.NoError(t, err)
+
+	date := time.Date(2021, 3, 14, 12, 0, 0, 0, time.UTC)
+	observations, err := MetarObsReader{}.ReadAll(dir, types.Domain{
+		MinLat: 40,
+		MaxLat: 44,
+		MinLon: 10,
+		MaxLon: 14,
+	}, date)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, len(observations))
+	assert.Equal(t, "LIRF", observations[0].StationID)
+	assert.Equal(t, "Roma Fiumicino", observations[0].StationName)
+	assert.Equal(t, 41.8, observations[0].Lat)
+	assert.Equal(t, 12.239, observations[0].Lon)
+	assert.Equal(t, 4.0, observations[0].Elevation)
+}
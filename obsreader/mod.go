@@ -1,10 +1,18 @@
 // Package obsreader contains an `ObsReader` interface
 // for types that can read list of types.Observation.
 //
-// It also contains three implementations of
+// It also contains these implementations of
 // the interface:
 //
 //  * WebdropsObsReader    - reads observations from a set of JSON files that follows the dewetra observations format
 //  * WundCurrentObsReader - reads observations from a set of JSON files as archived from the WSDN CIMA process.
 //  * WundHistObsReader    - reads observations from a set of JSON files as returned from the Wunderground API service.
+//  * MetarObsReader       - reads observations from text files containing METAR/SPECI reports or bulletins.
+//  * SynopObsReader       - reads observations from text files containing FM-12 SYNOP reports or bulletins.
+//  * BufrObsReader        - reads observations from WMO BUFR surface land reports.
+//  * CSVObsReader         - reads observations from CSV files, with columns described by a mapping file.
+//  * NetatmoObsReader     - reads observations from JSON files as returned from the netatmo 'getpublicdata' API.
+//  * WRFASCIIObsReader    - reads observations from files in WRFDA ob.ascii format.
+//  * LittleRObsReader     - reads observations from files in LITTLE_R format.
+//  * WyomingObsReader     - reads radiosonde observations from University of Wyoming text soundings.
 package obsreader
@@ -0,0 +1,258 @@
+package obsreader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/pressure"
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/meteocima/dewetra2wrf/units"
+)
+
+// NetatmoObsReader reads observations from JSON
+// files containing responses of netatmo 'getpublicdata'
+// web API, previously archived on disk.
+type NetatmoObsReader struct {
+	// ElevationSource tells where the
+	// elevation of stations is taken from.
+	ElevationSource ElevationSource
+	// PrecipPeriod is the requested period of precipitation
+	// accumulation: rain gauges report amounts over the
+	// last hour, and over the last 24 hours when this is 24h.
+	PrecipPeriod time.Duration
+}
+
+// netatmoUnits are the units of values returned
+// by netatmo public API, that can't be changed.
+var netatmoUnits = units.Source{
+	Temperature:   units.Celsius,
+	Speed:         units.KilometersPerHour,
+	Pressure:      units.Millibar,
+	Precipitation: units.Millimeter,
+}
+
+// netatmoResponse is the body of
+// a 'getpublicdata' response.
+type netatmoResponse struct {
+	Body []netatmoStation `json:"body"`
+}
+
+// netatmoStation is a single station of a
+// 'getpublicdata' response, with its measures
+// grouped by module id.
+type netatmoStation struct {
+	ID    string `json:"_id"`
+	Place struct {
+		// Location is [lon, lat]
+		Location []float64 `json:"location"`
+		Altitude *float64  `json:"altitude"`
+		City     string    `json:"city"`
+	} `json:"place"`
+	Measures map[string]netatmoModule `json:"measures"`
+}
+
+// netatmoModule contains measures of a single
+// module. Indoor and outdoor modules report
+// values in Res, keyed by unix timestamp and ordered as
+// in Type. Rain and wind gauges report their last value
+// in dedicated fields.
+type netatmoModule struct {
+	Res  map[string][]*float64 `json:"res"`
+	Type []string              `json:"type"`
+
+	Rain60min   *float64 `json:"rain_60min"`
+	Rain24h     *float64 `json:"rain_24h"`
+	RainTimeUtc int64    `json:"rain_timeutc"`
+
+	WindStrength *float64 `json:"wind_strength"`
+	WindAngle    *float64 `json:"wind_angle"`
+	WindTimeUtc  int64    `json:"wind_timeutc"`
+}
+
+// ReadAll implements ObsReader for NetatmoObsReader.
+// dataPath could be a single file or a directory
+// containing many of them. For each station, measures
+// closest to date within 30 minutes are returned; when
+// date is zero, the most recent ones are used.
+func (r NetatmoObsReader) ReadAll(dataPath string, domain types.Region, date time.Time) ([]types.Observation, error) {
+	files, err := inputFiles(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	observations := []types.Observation{}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		stations, err := parseNetatmo(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, station := range stations {
+			obs, ok := netatmoObservation(station, date, r.PrecipPeriod)
+			if !ok {
+				continue
+			}
+			if domain.Contains(obs.Lat, obs.Lon) {
+				obs.Elevation = r.ElevationSource.stationElevation(obs.StationID, obs.Elevation, obs.Lat, obs.Lon)
+				observations = append(observations, obs)
+			}
+		}
+	}
+
+	return closestToDate(observations, date, 30*time.Minute), nil
+}
+
+// parseNetatmo decodes the stations contained in content,
+// that is either a whole 'getpublicdata' response or just
+// its body array.
+func parseNetatmo(content []byte) ([]netatmoStation, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("[")) {
+		var stations []netatmoStation
+		err := json.Unmarshal(content, &stations)
+		return stations, err
+	}
+	var response netatmoResponse
+	err := json.Unmarshal(content, &response)
+	return response.Body, err
+}
+
+// netatmoValue returns the value pointed by v, or NaN.
+func netatmoValue(v *float64) types.Value {
+	if v == nil {
+		return types.NaN()
+	}
+	return types.Value(*v)
+}
+
+// netatmoAccept returns whether a measure taken at t could
+// be used for an observation at date, and whether it is
+// better than one taken at best.
+func netatmoAccept(t, best, date time.Time) bool {
+	if date.IsZero() {
+		return best.IsZero() || t.After(best)
+	}
+	delta := absDuration(t.Sub(date))
+	if delta > 30*time.Minute {
+		return false
+	}
+	return best.IsZero() || delta < absDuration(best.Sub(date))
+}
+
+// netatmoObservation converts a netatmo station into
+// a types.Observation, using measures that are closest
+// to date, and precipitation accumulated over the last
+// 24 hours when precipPeriod is 24h, otherwise over the
+// last hour. It returns false if the station has no
+// location or no usable measure.
+func netatmoObservation(station netatmoStation, date time.Time, precipPeriod time.Duration) (types.Observation, bool) {
+	obs := missingObservation(types.PlatformSynop)
+	obs.Group = types.Netatmo
+	obs.StationID = station.ID
+	obs.StationName = station.ID
+
+	if len(station.Place.Location) != 2 || station.ID == "" {
+		return obs, false
+	}
+	obs.Lon = station.Place.Location[0]
+	obs.Lat = station.Place.Location[1]
+	obs.Elevation = math.NaN()
+	if station.Place.Altitude != nil {
+		obs.Elevation = *station.Place.Altitude
+	}
+
+	// visit modules in a stable order
+	moduleIDs := make([]string, 0, len(station.Measures))
+	for id := range station.Measures {
+		moduleIDs = append(moduleIDs, id)
+	}
+	sort.Strings(moduleIDs)
+
+	// measure times, used to choose the
+	// observation time of the station
+	var tempAt, otherAt time.Time
+	found := false
+
+	for _, id := range moduleIDs {
+		module := station.Measures[id]
+
+		var bestAt time.Time
+		var best []*float64
+		for ts, values := range module.Res {
+			unix, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				continue
+			}
+			at := time.Unix(unix, 0).UTC()
+			if netatmoAccept(at, bestAt, date) {
+				bestAt = at
+				best = values
+			}
+		}
+		for i, kind := range module.Type {
+			if i >= len(best) || best[i] == nil {
+				continue
+			}
+			value := types.Value(*best[i])
+			switch kind {
+			case "temperature":
+				obs.Metric.TempAvg = inBase(netatmoUnits.Temperature, value)
+				tempAt = bestAt
+			case "humidity":
+				obs.HumidityAvg = value
+			case "pressure":
+				// netatmo pressure is reduced to sea level.
+				setPressure(&obs.Metric, pressure.SeaLevel, inBase(netatmoUnits.Pressure, value))
+			default:
+				continue
+			}
+			found = true
+			if otherAt.IsZero() {
+				otherAt = bestAt
+			}
+		}
+
+		if module.WindStrength != nil && module.WindTimeUtc != 0 {
+			at := time.Unix(module.WindTimeUtc, 0).UTC()
+			if netatmoAccept(at, time.Time{}, date) {
+				obs.Metric.WindspeedAvg = inBase(netatmoUnits.Speed, netatmoValue(module.WindStrength))
+				obs.WinddirAvg = netatmoValue(module.WindAngle)
+				found = true
+				if otherAt.IsZero() {
+					otherAt = at
+				}
+			}
+		}
+
+		if module.Rain60min != nil && module.RainTimeUtc != 0 {
+			at := time.Unix(module.RainTimeUtc, 0).UTC()
+			if netatmoAccept(at, time.Time{}, date) {
+				obs.Metric.PrecipTotal = inBase(netatmoUnits.Precipitation, netatmoValue(module.Rain60min))
+				obs.PrecipPeriod = time.Hour
+				if precipPeriod == 24*time.Hour && module.Rain24h != nil {
+					obs.Metric.PrecipTotal = inBase(netatmoUnits.Precipitation, netatmoValue(module.Rain24h))
+					obs.PrecipPeriod = precipPeriod
+				}
+				found = true
+				if otherAt.IsZero() {
+					otherAt = at
+				}
+			}
+		}
+	}
+
+	obs.ObsTimeUtc = tempAt
+	if obs.ObsTimeUtc.IsZero() {
+		obs.ObsTimeUtc = otherAt
+	}
+
+	return obs, found
+}
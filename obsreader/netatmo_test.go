@@ -0,0 +1,108 @@
+package obsreader
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var netatmoResponseFixture = `{
+	"status": "ok",
+	"time_server": 1585591800,
+	"body": [
+		{
+			"_id": "70:ee:50:00:00:01",
+			"place": {"location": [8.93, 44.41], "timezone": "Europe/Rome", "country": "IT", "altitude": 35, "city": "Genova"},
+			"mark": 10,
+			"measures": {
+				"02:00:00:00:00:01": {
+					"res": {"1585590600": [11.5, 70], "1585591200": [12.5, 80]},
+					"type": ["temperature", "humidity"]
+				},
+				"70:ee:50:00:00:01": {
+					"res": {"1585591200": [1013.2]},
+					"type": ["pressure"]
+				},
+				"05:00:00:00:00:01": {
+					"rain_60min": 1.2, "rain_24h": 4.5, "rain_live": 0, "rain_timeutc": 1585591190
+				},
+				"06:00:00:00:00:01": {
+					"wind_strength": 36, "wind_angle": 200, "gust_strength": 50, "gust_angle": 210, "wind_timeutc": 1585591190
+				}
+			},
+			"modules": ["02:00:00:00:00:01", "05:00:00:00:00:01", "06:00:00:00:00:01"]
+		},
+		{
+			"_id": "70:ee:50:00:00:02",
+			"place": {"location": [2.35, 48.85], "altitude": 40},
+			"measures": {
+				"02:00:00:00:00:02": {"res": {"1585591200": [9, 60]}, "type": ["temperature", "humidity"]}
+			}
+		},
+		{
+			"_id": "70:ee:50:00:00:03",
+			"place": {"location": [9.19, 45.46], "altitude": 120},
+			"measures": {
+				"02:00:00:00:00:03": {"res": {"1585580400": [9, 60]}, "type": ["temperature", "humidity"]}
+			}
+		}
+	]
+}`
+
+func TestNetatmo(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "netatmo.json")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(netatmoResponseFixture), 0644))
+
+	italy := types.Domain{MinLat: 36, MaxLat: 47, MinLon: 6, MaxLon: 19}
+	observations, err := NetatmoObsReader{}.ReadAll(file, italy, time.Date(2020, 3, 30, 18, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	// second station is outside domain, third has no recent measures
+	assert.Equal(t, 1, len(observations))
+
+	obs := observations[0]
+	assert.Equal(t, "70:ee:50:00:00:01", obs.StationID)
+	assert.Equal(t, types.Netatmo, obs.Group)
+	assert.Equal(t, 44.41, obs.Lat)
+	assert.Equal(t, 8.93, obs.Lon)
+	assert.Equal(t, 35.0, obs.Elevation)
+	assert.Equal(t, time.Date(2020, 3, 30, 18, 0, 0, 0, time.UTC), obs.ObsTimeUtc)
+	assert.InDelta(t, 285.65, obs.Metric.TempAvg.AsFloat(), 1e-6)
+	assert.Equal(t, types.Value(80), obs.HumidityAvg)
+	assert.InDelta(t, 101320, obs.Metric.SeaLevelPressure.AsFloat(), 1e-6)
+	assert.True(t, obs.Metric.Pressure.IsNaN())
+	assert.InDelta(t, 10, obs.Metric.WindspeedAvg.AsFloat(), 1e-3)
+	assert.Equal(t, types.Value(200), obs.WinddirAvg)
+	assert.Equal(t, types.Value(1.2), obs.Metric.PrecipTotal)
+}
+
+func TestNetatmoLatest(t *testing.T) {
+	stations, err := parseNetatmo([]byte(netatmoResponseFixture))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(stations))
+
+	obs, ok := netatmoObservation(stations[0], time.Time{}, 0)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2020, 3, 30, 18, 0, 0, 0, time.UTC), obs.ObsTimeUtc)
+	assert.InDelta(t, 285.65, obs.Metric.TempAvg.AsFloat(), 1e-6)
+
+	obs, ok = netatmoObservation(stations[2], time.Time{}, 0)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2020, 3, 30, 15, 0, 0, 0, time.UTC), obs.ObsTimeUtc)
+}
+
+func TestNetatmoPrecipPeriod(t *testing.T) {
+	stations, err := parseNetatmo([]byte(netatmoResponseFixture))
+	assert.NoError(t, err)
+
+	obs, _ := netatmoObservation(stations[0], time.Time{}, 0)
+	assert.Equal(t, types.Value(1.2), obs.Metric.PrecipTotal)
+	assert.Equal(t, time.Hour, obs.PrecipPeriod)
+
+	obs, _ = netatmoObservation(stations[0], time.Time{}, 24*time.Hour)
+	assert.Equal(t, types.Value(4.5), obs.Metric.PrecipTotal)
+	assert.Equal(t, 24*time.Hour, obs.PrecipPeriod)
+}
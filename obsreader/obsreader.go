@@ -10,9 +10,60 @@ import (
 // are ables to read `types.Observation`.
 type ObsReader interface {
 	// ReadAll returns a slice of types.Observation read
-	// from path argument, filtered by `domain` and
+	// from path argument, filtered by `domain` region and
 	// `date` arguments.
 	// If an error occurred, it is returned as second value,
 	// with the first one nil.
-	ReadAll(path string, domain types.Domain, date time.Time) ([]types.Observation, error)
+	ReadAll(path string, domain types.Region, date time.Time) ([]types.Observation, error)
+}
+
+// closestToDate returns, for each station, the observation
+// that occurred closest to date, skipping observations
+// that are more than maxDelta apart from it.
+// When date is zero, observations are returned unfiltered.
+func closestToDate(observations []types.Observation, date time.Time, maxDelta time.Duration) []types.Observation {
+	if date.IsZero() {
+		return observations
+	}
+
+	result := []types.Observation{}
+	stationIdx := map[string]int{}
+
+	for _, obs := range observations {
+		delta := absDuration(obs.ObsTimeUtc.Sub(date))
+		if delta > maxDelta {
+			continue
+		}
+		idx, ok := stationIdx[obs.StationID]
+		if !ok {
+			stationIdx[obs.StationID] = len(result)
+			result = append(result, obs)
+			continue
+		}
+		if delta < absDuration(result[idx].ObsTimeUtc.Sub(date)) {
+			result[idx] = obs
+		}
+	}
+
+	return result
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// missingObservation returns an observation
+// of given platform with all values set to NaN.
+func missingObservation(platform string) types.Observation {
+	return types.Observation{
+		Platform:    platform,
+		HumidityAvg: types.NaN(),
+		WinddirAvg:  types.NaN(),
+		Visibility:  types.NaN(),
+		UvHigh:      types.NaN(),
+		Metric:      types.MissingMetric(),
+	}
 }
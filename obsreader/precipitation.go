@@ -0,0 +1,77 @@
+package obsreader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/precipitation"
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// wundAccumulate replaces the daily precipitation of
+// Wunderground observations in list with the amount
+// accumulated over period until their time, computed from
+// the observations of the same station. It does nothing
+// when period is zero.
+func wundAccumulate(list []types.Observation, period time.Duration) {
+	if period == 0 {
+		return
+	}
+	series := map[string]*precipitation.Series{}
+	for _, obs := range list {
+		s, ok := series[obs.StationID]
+		if !ok {
+			s = &precipitation.Series{Kind: precipitation.Counter}
+			series[obs.StationID] = s
+		}
+		s.Add(obs.ObsTimeUtc, obs.Metric.PrecipTotal.AsFloat())
+	}
+	for i, obs := range list {
+		list[i].Metric.PrecipTotal = types.Value(series[obs.StationID].Accumulate(obs.ObsTimeUtc, period))
+		list[i].PrecipPeriod = period
+	}
+}
+
+// wundCurrentSeries returns, keyed by station ID, the
+// daily precipitation of Wunderground stations read from
+// the hourly directories of 'current' observations from
+// period before date until date. Missing directories
+// are skipped.
+func wundCurrentSeries(dataPath string, date time.Time, period time.Duration) (map[string]*precipitation.Series, error) {
+	series := map[string]*precipitation.Series{}
+	for at := date.Add(-period); !at.After(date); at = at.Add(time.Hour) {
+		dateDir := filepath.Join(dataPath, at.Format("2006010215"))
+		files, err := ioutil.ReadDir(dateDir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			obsBuf, err := ioutil.ReadFile(filepath.Join(dateDir, f.Name()))
+			if err != nil {
+				return nil, err
+			}
+			var wundObs wundObservation
+			if err := json.Unmarshal(obsBuf, &wundObs); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name(), err)
+			}
+			obs, err := wundObs.observation()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name(), err)
+			}
+			s, ok := series[obs.StationID]
+			if !ok {
+				s = &precipitation.Series{Kind: precipitation.Counter, Step: time.Hour}
+				series[obs.StationID] = s
+			}
+			s.Add(obs.ObsTimeUtc, obs.Metric.PrecipTotal.AsFloat())
+		}
+	}
+	return series, nil
+}
@@ -0,0 +1,28 @@
+package obsreader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWundAccumulate(t *testing.T) {
+	midnight := time.Date(2021, 3, 15, 0, 0, 0, 0, time.UTC)
+	list := []types.Observation{}
+	// daily accumulation, reset at midnight
+	for i, precip := range []types.Value{4, 5, 7, 0.5, 1.5} {
+		obs := types.Observation{StationID: "ILIGURIA42", ObsTimeUtc: midnight.Add(time.Duration(i-3) * 30 * time.Minute)}
+		obs.Metric.PrecipTotal = precip
+		list = append(list, obs)
+	}
+
+	wundAccumulate(list, time.Hour)
+	assert.True(t, list[0].Metric.PrecipTotal.IsNaN())
+	assert.True(t, list[1].Metric.PrecipTotal.IsNaN())
+	assert.Equal(t, types.Value(3), list[2].Metric.PrecipTotal)
+	assert.Equal(t, types.Value(2.5), list[3].Metric.PrecipTotal)
+	assert.Equal(t, types.Value(1.5), list[4].Metric.PrecipTotal)
+	assert.Equal(t, time.Hour, list[4].PrecipPeriod)
+}
@@ -0,0 +1,17 @@
+package obsreader
+
+import (
+	"github.com/meteocima/dewetra2wrf/pressure"
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// setPressure stores v, a pressure of given kind,
+// into Pressure when measured at station level,
+// or into SeaLevelPressure when reduced to sea level.
+func setPressure(m *types.ObservationMetric, kind pressure.Kind, v types.Value) {
+	if kind == pressure.SeaLevel {
+		m.SeaLevelPressure = v
+		return
+	}
+	m.Pressure = v
+}
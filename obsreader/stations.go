@@ -0,0 +1,147 @@
+package obsreader
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_ "embed" // needed by go:embed
+
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// icaoStations contains the stations table
+// of the main italian airports, used by
+// MetarObsReader when no other table is given.
+//
+//go:embed icao-stations.csv
+var icaoStations []byte
+
+// station contains coordinates and metadata
+// of a station, as read from a stations table.
+type station struct {
+	ID        string
+	Name      string
+	Lat, Lon  float64
+	Elevation float64
+}
+
+// stationsTable maps station identifiers
+// (ICAO codes or WMO indexes) to their metadata.
+type stationsTable map[string]station
+
+// locate fills name, coordinates and elevation of
+// obs using the station with obs.StationID in the table.
+// When the table lacks the elevation of the station,
+// it is set to NaN.
+// It returns false if the station is unknown
+// or falls outside domain.
+func (table stationsTable) locate(obs *types.Observation, domain types.Region) bool {
+	st, ok := table[obs.StationID]
+	if !ok {
+		return false
+	}
+	if !domain.Contains(st.Lat, st.Lon) {
+		return false
+	}
+
+	obs.StationName = st.Name
+	obs.Lat = st.Lat
+	obs.Lon = st.Lon
+	obs.Elevation = st.Elevation
+	return true
+}
+
+// readStationsTable reads a stations table
+// in CSV format. Each record contains ID, latitude,
+// longitude, elevation and name of a station, in that
+// sequence. Lines starting with '#' are ignored.
+// Elevation could be left empty when unknown.
+func readStationsTable(r io.Reader) (stationsTable, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	table := stationsTable{}
+	for _, record := range records {
+		if len(record) < 4 {
+			return nil, fmt.Errorf("invalid stations table record: %s", strings.Join(record, ","))
+		}
+		st := station{ID: strings.TrimSpace(record[0])}
+		if st.Lat, err = strconv.ParseFloat(strings.TrimSpace(record[1]), 64); err != nil {
+			return nil, err
+		}
+		if st.Lon, err = strconv.ParseFloat(strings.TrimSpace(record[2]), 64); err != nil {
+			return nil, err
+		}
+		if elevation := strings.TrimSpace(record[3]); elevation == "" {
+			st.Elevation = math.NaN()
+		} else if st.Elevation, err = strconv.ParseFloat(elevation, 64); err != nil {
+			return nil, err
+		}
+		if len(record) > 4 {
+			st.Name = strings.TrimSpace(record[4])
+		} else {
+			st.Name = st.ID
+		}
+		table[st.ID] = st
+	}
+	return table, nil
+}
+
+// openStationsTable reads the stations table
+// contained in file. When file is empty, the
+// default table is read instead.
+func openStationsTable(file string, defaultTable []byte) (stationsTable, error) {
+	if file == "" {
+		return readStationsTable(bytes.NewReader(defaultTable))
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readStationsTable(f)
+}
+
+// inputFiles returns path itself if it is a
+// regular file, or all regular files contained in it
+// if it is a directory.
+func inputFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		result = append(result, filepath.Join(path, f.Name()))
+	}
+	return result, nil
+}
@@ -0,0 +1,385 @@
+package obsreader
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/pressure"
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/meteocima/dewetra2wrf/units"
+)
+
+// SynopObsReader reads observations from
+// text files containing traditional alphanumeric
+// SYNOP reports (FM-12, AAXX), possibly grouped in
+// WMO bulletins.
+// Coordinates of the stations are read from the
+// CSV table at StationsFile, keyed by WMO index.
+type SynopObsReader struct {
+	StationsFile string
+	// ElevationSource tells where the
+	// elevation of stations is taken from.
+	ElevationSource ElevationSource
+	// PrecipPeriod, when not zero, selects the precipitation
+	// amount accumulated over this period, among those
+	// reported.
+	PrecipPeriod time.Duration
+}
+
+// ReadAll implements ObsReader for SynopObsReader.
+// dataPath could be a single file or a directory
+// containing many of them. For each station, only the
+// report closest to date within 30 minutes is returned.
+// Malformed reports and reports from stations missing
+// from the stations table are skipped.
+func (r SynopObsReader) ReadAll(dataPath string, domain types.Region, date time.Time) ([]types.Observation, error) {
+	if r.StationsFile == "" {
+		return nil, errors.New("SYNOP reader requires a stations table")
+	}
+	stations, err := openStationsTable(r.StationsFile, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := inputFiles(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	refDate := date
+	if refDate.IsZero() {
+		refDate = time.Now().UTC()
+	}
+
+	observations := []types.Observation{}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, report := range splitSynopReports(string(content)) {
+			obs, err := parseSynop(report, refDate, r.PrecipPeriod)
+			if err != nil {
+				continue
+			}
+			if stations.locate(&obs, domain) {
+				obs.Elevation = r.ElevationSource.stationElevation(obs.StationID, obs.Elevation, obs.Lat, obs.Lon)
+				observations = append(observations, obs)
+			}
+		}
+	}
+
+	return closestToDate(observations, date, 30*time.Minute), nil
+}
+
+// synopReport contains the groups of a single
+// SYNOP report, together with the values of
+// section 0 of the bulletin it belongs to.
+type synopReport struct {
+	day, hour int
+	// windUnit is the iw indicator of section 0
+	windUnit byte
+	groups   []string
+}
+
+func isSynopGroup(token string) bool {
+	if len(token) != 5 {
+		return false
+	}
+	for _, ch := range token {
+		if (ch < '0' || ch > '9') && ch != '/' {
+			return false
+		}
+	}
+	return true
+}
+
+// splitSynopReports splits text into single
+// SYNOP reports. Each report is terminated by a '=',
+// and section 0 (AAXX YYGGi) applies to all reports that
+// follow it, until the end of the bulletin.
+// NIL reports are discarded.
+func splitSynopReports(text string) []synopReport {
+	reports := []synopReport{}
+	var current *synopReport
+
+	for _, chunk := range strings.Split(text, "=") {
+		tokens := strings.Fields(chunk)
+
+		for idx, token := range tokens {
+			if token != "AAXX" {
+				continue
+			}
+			current = nil
+			if idx+1 >= len(tokens) || !isSynopGroup(tokens[idx+1]) {
+				break
+			}
+			yyggi := tokens[idx+1]
+			day, errDay := strconv.Atoi(yyggi[0:2])
+			hour, errHour := strconv.Atoi(yyggi[2:4])
+			if errDay != nil || errHour != nil {
+				break
+			}
+			current = &synopReport{
+				day:      day,
+				hour:     hour,
+				windUnit: yyggi[4],
+			}
+			tokens = tokens[idx+2:]
+			break
+		}
+
+		if current == nil || len(tokens) == 0 {
+			continue
+		}
+
+		if !isSynopGroup(tokens[0]) {
+			// end of bulletin
+			current = nil
+			continue
+		}
+
+		if len(tokens) > 1 && tokens[1] == "NIL" {
+			continue
+		}
+
+		report := *current
+		report.groups = tokens
+		reports = append(reports, report)
+	}
+
+	return reports
+}
+
+// synopSignedTenths returns the value
+// of a snTTT group part, in units.
+func synopSignedTenths(sn byte, ttt string) (float64, bool) {
+	val, err := strconv.ParseFloat(ttt, 64)
+	if err != nil {
+		return 0, false
+	}
+	val /= 10
+	switch sn {
+	case '0':
+		return val, true
+	case '1':
+		return -val, true
+	}
+	return 0, false
+}
+
+// synopPressure returns pressure in hPa
+// of a PPPP group part, expressed in tenths of
+// hPa and with thousands digit omitted.
+func synopPressure(pppp string) (float64, bool) {
+	val, err := strconv.ParseFloat(pppp, 64)
+	if err != nil {
+		return 0, false
+	}
+	val /= 10
+	if val < 100 {
+		val += 1000
+	}
+	return val, true
+}
+
+// synopPrecipitation returns precipitation
+// amount in mm of a RRR group part.
+func synopPrecipitation(rrr string) (float64, bool) {
+	val, err := strconv.Atoi(rrr)
+	if err != nil {
+		return 0, false
+	}
+	if val == 990 {
+		// trace
+		return 0, true
+	}
+	if val > 990 {
+		return float64(val-990) / 10, true
+	}
+	return float64(val), true
+}
+
+// synopPrecip is a precipitation amount in mm,
+// accumulated over period, or over an unknown
+// period when it's zero.
+type synopPrecip struct {
+	amount float64
+	period time.Duration
+}
+
+// synopPrecipPeriods are the periods of
+// precipitation told by tR indicators.
+var synopPrecipPeriods = map[byte]time.Duration{
+	'1': 6 * time.Hour,
+	'2': 12 * time.Hour,
+	'3': 18 * time.Hour,
+	'4': 24 * time.Hour,
+	'5': time.Hour,
+	'6': 2 * time.Hour,
+	'7': 3 * time.Hour,
+	'8': 9 * time.Hour,
+	'9': 15 * time.Hour,
+}
+
+// synopUnits are the units of values in SYNOP reports,
+// except wind speed whose unit is given by synopWindUnit.
+var synopUnits = units.Source{
+	Temperature: units.Celsius,
+	Pressure:    units.Hectopascal,
+}
+
+// synopWindUnit returns the unit of wind speed
+// told by the iw indicator of section 0.
+func synopWindUnit(iw byte) (units.Unit, error) {
+	switch iw {
+	case '0', '1':
+		return units.MetersPerSecond, nil
+	case '3', '4':
+		return units.Knots, nil
+	}
+	return units.Unit{}, fmt.Errorf("unknown wind speed unit indicator `%c`", iw)
+}
+
+// parseSynop parses a single SYNOP report.
+// Returned observation contains only station
+// identifier, time and measured values; temperatures
+// are in °K, wind speed in m/s, pressure in Pa
+// and precipitation in mm.
+// Pressure is the station level pressure, as
+// reported in 3PPPP group, while 4PPPP group is
+// returned as SeaLevelPressure.
+// Precipitation is the first amount reported in
+// 6RRRtR or 7R24R24R24R24 groups, or the first one
+// accumulated over precipPeriod when it's not zero.
+func parseSynop(report synopReport, ref time.Time, precipPeriod time.Duration) (types.Observation, error) {
+	obs := missingObservation(types.PlatformSynop)
+	obs.Group = types.WMOStations
+	groups := report.groups
+	if len(groups) < 3 {
+		return obs, errors.New("report too short: " + strings.Join(groups, " "))
+	}
+	for _, group := range groups[1:3] {
+		if !isSynopGroup(group) {
+			return obs, errors.New("invalid group " + group + " in report: " + strings.Join(groups, " "))
+		}
+	}
+
+	obs.StationID = groups[0]
+	obs.ObsTimeUtc = metarTime(report.day, report.hour, 0, ref)
+
+	precipIndicator := groups[1][0]
+	if precipIndicator == '3' {
+		// no precipitation occurred
+		obs.Metric.PrecipTotal = 0
+		obs.PrecipPeriod = precipPeriod
+	}
+	precips := []synopPrecip{}
+
+	nddff := groups[2]
+	speed, errSpeed := strconv.ParseFloat(nddff[3:5], 64)
+	groups = groups[3:]
+	if nddff[3:5] == "99" && len(groups) > 0 && strings.HasPrefix(groups[0], "00") {
+		// speed of 99 units or more
+		// is reported in following group.
+		if !isSynopGroup(groups[0]) {
+			return obs, errors.New("invalid group " + groups[0] + " in report: " + strings.Join(report.groups, " "))
+		}
+		speed, errSpeed = strconv.ParseFloat(groups[0][2:5], 64)
+		groups = groups[1:]
+	}
+	if errSpeed == nil {
+		unit, err := synopWindUnit(report.windUnit)
+		if err != nil {
+			return obs, err
+		}
+		obs.Metric.WindspeedAvg = types.Value(unit.ToBase(speed))
+	}
+	if dir, err := strconv.ParseFloat(nddff[1:3], 64); err == nil && dir <= 36 {
+		obs.WinddirAvg = types.Value(dir * 10)
+	}
+
+	section := "1"
+	for _, group := range groups {
+		switch {
+		case len(group) == 5 && strings.HasPrefix(group, "222"):
+			// section 2 starts with the 222Dv group
+			// of ship and coastal station reports.
+			section = "2"
+			continue
+		case group == "333", group == "444", group == "555":
+			section = group[0:1]
+			continue
+		}
+		if section == "4" || section == "5" {
+			break
+		}
+		if len(group) != 5 {
+			continue
+		}
+
+		if section == "1" {
+			switch group[0] {
+			case '1':
+				if val, ok := synopSignedTenths(group[1], group[2:]); ok {
+					obs.Metric.TempAvg = types.Value(synopUnits.Temperature.ToBase(val))
+				}
+			case '2':
+				if group[1] == '9' {
+					if val, err := strconv.ParseFloat(group[2:], 64); err == nil {
+						obs.HumidityAvg = types.Value(val)
+					}
+				} else if val, ok := synopSignedTenths(group[1], group[2:]); ok {
+					obs.Metric.DewptAvg = types.Value(synopUnits.Temperature.ToBase(val))
+				}
+			case '3':
+				if val, ok := synopPressure(group[1:]); ok {
+					setPressure(&obs.Metric, pressure.Station, types.Value(synopUnits.Pressure.ToBase(val)))
+				}
+			case '4':
+				// 4a3hhh groups, reported instead of
+				// sea level pressure by high level stations,
+				// never start with 0 or 9.
+				if group[1] != '0' && group[1] != '9' {
+					continue
+				}
+				if val, ok := synopPressure(group[1:]); ok {
+					setPressure(&obs.Metric, pressure.SeaLevel, types.Value(synopUnits.Pressure.ToBase(val)))
+				}
+			case '6':
+				if val, ok := synopPrecipitation(group[1:4]); ok {
+					precips = append(precips, synopPrecip{val, synopPrecipPeriods[group[4]]})
+				}
+			}
+		}
+
+		if section == "3" && group[0] == '6' {
+			if val, ok := synopPrecipitation(group[1:4]); ok {
+				precips = append(precips, synopPrecip{val, synopPrecipPeriods[group[4]]})
+			}
+		}
+		if section == "3" && group[0] == '7' {
+			if val, err := strconv.ParseFloat(group[1:], 64); err == nil {
+				if val == 9999 {
+					// trace
+					val = 0
+				}
+				precips = append(precips, synopPrecip{val / 10, 24 * time.Hour})
+			}
+		}
+	}
+
+	for _, p := range precips {
+		if precipPeriod == 0 || p.period == precipPeriod {
+			obs.Metric.PrecipTotal = types.Value(p.amount)
+			obs.PrecipPeriod = p.period
+			break
+		}
+	}
+
+	return obs, nil
+}
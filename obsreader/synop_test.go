@@ -0,0 +1,155 @@
+package obsreader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var synopBulletin = `ZCZC 045
+SMIT01 LIIB 141200
+AAXX 14121
+16242 12970 52512 10125 20083 30105 40125 57010 60051 70222 81330
+      333 20080=
+16080 NIL=
+16045 21/98 /9905 11021 29085 38712 4//// 333 69922=
+NNNN
+`
+
+func TestSplitSynopReports(t *testing.T) {
+	reports := splitSynopReports(synopBulletin)
+	assert.Equal(t, 2, len(reports))
+	assert.Equal(t, 14, reports[0].day)
+	assert.Equal(t, 12, reports[0].hour)
+	assert.Equal(t, byte('1'), reports[0].windUnit)
+	assert.Equal(t, "16242", reports[0].groups[0])
+	assert.Equal(t, "20080", reports[0].groups[12])
+	assert.Equal(t, "16045", reports[1].groups[0])
+}
+
+func TestParseSynop(t *testing.T) {
+	ref := time.Date(2021, 3, 14, 12, 0, 0, 0, time.UTC)
+	reports := splitSynopReports(synopBulletin)
+
+	obs, err := parseSynop(reports[0], ref, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "16242", obs.StationID)
+	assert.Equal(t, types.PlatformSynop, obs.Platform)
+	assert.Equal(t, ref, obs.ObsTimeUtc)
+	assert.Equal(t, types.Value(250), obs.WinddirAvg)
+	assert.Equal(t, types.Value(12), obs.Metric.WindspeedAvg)
+	assert.InDelta(t, 285.65, obs.Metric.TempAvg.AsFloat(), 0.001)
+	assert.InDelta(t, 281.45, obs.Metric.DewptAvg.AsFloat(), 0.001)
+	assert.InDelta(t, 101050, obs.Metric.Pressure.AsFloat(), 0.001)
+	assert.InDelta(t, 101250, obs.Metric.SeaLevelPressure.AsFloat(), 0.001)
+	assert.Equal(t, types.Value(5), obs.Metric.PrecipTotal)
+	assert.True(t, obs.HumidityAvg.IsNaN())
+
+	obs, err = parseSynop(reports[1], ref, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "16045", obs.StationID)
+	assert.True(t, obs.WinddirAvg.IsNaN())
+	assert.Equal(t, types.Value(5), obs.Metric.WindspeedAvg)
+	assert.InDelta(t, 271.05, obs.Metric.TempAvg.AsFloat(), 0.001)
+	assert.True(t, obs.Metric.DewptAvg.IsNaN())
+	assert.Equal(t, types.Value(85), obs.HumidityAvg)
+	assert.InDelta(t, 87120, obs.Metric.Pressure.AsFloat(), 0.001)
+	assert.True(t, obs.Metric.SeaLevelPressure.IsNaN())
+	assert.InDelta(t, 0.2, obs.Metric.PrecipTotal.AsFloat(), 0.001)
+}
+
+func TestParseSynopWindUnit(t *testing.T) {
+	ref := time.Date(2021, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	report := synopReport{day: 14, hour: 12, windUnit: '4', groups: []string{"16242", "12970", "52510"}}
+	obs, err := parseSynop(report, ref, 0)
+	assert.NoError(t, err)
+	assert.InDelta(t, 5.14444, obs.Metric.WindspeedAvg.AsFloat(), 0.00001)
+
+	report.windUnit = '/'
+	_, err = parseSynop(report, ref, 0)
+	assert.EqualError(t, err, "unknown wind speed unit indicator `/`")
+}
+
+func TestParseSynopPrecipPeriod(t *testing.T) {
+	ref := time.Date(2021, 3, 14, 12, 0, 0, 0, time.UTC)
+	reports := splitSynopReports(synopBulletin)
+
+	obs, err := parseSynop(reports[0], ref, 6*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, types.Value(5), obs.Metric.PrecipTotal)
+	assert.Equal(t, 6*time.Hour, obs.PrecipPeriod)
+
+	obs, err = parseSynop(reports[0], ref, 24*time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, obs.Metric.PrecipTotal.IsNaN())
+
+	obs, err = parseSynop(reports[1], ref, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 12*time.Hour, obs.PrecipPeriod)
+
+	report := synopReport{day: 14, hour: 12, windUnit: '1', groups: []string{"16242", "12970", "52510", "60121", "333", "60247", "70123"}}
+	obs, err = parseSynop(report, ref, 24*time.Hour)
+	assert.NoError(t, err)
+	assert.InDelta(t, 12.3, obs.Metric.PrecipTotal.AsFloat(), 0.001)
+	assert.Equal(t, 24*time.Hour, obs.PrecipPeriod)
+
+	obs, err = parseSynop(report, ref, 3*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, types.Value(24), obs.Metric.PrecipTotal)
+}
+
+func TestParseSynopTruncated(t *testing.T) {
+	ref := time.Date(2021, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	reports := splitSynopReports("AAXX 14121\n16242 12970 525=")
+	assert.Equal(t, 1, len(reports))
+	_, err := parseSynop(reports[0], ref, 0)
+	assert.EqualError(t, err, "invalid group 525 in report: 16242 12970 525")
+
+	report := synopReport{day: 14, hour: 12, windUnit: '1', groups: []string{"16242", "12970", "52599", "001"}}
+	_, err = parseSynop(report, ref, 0)
+	assert.EqualError(t, err, "invalid group 001 in report: 16242 12970 52599 001")
+}
+
+func TestParseSynopSection2(t *testing.T) {
+	ref := time.Date(2021, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	// coastal station: the 222Dv group opens the
+	// sea surface and wave groups of section 2.
+	reports := splitSynopReports("AAXX 14121\n16120 12970 52512 10125 20083 40125 22200 00150 10503 20301 333 20080=")
+	assert.Equal(t, 1, len(reports))
+	obs, err := parseSynop(reports[0], ref, 0)
+	assert.NoError(t, err)
+	assert.InDelta(t, 285.65, obs.Metric.TempAvg.AsFloat(), 0.001)
+	assert.InDelta(t, 281.45, obs.Metric.DewptAvg.AsFloat(), 0.001)
+	assert.InDelta(t, 101250, obs.Metric.SeaLevelPressure.AsFloat(), 0.001)
+}
+
+func TestSynopReadAll(t *testing.T) {
+	dir := t.TempDir()
+	stationsFile := filepath.Join(dir, "stations.csv")
+	err := ioutil.WriteFile(stationsFile, []byte("16242,41.800,12.583,131,Station A\n16045,46.000,13.000,,Station B\n"), 0644)
+	assert.NoError(t, err)
+	dataDir := filepath.Join(dir, "data")
+	assert.NoError(t, os.Mkdir(dataDir, 0755))
+	err = ioutil.WriteFile(filepath.Join(dataDir, "bulletin.txt"), []byte(synopBulletin), 0644)
+	assert.NoError(t, err)
+
+	date := time.Date(2021, 3, 14, 12, 0, 0, 0, time.UTC)
+	domain := types.Domain{MinLat: 40, MaxLat: 45, MinLon: 10, MaxLon: 14}
+
+	_, err = SynopObsReader{}.ReadAll(dataDir, domain, date)
+	assert.Error(t, err)
+
+	observations, err := SynopObsReader{StationsFile: stationsFile}.ReadAll(dataDir, domain, date)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(observations))
+	assert.Equal(t, "Station A", observations[0].StationName)
+	assert.Equal(t, 131.0, observations[0].Elevation)
+}
@@ -0,0 +1,38 @@
+package obsreader
+
+import (
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/meteocima/dewetra2wrf/units"
+)
+
+// inBase converts v from unit into the base
+// unit of its quantity, as used by types.Observation.
+func inBase(unit units.Unit, v types.Value) types.Value {
+	return types.Value(unit.ToBase(float64(v)))
+}
+
+// toBaseUnits converts the metric values of obs, read
+// from a source with given units, into the units used
+// by types.Observation.
+func toBaseUnits(obs *types.Observation, source units.Source) {
+	m := &obs.Metric
+	m.TempAvg = inBase(source.Temperature, m.TempAvg)
+	m.DewptAvg = inBase(source.Temperature, m.DewptAvg)
+	m.WindspeedAvg = inBase(source.Speed, m.WindspeedAvg)
+	m.Pressure = inBase(source.Pressure, m.Pressure)
+	m.PressureMin = inBase(source.Pressure, m.PressureMin)
+	m.PressureMax = inBase(source.Pressure, m.PressureMax)
+	m.SeaLevelPressure = inBase(source.Pressure, m.SeaLevelPressure)
+	m.PrecipTotal = inBase(source.Precipitation, m.PrecipTotal)
+	m.TempHigh = inBase(source.Temperature, m.TempHigh)
+	m.TempLow = inBase(source.Temperature, m.TempLow)
+	m.WindgustAvg = inBase(source.Speed, m.WindgustAvg)
+	// rate is per hour, in the same
+	// unit of precipitation amounts.
+	m.PrecipRate = inBase(source.Precipitation, m.PrecipRate)
+	m.HeatindexAvg = inBase(source.Temperature, m.HeatindexAvg)
+	if m.Elev != nil {
+		elev := inBase(source.Length, *m.Elev)
+		m.Elev = &elev
+	}
+}
@@ -4,30 +4,47 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"math"
+	"os"
 	"path"
 	"path/filepath"
 	"sort"
 	"time"
 
-	"github.com/meteocima/dewetra2wrf/elevations"
+	"github.com/meteocima/dewetra2wrf/precipitation"
 	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/meteocima/dewetra2wrf/units"
+	"github.com/meteocima/dewetra2wrf/wind"
 )
 
 // WebdropsObsReader is a struct that implements ObsReader
 // and that reads observations from JSON files as downloaded
 // from "webdrops" CIMA service.
-type WebdropsObsReader struct{}
+type WebdropsObsReader struct {
+	// ElevationSource tells where the
+	// elevation of stations is taken from.
+	ElevationSource ElevationSource
+	// PrecipPeriod, when not zero, is the period over
+	// which precipitation measured by PLUVIOMETRO sensors
+	// is accumulated, until the time of observations.
+	PrecipPeriod time.Duration
+	// WindWindow, when not zero, is the time window, ending
+	// at the time of observations, over which wind measured
+	// by ANEMOMETRO and DIREZIONEVENTO sensors is averaged
+	// as a vector.
+	WindWindow time.Duration
+}
 
 // ReadAll implements ObsReader for WebdropsObsReader
-func (r WebdropsObsReader) ReadAll(dataPath string, domain types.Domain, date time.Time) ([]types.Observation, error) {
+func (r WebdropsObsReader) ReadAll(dataPath string, domain types.Region, date time.Time) ([]types.Observation, error) {
 	/*
 		relativeHumidity, err := readRelativeHumidity(dataPath, domain, date)
 		if err != nil {
 			return nil, err
 		}
 	*/
-	temperature, err := readTemperature(dataPath, domain, date)
+	temperature, err := readTemperature(dataPath, domain, date, r.ElevationSource)
 	if err != nil {
 		return nil, err
 	}
@@ -52,36 +69,173 @@ func (r WebdropsObsReader) ReadAll(dataPath string, domain types.Domain, date ti
 			return nil, err
 		}
 	*/
-	return mergeObservations(dataPath, domain /*pressure, relativeHumidity, */, temperature /*, windDirection, windSpeed, precipitableWater*/)
+	observations, err := mergeObservations(dataPath, domain, r.ElevationSource /*pressure, relativeHumidity, */, temperature /*, windDirection, windSpeed, precipitableWater*/)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.PrecipPeriod > 0 {
+		precipSeries, err := readDewetraPrecipitation(dataPath, domain, r.ElevationSource)
+		if err != nil {
+			return nil, err
+		}
+		for i, obs := range observations {
+			observations[i].Metric.PrecipTotal = types.NaN()
+			if series, ok := precipSeries[obs.SortKey()]; ok {
+				observations[i].Metric.PrecipTotal = types.Value(series.Accumulate(obs.ObsTimeUtc, r.PrecipPeriod))
+			}
+			observations[i].PrecipPeriod = r.PrecipPeriod
+		}
+	}
+
+	if r.WindWindow > 0 {
+		speeds, err := readDewetraTimelines(dataPath, domain, "ANEMOMETRO", dewetraUnits.Speed, r.ElevationSource)
+		if err != nil {
+			return nil, err
+		}
+		directions, err := readDewetraTimelines(dataPath, domain, "DIREZIONEVENTO", units.Degree, r.ElevationSource)
+		if err != nil {
+			return nil, err
+		}
+		for i, obs := range observations {
+			speed, dir := dewetraWind(speeds[obs.SortKey()], directions[obs.SortKey()], obs.ObsTimeUtc, r.WindWindow)
+			observations[i].Metric.WindspeedAvg = types.Value(speed)
+			observations[i].WinddirAvg = types.Value(dir)
+		}
+	}
+
+	return observations, nil
+}
+
+// dewetraSample is a value of the timeline
+// of a Dewetra sensor, in base units.
+type dewetraSample struct {
+	at    time.Time
+	value float64
+}
+
+// readDewetraTimelines returns the timelines of sensors of
+// sensorClass within domain, keyed by the sort key of their
+// station, or an empty map when the data file is missing.
+// Values are converted into base units from the unit of
+// each sensor, or from defaultUnit when not declared; angles
+// are always in degrees.
+func readDewetraTimelines(dataPath string, domain types.Region, sensorClass string, defaultUnit units.Unit, source ElevationSource) (map[string][]dewetraSample, error) {
+	timelines := map[string][]dewetraSample{}
+	content, err := ioutil.ReadFile(filepath.Join(dataPath, sensorClass+".json"))
+	if os.IsNotExist(err) {
+		return timelines, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data := []sensorData{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("%s.json: %w", sensorClass, err)
+	}
+	sensorsTable, err := openSensorsMap(dataPath, domain, sensorClass, source)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sens := range data {
+		sensAnag, ok := sensorsTable[sens.SensorID]
+		if !ok {
+			continue
+		}
+		unit := defaultUnit
+		if defaultUnit.Quantity != units.Angle {
+			unit = dewetraUnit(sensAnag, defaultUnit)
+		}
+		samples := []dewetraSample{}
+		for idx, dateS := range sens.Timeline {
+			at, err := time.Parse(time.RFC3339, dateS)
+			if err != nil {
+				return nil, err
+			}
+			if idx >= len(sens.Values) {
+				break
+			}
+			value := types.Result{Value: sens.Values[idx]}.SensorValue()
+			samples = append(samples, dewetraSample{at, inBase(unit, value).AsFloat()})
+		}
+		timelines[fmt.Sprintf("%s:%05f:%05f", sensAnag.Name, sensAnag.Lat, sensAnag.Lng)] = samples
+	}
+	return timelines, nil
+}
+
+// readDewetraPrecipitation returns the timelines of
+// PLUVIOMETRO sensors within domain, keyed by the sort key
+// of their station, or an empty map when the data file
+// is missing. Each value of the timelines is the amount
+// fallen since the previous one.
+func readDewetraPrecipitation(dataPath string, domain types.Region, source ElevationSource) (map[string]*precipitation.Series, error) {
+	timelines, err := readDewetraTimelines(dataPath, domain, "PLUVIOMETRO", dewetraUnits.Precipitation, source)
+	if err != nil {
+		return nil, err
+	}
+	precipSeries := map[string]*precipitation.Series{}
+	for key, samples := range timelines {
+		series := &precipitation.Series{Kind: precipitation.Interval}
+		for _, sample := range samples {
+			series.Add(sample.at, sample.value)
+		}
+		precipSeries[key] = series
+	}
+	return precipSeries, nil
+}
+
+// dewetraWind returns the vector average of wind measured
+// by a station in the window ending at end, from the
+// timelines of its speed and direction sensors.
+// Directions are matched to speeds by time.
+func dewetraWind(speeds, directions []dewetraSample, end time.Time, window time.Duration) (speed, dir float64) {
+	dirAt := map[int64]float64{}
+	for _, d := range directions {
+		dirAt[d.at.Unix()] = d.value
+	}
+	samples := []wind.Sample{}
+	for _, s := range speeds {
+		if !s.at.After(end.Add(-window)) || s.at.After(end) {
+			continue
+		}
+		d, ok := dirAt[s.at.Unix()]
+		if !ok {
+			d = math.NaN()
+		}
+		samples = append(samples, wind.Sample{Speed: s.value, Direction: d})
+	}
+	return wind.Average(samples)
 }
 
 /*
-func readRelativeHumidity(dataPath string, domain types.Domain, date time.Time) ([]types.Result, error) {
+func readRelativeHumidity(dataPath string, domain types.Region, date time.Time) ([]types.Result, error) {
 	return readDewetraSensor(dataPath, domain, "IGROMETRO", date)
 }
 */
-func readTemperature(dataPath string, domain types.Domain, date time.Time) ([]types.Result, error) {
-	return readDewetraSensor(dataPath, domain, "TERMOMETRO", date)
+func readTemperature(dataPath string, domain types.Region, date time.Time, source ElevationSource) ([]types.Result, error) {
+	return readDewetraSensor(dataPath, domain, "TERMOMETRO", date, source)
 }
 
 /*
-func readWindDirection(dataPath string, domain types.Domain, date time.Time) ([]types.Result, error) {
+func readWindDirection(dataPath string, domain types.Region, date time.Time) ([]types.Result, error) {
 	return readDewetraSensor(dataPath, domain, "DIREZIONEVENTO", date)
 }
 
-func readWindSpeed(dataPath string, domain types.Domain, date time.Time) ([]types.Result, error) {
+func readWindSpeed(dataPath string, domain types.Region, date time.Time) ([]types.Result, error) {
 	return readDewetraSensor(dataPath, domain, "ANEMOMETRO", date)
 }
 
-func readPrecipitableWater(dataPath string, domain types.Domain, date time.Time) ([]types.Result, error) {
+func readPrecipitableWater(dataPath string, domain types.Region, date time.Time) ([]types.Result, error) {
 	return readDewetraSensor(dataPath, domain, "PLUVIOMETRO", date)
 }
 
-func readPressure(dataPath string, domain types.Domain, date time.Time) ([]types.Result, error) {
+func readPressure(dataPath string, domain types.Region, date time.Time) ([]types.Result, error) {
 	return readDewetraSensor(dataPath, domain, "BAROMETRO", date)
 }
 */
-func readDewetraSensor(dataPath string, domain types.Domain, sensorClass string, date time.Time) ([]types.Result, error) {
+func readDewetraSensor(dataPath string, domain types.Region, sensorClass string, date time.Time, source ElevationSource) ([]types.Result, error) {
 
 	content, err := ioutil.ReadFile(filepath.Join(dataPath, sensorClass+".json"))
 	if err != nil {
@@ -102,7 +256,7 @@ func readDewetraSensor(dataPath string, domain types.Domain, sensorClass string,
 		return nil, err
 	}
 
-	sensorsTable, err := openSensorsMap(dataPath, domain, sensorClass)
+	sensorsTable, err := openSensorsMap(dataPath, domain, sensorClass, source)
 	if err != nil {
 		return nil, err
 	}
@@ -154,7 +308,7 @@ func readDewetraSensor(dataPath string, domain types.Domain, sensorClass string,
 }
 
 // mergeObservations is
-func mergeObservations(dataPath string, domain types.Domain /*, pressure, relativeHumidity,*/, temperature /*, windDirection, windSpeed, precipitableWater*/ []types.Result) ([]types.Observation, error) {
+func mergeObservations(dataPath string, domain types.Region, source ElevationSource /*, pressure, relativeHumidity,*/, temperature /*, windDirection, windSpeed, precipitableWater*/ []types.Result) ([]types.Observation, error) {
 	//pressureIdx := 0
 	//relativeHumidityIdx := 0
 	temperatureIdx := 0
@@ -163,8 +317,10 @@ func mergeObservations(dataPath string, domain types.Domain /*, pressure, relati
 	//precipitableWaterIdx := 0
 
 	results := []types.Observation{}
+	// units of temperature sensors, by sensor ID
+	temperatureUnits := map[string]units.Unit{}
 
-	sensorsTable, err := openCompleteSensorsMap(dataPath, domain)
+	sensorsTable, err := openCompleteSensorsMap(dataPath, domain, source)
 	if err != nil {
 		return nil, err
 	}
@@ -237,14 +393,11 @@ func mergeObservations(dataPath string, domain types.Domain /*, pressure, relati
 			Lon:         station.Lng,
 			HumidityAvg: types.NaN(),
 			WinddirAvg:  types.NaN(),
+			Visibility:  types.NaN(),
+			UvHigh:      types.NaN(),
 			Elevation:   station.Elevation,
-			Metric: types.ObservationMetric{
-				//DewptAvg:     types.NaN(),
-				PrecipTotal:  types.NaN(),
-				Pressure:     types.NaN(),
-				TempAvg:      types.NaN(),
-				WindspeedAvg: types.NaN(),
-			},
+			Group:       types.DPCTrusted,
+			Metric:      types.MissingMetric(),
 		}
 		/*
 			if relativeHumidityItem.SortKey == currentObs.SortKey() && currentObs.ObsTimeUtc.Equal(relativeHumidityItem.At) {
@@ -253,7 +406,12 @@ func mergeObservations(dataPath string, domain types.Domain /*, pressure, relati
 			}
 		*/
 		if temperatureItem.SortKey == currentObs.SortKey() && currentObs.ObsTimeUtc.Equal(temperatureItem.At) {
-			currentObs.Metric.TempAvg = temperatureItem.SensorValue()
+			unit, ok := temperatureUnits[station.ID]
+			if !ok {
+				unit = dewetraUnit(station, dewetraUnits.Temperature)
+				temperatureUnits[station.ID] = unit
+			}
+			currentObs.Metric.TempAvg = inBase(unit, temperatureItem.SensorValue())
 			temperatureIdx++
 		}
 		/*
@@ -305,7 +463,6 @@ func mergeObservations(dataPath string, domain types.Domain /*, pressure, relati
 
 		// convert temperatures from °celsius to °kelvin
 		//currentObs.Metric.DewptAvg += 273.15
-		currentObs.Metric.TempAvg += 273.15
 
 		// convert pression from hPa to Pa
 		//currentObs.Metric.Pressure *= 100
@@ -354,11 +511,42 @@ type sensorData struct {
 	Values   []float64
 }
 
+// dewetraUnits are the units of values of Dewetra
+// sensors whose registry doesn't report one.
+var dewetraUnits = units.Source{
+	Temperature:   units.Celsius,
+	Speed:         units.KilometersPerHour,
+	Pressure:      units.Hectopascal,
+	Precipitation: units.Millimeter,
+}
+
+// dewetraUnit returns the unit of values of sensor,
+// parsed from its MU as a quantity of the same kind of
+// defaultUnit, or defaultUnit when MU is missing.
+// Registries often declare a wrong MU (e.g. Km/h for
+// TERMOMETRO sensors): in that case a warning is
+// logged and defaultUnit is returned.
+func dewetraUnit(sensor sensorAnag, defaultUnit units.Unit) units.Unit {
+	if sensor.MU == "" {
+		return defaultUnit
+	}
+	unit, err := units.Parse(defaultUnit.Quantity, sensor.MU)
+	if err != nil {
+		log.Printf("sensor %s: %s, using %s", sensor.ID, err, defaultUnit.Symbol)
+		return defaultUnit
+	}
+	return unit
+}
+
 type sensorAnag struct {
-	ID                  string
-	Name                string
-	MU                  string
-	Lng, Lat, Elevation float64
+	ID        string
+	Name      string
+	MU        string
+	Lng, Lat  float64
+	Elevation float64 `json:"-"`
+	// ReportedElevation is the elevation
+	// of the sensor in the registry, if any.
+	ReportedElevation *float64 `json:"elevation"`
 }
 
 /*
@@ -385,11 +573,11 @@ func minObservation(results ...types.Result) types.Result {
 	return min
 }
 */
-func openSensorsMap(dataPath string, domain types.Domain, sensorClass string) (map[string]sensorAnag, error) {
+func openSensorsMap(dataPath string, domain types.Region, sensorClass string, source ElevationSource) (map[string]sensorAnag, error) {
 	sensorsTable := map[string]sensorAnag{}
 	//fmt.Println("openSensorsMap", sensorClass, domain)
 
-	err := fillSensorsMap(dataPath, domain, sensorClass, sensorsTable)
+	err := fillSensorsMap(dataPath, domain, sensorClass, source, sensorsTable)
 	if err != nil {
 		return nil, err
 	}
@@ -397,7 +585,7 @@ func openSensorsMap(dataPath string, domain types.Domain, sensorClass string) (m
 	return sensorsTable, nil
 }
 
-func fillSensorsMap(dataPath string, domain types.Domain, sensorClass string, sensorsTable map[string]sensorAnag) error {
+func fillSensorsMap(dataPath string, domain types.Region, sensorClass string, source ElevationSource, sensorsTable map[string]sensorAnag) error {
 	//fmt.Printf("fillSensorsMap %s\n", sensorClass)
 
 	sensorsAnag := []sensorAnag{}
@@ -413,9 +601,12 @@ func fillSensorsMap(dataPath string, domain types.Domain, sensorClass string, se
 	}
 
 	for _, sensor := range sensorsAnag {
-		if sensor.Lat >= domain.MinLat && sensor.Lat <= domain.MaxLat &&
-			sensor.Lng >= domain.MinLon && sensor.Lng <= domain.MaxLon {
-			sensor.Elevation = elevations.GetFromCoord(sensor.Lat, sensor.Lng)
+		if domain.Contains(sensor.Lat, sensor.Lng) {
+			reported := math.NaN()
+			if sensor.ReportedElevation != nil {
+				reported = *sensor.ReportedElevation
+			}
+			sensor.Elevation = source.stationElevation(sensor.ID, reported, sensor.Lat, sensor.Lng)
 			if _, exists := sensorsTable[sensor.ID]; exists {
 				return fmt.Errorf("sensor exists with id %s", sensor.ID)
 			}
@@ -428,37 +619,37 @@ func fillSensorsMap(dataPath string, domain types.Domain, sensorClass string, se
 	return nil
 }
 
-func openCompleteSensorsMap(dataPath string, domain types.Domain) (map[string]sensorAnag, error) {
+func openCompleteSensorsMap(dataPath string, domain types.Region, source ElevationSource) (map[string]sensorAnag, error) {
 	sensorsTable := map[string]sensorAnag{}
 
 	//fmt.Println("openCompleteSensorsMap", domain)
 	/*
-		err := fillSensorsMap(dataPath, domain, "IGROMETRO", sensorsTable)
+		err := fillSensorsMap(dataPath, domain, "IGROMETRO", source, sensorsTable)
 		if err != nil {
 			return nil, err
 		}
 	*/
-	err := fillSensorsMap(dataPath, domain, "TERMOMETRO", sensorsTable)
+	err := fillSensorsMap(dataPath, domain, "TERMOMETRO", source, sensorsTable)
 	if err != nil {
 		return nil, err
 	}
 	/*
-		err = fillSensorsMap(dataPath, domain, "DIREZIONEVENTO", sensorsTable)
+		err = fillSensorsMap(dataPath, domain, "DIREZIONEVENTO", source, sensorsTable)
 		if err != nil {
 			return nil, err
 		}
 
-		err = fillSensorsMap(dataPath, domain, "ANEMOMETRO", sensorsTable)
+		err = fillSensorsMap(dataPath, domain, "ANEMOMETRO", source, sensorsTable)
 		if err != nil {
 			return nil, err
 		}
 
-		err = fillSensorsMap(dataPath, domain, "PLUVIOMETRO", sensorsTable)
+		err = fillSensorsMap(dataPath, domain, "PLUVIOMETRO", source, sensorsTable)
 		if err != nil {
 			return nil, err
 		}
 
-		err = fillSensorsMap(dataPath, domain, "BAROMETRO", sensorsTable)
+		err = fillSensorsMap(dataPath, domain, "BAROMETRO", source, sensorsTable)
 		if err != nil {
 			return nil, err
 		}
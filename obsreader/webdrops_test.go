@@ -0,0 +1,60 @@
+package obsreader
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDewetraWind(t *testing.T) {
+	end := time.Date(2020, 3, 30, 18, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return end.Add(time.Duration(minutes) * time.Minute)
+	}
+	speeds := []dewetraSample{{at(-20), 9}, {at(-10), 4}, {at(-5), 6}, {at(0), 5}, {at(5), 9}}
+	directions := []dewetraSample{{at(-20), 180}, {at(-10), 350}, {at(-5), 10}, {at(0), 0}}
+
+	speed, dir := dewetraWind(speeds, directions, end, 15*time.Minute)
+	assert.InDelta(t, 5, speed, 1e-9)
+	assert.InDelta(t, 360, dir, 1e-9)
+
+	// a speed without direction
+	speed, dir = dewetraWind(speeds, directions, at(5), 5*time.Minute)
+	assert.Equal(t, 9.0, speed)
+	assert.True(t, math.IsNaN(dir))
+
+	speed, _ = dewetraWind(speeds, directions, at(30), 10*time.Minute)
+	assert.True(t, math.IsNaN(speed))
+}
+
+func TestWebdropsReadAll(t *testing.T) {
+	domain, err := types.DomainFromS("44,45,8,9")
+	assert.NoError(t, err)
+	date := time.Date(2021, 3, 14, 22, 0, 0, 0, time.UTC)
+	r := WebdropsObsReader{ElevationSource: Reported, PrecipPeriod: time.Hour, WindWindow: 10 * time.Minute}
+
+	// fixtures registries declare Km/h for every sensor
+	observations, err := r.ReadAll("../fixtures", domain, date)
+	assert.NoError(t, err)
+	assert.Len(t, observations, 1)
+	obs := observations[0]
+	assert.Equal(t, "210797226_2_06", obs.StationID)
+	assert.Equal(t, date, obs.ObsTimeUtc)
+	assert.True(t, obs.Metric.TempAvg.IsNaN())
+
+	// values are read in the default unit of their class
+	dir := t.TempDir()
+	registry := `[{"id":"T1","name":"Arenzano","lat":44.4051,"lng":8.67035,"mu":"Km/h"}]`
+	data := `[{"sensorId":"T1","timeline":["2021-03-14T22:00:00Z"],"values":[12]}]`
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "TERMOMETRO-registry.json"), []byte(registry), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "TERMOMETRO.json"), []byte(data), 0644))
+	observations, err = WebdropsObsReader{ElevationSource: Reported}.ReadAll(dir, domain, date)
+	assert.NoError(t, err)
+	assert.Len(t, observations, 1)
+	assert.InDelta(t, 285.15, observations[0].Metric.TempAvg.AsFloat(), 1e-9)
+}
@@ -0,0 +1,61 @@
+package obsreader
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/conversion"
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// WRFASCIIObsReader reads observations from
+// files in WRFDA ob.ascii format, like the ones
+// written by dewetra2wrf.Convert. Only the first
+// level of each report is used.
+type WRFASCIIObsReader struct {
+	// ElevationSource tells where the
+	// elevation of stations is taken from.
+	ElevationSource ElevationSource
+}
+
+// ReadAll implements ObsReader for WRFASCIIObsReader.
+// dataPath could be a single file or a directory
+// containing many of them. For each station, only the
+// report closest to date within 30 minutes is returned.
+func (r WRFASCIIObsReader) ReadAll(dataPath string, domain types.Region, date time.Time) ([]types.Observation, error) {
+	files, err := inputFiles(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	observations := []types.Observation{}
+	for _, file := range files {
+		reports, err := readWRFASCIIFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, report := range reports {
+			obs := report.Observation()
+			if domain.Contains(obs.Lat, obs.Lon) {
+				obs.Elevation = r.ElevationSource.stationElevation(obs.StationID, obs.Elevation, obs.Lat, obs.Lon)
+				observations = append(observations, obs)
+			}
+		}
+	}
+
+	return closestToDate(observations, date, 30*time.Minute), nil
+}
+
+func readWRFASCIIFile(file string) ([]conversion.Report, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reports, err := conversion.ReadWRFASCII(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return reports, nil
+}
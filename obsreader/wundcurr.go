@@ -2,21 +2,34 @@ package obsreader
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"math"
 	"path/filepath"
 	"time"
 
-	"github.com/meteocima/dewetra2wrf/elevations"
+	"github.com/meteocima/dewetra2wrf/precipitation"
+	"github.com/meteocima/dewetra2wrf/pressure"
 	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/meteocima/dewetra2wrf/units"
 )
 
 // WundCurrentObsReader reads
 // observations from JSON files as returned
 // from wunderground 'current' web API.
-type WundCurrentObsReader struct{}
+type WundCurrentObsReader struct {
+	// ElevationSource tells where the
+	// elevation of stations is taken from.
+	ElevationSource ElevationSource
+	// PrecipPeriod, when not zero, is the period over
+	// which precipitation is accumulated, computed from
+	// observations of previous hours, instead of the
+	// daily accumulation reported by stations.
+	PrecipPeriod time.Duration
+}
 
 // ReadAll implements ObsReader for WundCurrentObsReader
-func (r WundCurrentObsReader) ReadAll(dataPath string, domain types.Domain, date time.Time) ([]types.Observation, error) {
+func (r WundCurrentObsReader) ReadAll(dataPath string, domain types.Region, date time.Time) ([]types.Observation, error) {
 	dateDir := filepath.Join(dataPath, date.Format("2006010215"))
 	files, err := ioutil.ReadDir(dateDir)
 	if err != nil {
@@ -24,28 +37,41 @@ func (r WundCurrentObsReader) ReadAll(dataPath string, domain types.Domain, date
 	}
 	observations := []types.Observation{}
 
+	var precipSeries map[string]*precipitation.Series
+	if r.PrecipPeriod > 0 {
+		precipSeries, err = wundCurrentSeries(dataPath, date, r.PrecipPeriod)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	for _, f := range files {
 		obsBuf, err := ioutil.ReadFile(filepath.Join(dateDir, f.Name()))
 		if err != nil {
 			return nil, err
 		}
-		var obs types.Observation
-		err = json.Unmarshal(obsBuf, &obs)
+		var wundObs wundObservation
+		err = json.Unmarshal(obsBuf, &wundObs)
 		if err != nil {
 			return nil, err
 		}
-		if obs.Lat <= domain.MaxLat && obs.Lat >= domain.MinLat &&
-			obs.Lon <= domain.MaxLon && obs.Lon >= domain.MinLon {
+		obs, err := wundObs.observation()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name(), err)
+		}
+		if domain.Contains(obs.Lat, obs.Lon) {
 
-			obs.Elevation = elevations.GetFromCoord(obs.Lat, obs.Lon)
+			obs.Elevation = r.ElevationSource.stationElevation(obs.StationID, wundElevation(obs), obs.Lat, obs.Lon)
 			obs.StationName = obs.StationID
-			obs.Metric.Pressure = types.Value((obs.Metric.PressureMax + obs.Metric.PressureMin) / 2)
-			// convert temperatures from °celsius to °kelvin
-			obs.Metric.TempAvg += 273.15
-			// convert wind speed from km/h into m/s
-			obs.Metric.WindspeedAvg *= 0.277778
-			// convert pressure from hPa into Pa
-			obs.Metric.Pressure *= 100
+			obs.Group = types.Wunderground
+			wundPressure(&obs.Metric)
+			if r.PrecipPeriod > 0 {
+				obs.Metric.PrecipTotal = types.NaN()
+				if series, ok := precipSeries[obs.StationID]; ok {
+					obs.Metric.PrecipTotal = types.Value(series.Accumulate(obs.ObsTimeUtc, r.PrecipPeriod))
+				}
+				obs.PrecipPeriod = r.PrecipPeriod
+			}
 
 			observations = append(observations, obs)
 		}
@@ -53,3 +79,173 @@ func (r WundCurrentObsReader) ReadAll(dataPath string, domain types.Domain, date
 	}
 	return observations, nil
 }
+
+// wundPressure moves the pressure of m into SeaLevelPressure,
+// since Wunderground stations report pressure reduced to sea
+// level. History observations summarize it with PressureMin
+// and PressureMax, whose average is used instead.
+func wundPressure(m *types.ObservationMetric) {
+	p := m.Pressure
+	if !m.PressureMin.IsNaN() && !m.PressureMax.IsNaN() {
+		p = (m.PressureMax + m.PressureMin) / 2
+	}
+	m.Pressure = types.NaN()
+	setPressure(m, pressure.SeaLevel, p)
+}
+
+// wundElevation returns the elevation of the station
+// reported in obs, or NaN when missing.
+func wundElevation(obs types.Observation) float64 {
+	if obs.Metric.Elev == nil {
+		return math.NaN()
+	}
+	return obs.Metric.Elev.AsFloat()
+}
+
+// Units of Wunderground observations, requested
+// with `units` parameter set to `m`, `s`, `h` or `e`.
+var (
+	wundMetricUnits = units.Source{
+		Temperature:   units.Celsius,
+		Speed:         units.KilometersPerHour,
+		Pressure:      units.Hectopascal,
+		Precipitation: units.Millimeter,
+		Length:        units.Meter,
+	}
+	wundMetricSIUnits = units.Source{
+		Temperature:   units.Celsius,
+		Speed:         units.MetersPerSecond,
+		Pressure:      units.Hectopascal,
+		Precipitation: units.Millimeter,
+		Length:        units.Meter,
+	}
+	wundUKHybridUnits = units.Source{
+		Temperature:   units.Celsius,
+		Speed:         units.MilesPerHour,
+		Pressure:      units.Millibar,
+		Precipitation: units.Millimeter,
+		Length:        units.Foot,
+	}
+	wundImperialUnits = units.Source{
+		Temperature:   units.Fahrenheit,
+		Speed:         units.MilesPerHour,
+		Pressure:      units.InchesOfMercury,
+		Precipitation: units.Inch,
+		Length:        units.Foot,
+	}
+)
+
+// wundObservation is an observation as returned
+// from Wunderground web API. Its values are contained
+// in a single block, named after the units requested.
+type wundObservation struct {
+	types.Observation
+	Metric   *types.ObservationMetric
+	MetricSI *types.ObservationMetric `json:"metric_si"`
+	UKHybrid *types.ObservationMetric `json:"uk_hybrid"`
+	Imperial *types.ObservationMetric
+	// UvHigh, HumidityAvg and WinddirAvg
+	// are nil when missing.
+	UvHigh      *types.Value
+	HumidityAvg *types.Value
+	WinddirAvg  *types.Value
+}
+
+// valueOrNaN returns the value pointed by
+// v, or NaN when v is nil.
+func valueOrNaN(v *types.Value) types.Value {
+	if v == nil {
+		return types.NaN()
+	}
+	return *v
+}
+
+// observation returns the observation contained
+// in w, with values of its block of values
+// converted into the units of types.Observation.
+func (w wundObservation) observation() (types.Observation, error) {
+	obs, _, err := w.decode()
+	return obs, err
+}
+
+// decode returns the observation contained in w,
+// as returned by observation, and the units
+// of its block of values.
+func (w wundObservation) decode() (types.Observation, units.Source, error) {
+	obs := w.Observation
+	obs.UvHigh = valueOrNaN(w.UvHigh)
+	obs.HumidityAvg = valueOrNaN(w.HumidityAvg)
+	obs.WinddirAvg = valueOrNaN(w.WinddirAvg)
+	// Wunderground doesn't report visibility
+	obs.Visibility = types.NaN()
+	blocks := []struct {
+		values *types.ObservationMetric
+		units  units.Source
+	}{
+		{w.Metric, wundMetricUnits},
+		{w.MetricSI, wundMetricSIUnits},
+		{w.UKHybrid, wundUKHybridUnits},
+		{w.Imperial, wundImperialUnits},
+	}
+	for _, block := range blocks {
+		if block.values != nil {
+			obs.Metric = *block.values
+			toBaseUnits(&obs, block.units)
+			return obs, block.units, nil
+		}
+	}
+	return obs, units.Source{}, fmt.Errorf("observation of station %s without metric, metric_si, uk_hybrid or imperial values", obs.StationID)
+}
+
+// WundReport is an observation read from a
+// Wunderground JSON file, with the units its
+// station reported values in.
+type WundReport struct {
+	types.Observation
+	Units units.Source
+}
+
+// DecodeWunderground returns the observations contained
+// in content, a JSON file as returned from 'current' or
+// 'historical' Wunderground web API, with values converted
+// into the units of types.Observation as done by
+// WundCurrentObsReader and WundHistObsReader.
+func DecodeWunderground(content []byte) ([]WundReport, error) {
+	var obsList struct {
+		Observations []wundObservation
+	}
+	if err := json.Unmarshal(content, &obsList); err != nil {
+		return nil, err
+	}
+	if len(obsList.Observations) == 0 {
+		var w wundObservation
+		if err := json.Unmarshal(content, &w); err != nil {
+			return nil, err
+		}
+		obsList.Observations = []wundObservation{w}
+	}
+
+	reports := make([]WundReport, len(obsList.Observations))
+	for i, w := range obsList.Observations {
+		obs, source, err := w.decode()
+		if err != nil {
+			return nil, err
+		}
+		wundPressure(&obs.Metric)
+		reports[i] = WundReport{Observation: obs, Units: source}
+	}
+	return reports, nil
+}
+
+// wundObservations returns the observations
+// contained in list, as returned by observation.
+func wundObservations(list []wundObservation) ([]types.Observation, error) {
+	observations := make([]types.Observation, len(list))
+	for i, w := range list {
+		var err error
+		if observations[i], err = w.observation(); err != nil {
+			return nil, err
+		}
+	}
+	return observations, nil
+}
@@ -0,0 +1,89 @@
+package obsreader
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWundObservationUnits(t *testing.T) {
+	payloads := map[string]string{
+		"metric":    `"metric": {"tempAvg": 20, "dewptAvg": 10, "windspeedAvg": 36, "pressureMax": 1013.25, "pressureMin": 1013.25, "precipTotal": 2.54, "elev": 30.48}`,
+		"metric_si": `"metric_si": {"tempAvg": 20, "dewptAvg": 10, "windspeedAvg": 10, "pressureMax": 1013.25, "pressureMin": 1013.25, "precipTotal": 2.54, "elev": 30.48}`,
+		"uk_hybrid": `"uk_hybrid": {"tempAvg": 20, "dewptAvg": 10, "windspeedAvg": 22.369, "pressureMax": 1013.25, "pressureMin": 1013.25, "precipTotal": 2.54, "elev": 100}`,
+		"imperial":  `"imperial": {"tempAvg": 68, "dewptAvg": 50, "windspeedAvg": 22.369, "pressureMax": 29.921, "pressureMin": 29.921, "precipTotal": 0.1, "elev": 100}`,
+	}
+	for name, values := range payloads {
+		var w wundObservation
+		err := json.Unmarshal([]byte(`{"stationID": "IGENOVA1", "lat": 44.4, "lon": 8.9, `+values+`}`), &w)
+		assert.NoError(t, err, name)
+		obs, err := w.observation()
+		assert.NoError(t, err, name)
+
+		assert.Equal(t, "IGENOVA1", obs.StationID, name)
+		assert.InDelta(t, 293.15, obs.Metric.TempAvg.AsFloat(), 0.01, name)
+		assert.InDelta(t, 283.15, obs.Metric.DewptAvg.AsFloat(), 0.01, name)
+		assert.InDelta(t, 10, obs.Metric.WindspeedAvg.AsFloat(), 0.01, name)
+		assert.InDelta(t, 101325, obs.Metric.PressureMax.AsFloat(), 1, name)
+		assert.InDelta(t, 101325, obs.Metric.PressureMin.AsFloat(), 1, name)
+		assert.InDelta(t, 2.54, obs.Metric.PrecipTotal.AsFloat(), 0.001, name)
+		assert.InDelta(t, 30.48, wundElevation(obs), 0.001, name)
+	}
+
+	var w wundObservation
+	err := json.Unmarshal([]byte(`{"stationID": "IGENOVA1", "lat": 44.4, "lon": 8.9}`), &w)
+	assert.NoError(t, err)
+	_, err = w.observation()
+	assert.EqualError(t, err, "observation of station IGENOVA1 without metric, metric_si, uk_hybrid or imperial values")
+}
+
+func TestWundObservationSummary(t *testing.T) {
+	var w wundObservation
+	err := json.Unmarshal([]byte(`{
+		"stationID": "IGENOVA1", "uvHigh": 3.2, "humidityAvg": 60,
+		"metric": {
+			"tempHigh": 22, "tempLow": 18, "tempAvg": 20, "dewptAvg": 12,
+			"windspeedAvg": 18, "windgustAvg": 36, "heatindexAvg": 21,
+			"pressureMax": 1014, "pressureMin": 1012, "precipRate": 1.5, "precipTotal": 0.5
+		}
+	}`), &w)
+	assert.NoError(t, err)
+	obs, err := w.observation()
+	assert.NoError(t, err)
+
+	assert.InDelta(t, 295.15, obs.Metric.TempHigh.AsFloat(), 0.001)
+	assert.InDelta(t, 291.15, obs.Metric.TempLow.AsFloat(), 0.001)
+	assert.InDelta(t, 285.15, obs.Metric.DewptAvg.AsFloat(), 0.001)
+	assert.InDelta(t, 294.15, obs.Metric.HeatindexAvg.AsFloat(), 0.001)
+	assert.InDelta(t, 10, obs.Metric.WindgustAvg.AsFloat(), 0.001)
+	assert.InDelta(t, 1.5, obs.Metric.PrecipRate.AsFloat(), 0.001)
+	assert.InDelta(t, 3.2, obs.UvHigh.AsFloat(), 0.001)
+	assert.True(t, obs.Metric.SeaLevelPressure.IsNaN())
+	assert.True(t, obs.Visibility.IsNaN())
+
+	var partial wundObservation
+	err = json.Unmarshal([]byte(`{"stationID": "IGENOVA2", "metric": {"tempAvg": 20}}`), &partial)
+	assert.NoError(t, err)
+	obs, err = partial.observation()
+	assert.NoError(t, err)
+	assert.True(t, obs.Metric.WindgustAvg.IsNaN())
+	assert.True(t, obs.Metric.DewptAvg.IsNaN())
+	assert.True(t, obs.UvHigh.IsNaN())
+}
+
+func TestWundPressure(t *testing.T) {
+	history := types.MissingMetric()
+	history.PressureMax = 101400
+	history.PressureMin = 101200
+	wundPressure(&history)
+	assert.Equal(t, types.Value(101300), history.SeaLevelPressure)
+	assert.True(t, history.Pressure.IsNaN())
+
+	current := types.MissingMetric()
+	current.Pressure = 101500
+	wundPressure(&current)
+	assert.Equal(t, types.Value(101500), current.SeaLevelPressure)
+	assert.True(t, current.Pressure.IsNaN())
+}
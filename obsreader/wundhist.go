@@ -2,22 +2,31 @@ package obsreader
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"math"
 	"path/filepath"
 	"time"
 
-	"github.com/meteocima/dewetra2wrf/elevations"
 	"github.com/meteocima/dewetra2wrf/types"
 )
 
 // WundHistObsReader reads
 // observations from JSON files as returned
 // from wunderground 'historical' web API.
-type WundHistObsReader struct{}
+type WundHistObsReader struct {
+	// ElevationSource tells where the
+	// elevation of stations is taken from.
+	ElevationSource ElevationSource
+	// PrecipPeriod, when not zero, is the period over
+	// which precipitation is accumulated, computed from
+	// the observations of the same file, instead of the
+	// daily accumulation reported by stations.
+	PrecipPeriod time.Duration
+}
 
 // ReadAll implements ObsReader for WundHistObsReader
-func (r WundHistObsReader) ReadAll(dataPath string, domain types.Domain, date time.Time) ([]types.Observation, error) {
+func (r WundHistObsReader) ReadAll(dataPath string, domain types.Region, date time.Time) ([]types.Observation, error) {
 	var dateDir string
 	if !date.IsZero() {
 		dateDir = filepath.Join(dataPath, date.Format("20060102"))
@@ -37,39 +46,38 @@ func (r WundHistObsReader) ReadAll(dataPath string, domain types.Domain, date ti
 			return nil, err
 		}
 		var obsList struct {
-			Observations []types.Observation
+			Observations []wundObservation
 		}
 
 		err = json.Unmarshal(obsBuf, &obsList)
 		if err != nil {
 			return nil, err
 		}
+		list, err := wundObservations(obsList.Observations)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name(), err)
+		}
 
-		if len(obsList.Observations) == 0 {
+		if len(list) == 0 {
 			continue
 		}
-		var obs types.Observation = obsList.Observations[0]
+		wundAccumulate(list, r.PrecipPeriod)
+		var obs types.Observation = list[0]
 
 		if date.IsZero() {
-			for _, obs := range obsList.Observations {
-				obs.Elevation = elevations.GetFromCoord(obs.Lat, obs.Lon)
+			for _, obs := range list {
+				obs.Elevation = r.ElevationSource.stationElevation(obs.StationID, wundElevation(obs), obs.Lat, obs.Lon)
 				obs.StationName = obs.StationID
-				obs.Metric.Pressure = types.Value((obs.Metric.PressureMax + obs.Metric.PressureMin) / 2)
-				// convert temperatures from °celsius to °kelvin
-				obs.Metric.TempAvg += 273.15
-				// convert wind speed from km/h into m/s
-				obs.Metric.WindspeedAvg *= 0.277778
-				// convert pressure from mbar into Pa
-				obs.Metric.Pressure *= 100
+				obs.Group = types.Wunderground
+				wundPressure(&obs.Metric)
 
 				observations = append(observations, obs)
 			}
 		} else {
-			if obs.Lat <= domain.MaxLat && obs.Lat >= domain.MinLat &&
-				obs.Lon <= domain.MaxLon && obs.Lon >= domain.MinLon {
+			if domain.Contains(obs.Lat, obs.Lon) {
 
 				minDeltaMin := 30.0
-				for _, o := range obsList.Observations {
+				for _, o := range list {
 
 					delta := math.Abs(date.Sub(o.ObsTimeUtc).Minutes())
 					if delta < minDeltaMin {
@@ -81,15 +89,10 @@ func (r WundHistObsReader) ReadAll(dataPath string, domain types.Domain, date ti
 					}
 				}
 
-				obs.Elevation = elevations.GetFromCoord(obs.Lat, obs.Lon)
+				obs.Elevation = r.ElevationSource.stationElevation(obs.StationID, wundElevation(obs), obs.Lat, obs.Lon)
 				obs.StationName = obs.StationID
-				obs.Metric.Pressure = types.Value((obs.Metric.PressureMax + obs.Metric.PressureMin) / 2)
-				// convert temperatures from °celsius to °kelvin
-				obs.Metric.TempAvg += 273.15
-				// convert wind speed from km/h into m/s
-				obs.Metric.WindspeedAvg *= 0.277778
-				// convert pressure from mbar into Pa
-				obs.Metric.Pressure *= 100
+				obs.Group = types.Wunderground
+				wundPressure(&obs.Metric)
 
 				observations = append(observations, obs)
 			}
@@ -0,0 +1,284 @@
+package obsreader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/meteocima/dewetra2wrf/units"
+)
+
+// WyomingObsReader reads radiosonde observations from
+// text soundings as published by the University of
+// Wyoming (TEXT:LIST format), archived on disk either as
+// HTML pages or as plain text.
+// Each sounding is returned as a multi-level FM-35 TEMP
+// observation.
+type WyomingObsReader struct {
+	// ElevationSource tells where the
+	// elevation of stations is taken from.
+	ElevationSource ElevationSource
+}
+
+// wyomingUnits are the units of values in columns
+// of the sounding table, as told by its units line.
+var wyomingUnits = units.Source{
+	Temperature: units.Celsius,
+	Speed:       units.Knots,
+	Pressure:    units.Hectopascal,
+	Length:      units.Meter,
+}
+
+// wyomingColumnWidth is the width of
+// each column of the sounding table.
+const wyomingColumnWidth = 7
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// wyomingSounding is a sounding read from a file,
+// with its table rows and metadata.
+type wyomingSounding struct {
+	title   string
+	columns []string
+	rows    []map[string]float64
+	meta    map[string]string
+}
+
+// splitWyomingColumns returns the trimmed
+// content of each column of line.
+func splitWyomingColumns(line string) []string {
+	cells := []string{}
+	for start := 0; start < len(line); start += wyomingColumnWidth {
+		end := start + wyomingColumnWidth
+		if end > len(line) {
+			end = len(line)
+		}
+		cells = append(cells, strings.TrimSpace(line[start:end]))
+	}
+	return cells
+}
+
+// isWyomingHeader returns whether line
+// is the header of a sounding table.
+func isWyomingHeader(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) > 2 && fields[0] == "PRES" && fields[1] == "HGHT"
+}
+
+// parseWyoming reads all soundings contained in content.
+func parseWyoming(content []byte) ([]*wyomingSounding, error) {
+	soundings := []*wyomingSounding{}
+	var current *wyomingSounding
+	title := ""
+	inTable := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		raw := strings.TrimRight(scanner.Text(), "\r")
+		line := htmlTag.ReplaceAllString(raw, "")
+
+		if strings.Contains(line, "Observations at") {
+			title = strings.TrimSpace(line)
+			continue
+		}
+
+		if isWyomingHeader(line) {
+			current = &wyomingSounding{
+				title:   title,
+				columns: splitWyomingColumns(line),
+				meta:    map[string]string{},
+			}
+			soundings = append(soundings, current)
+			title = ""
+			inTable = true
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		if inTable {
+			trimmed := strings.TrimSpace(line)
+			// units line and separators
+			if trimmed == "" || strings.HasPrefix(trimmed, "---") || strings.HasPrefix(trimmed, "hPa") {
+				continue
+			}
+			cells := splitWyomingColumns(line)
+			if _, err := strconv.ParseFloat(cells[0], 64); err == nil {
+				row := map[string]float64{}
+				for i, cell := range cells {
+					if i >= len(current.columns) || cell == "" {
+						continue
+					}
+					v, err := strconv.ParseFloat(cell, 64)
+					if err != nil {
+						return nil, fmt.Errorf("invalid value `%s` in column %s", cell, current.columns[i])
+					}
+					row[current.columns[i]] = v
+				}
+				current.rows = append(current.rows, row)
+				continue
+			}
+			inTable = false
+		}
+
+		if idx := strings.Index(line, ":"); idx > 0 {
+			key := strings.TrimSpace(line[:idx])
+			current.meta[key] = strings.TrimSpace(line[idx+1:])
+		}
+	}
+
+	return soundings, scanner.Err()
+}
+
+// metaNumber returns the numeric
+// value of a metadata entry, or NaN.
+func (s *wyomingSounding) metaNumber(key string) float64 {
+	v, err := strconv.ParseFloat(s.meta[key], 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return v
+}
+
+// rowValue returns the value of column
+// in row, or NaN if it's missing.
+func rowValue(row map[string]float64, column string) types.Value {
+	v, ok := row[column]
+	if !ok {
+		return types.NaN()
+	}
+	return types.Value(v)
+}
+
+// observation converts the sounding into a multi-level
+// types.Observation. Levels below the surface are dropped,
+// and the surface level fills the surface values.
+// It returns an error if the sounding lacks station
+// metadata or observation time.
+func (s *wyomingSounding) observation() (types.Observation, error) {
+	obs := missingObservation(types.PlatformTemp)
+	obs.Group = types.WMOStations
+
+	obs.StationID = s.meta["Station number"]
+	if obs.StationID == "" {
+		obs.StationID = s.meta["Station identifier"]
+	}
+	if obs.StationID == "" {
+		return obs, fmt.Errorf("sounding without station number")
+	}
+	obs.StationName = s.meta["Station identifier"]
+	// title is like `16080 LIML Milano Observations at 12Z 30 Mar 2020`
+	if idx := strings.Index(s.title, " Observations at"); idx > 0 {
+		words := strings.Fields(s.title[:idx])
+		for len(words) > 1 && (words[0] == obs.StationID || words[0] == s.meta["Station identifier"]) {
+			words = words[1:]
+		}
+		obs.StationName = strings.Join(words, " ")
+	}
+	if obs.StationName == "" {
+		obs.StationName = obs.StationID
+	}
+
+	obs.Lat = s.metaNumber("Station latitude")
+	obs.Lon = s.metaNumber("Station longitude")
+	obs.Elevation = s.metaNumber("Station elevation")
+	if math.IsNaN(obs.Lat) || math.IsNaN(obs.Lon) {
+		return obs, fmt.Errorf("station %s: sounding without coordinates", obs.StationID)
+	}
+
+	var err error
+	obs.ObsTimeUtc, err = time.Parse("060102/1504", s.meta["Observation time"])
+	if err != nil {
+		return obs, fmt.Errorf("station %s: %w", obs.StationID, err)
+	}
+
+	// find the surface, that is the level at station
+	// elevation: levels with higher pressures
+	// are extrapolated below ground.
+	surfacePressure := math.Inf(1)
+	for _, row := range s.rows {
+		if h, ok := row["HGHT"]; ok && h == obs.Elevation {
+			surfacePressure = row["PRES"]
+			break
+		}
+	}
+
+	for _, row := range s.rows {
+		if row["PRES"] > surfacePressure {
+			continue
+		}
+		level := types.Level{
+			Pressure:     inBase(wyomingUnits.Pressure, rowValue(row, "PRES")),
+			Height:       inBase(wyomingUnits.Length, rowValue(row, "HGHT")),
+			TempAvg:      inBase(wyomingUnits.Temperature, rowValue(row, "TEMP")),
+			DewptAvg:     inBase(wyomingUnits.Temperature, rowValue(row, "DWPT")),
+			HumidityAvg:  rowValue(row, "RELH"),
+			WinddirAvg:   rowValue(row, "DRCT"),
+			WindspeedAvg: inBase(wyomingUnits.Speed, rowValue(row, "SKNT")),
+		}
+		if level.TempAvg.IsNaN() && level.WindspeedAvg.IsNaN() {
+			continue
+		}
+		obs.Levels = append(obs.Levels, level)
+
+		if row["PRES"] == surfacePressure {
+			obs.Metric.Pressure = level.Pressure
+			obs.Metric.TempAvg = level.TempAvg
+			obs.Metric.DewptAvg = level.DewptAvg
+			obs.Metric.WindspeedAvg = level.WindspeedAvg
+			obs.WinddirAvg = level.WinddirAvg
+			obs.HumidityAvg = level.HumidityAvg
+		}
+	}
+
+	if len(obs.Levels) == 0 {
+		return obs, fmt.Errorf("station %s: sounding without levels", obs.StationID)
+	}
+
+	return obs, nil
+}
+
+// ReadAll implements ObsReader for WyomingObsReader.
+// dataPath could be a single file or a directory
+// containing many of them. Since soundings are launched
+// only at main synoptic hours, for each station the
+// sounding closest to date within 3 hours is returned.
+func (r WyomingObsReader) ReadAll(dataPath string, domain types.Region, date time.Time) ([]types.Observation, error) {
+	files, err := inputFiles(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	observations := []types.Observation{}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		soundings, err := parseWyoming(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, sounding := range soundings {
+			obs, err := sounding.observation()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			if domain.Contains(obs.Lat, obs.Lon) {
+				obs.Elevation = r.ElevationSource.stationElevation(obs.StationID, obs.Elevation, obs.Lat, obs.Lon)
+				observations = append(observations, obs)
+			}
+		}
+	}
+
+	return closestToDate(observations, date, 3*time.Hour), nil
+}
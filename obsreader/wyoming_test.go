@@ -0,0 +1,84 @@
+package obsreader
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var wyomingSoundingFixture = `<HTML>
+<TITLE>University of Wyoming - Radiosonde Data</TITLE>
+<BODY BGCOLOR="white">
+<H2>16080 LIML Milano Observations at 12Z 30 Mar 2020</H2>
+<PRE>
+-----------------------------------------------------------------------------
+   PRES   HGHT   TEMP   DWPT   RELH   MIXR   DRCT   SKNT   THTA   THTE   THTV
+    hPa     m      C      C      %    g/kg    deg   knot     K      K      K 
+-----------------------------------------------------------------------------
+ 1013.0     20                                                               
+ 1005.0    103   12.4    5.4     62   5.61    240      4  285.1  300.8  286.1
+ 1000.0    145   11.8    4.8     62   5.40    245      6  284.9  300.0  285.9
+  925.0    790    8.8    2.8     66   5.03    250     12  287.9  302.2  288.8
+  850.0   1490    3.2   -4.8     56   3.20    260     20  289.3  298.8  289.9
+  700.0   3050   -6.1                         270     35  295.6              
+</PRE><H3>Station information and sounding indices</H3><PRE>
+                         Station identifier: LIML
+                             Station number: 16080
+                           Observation time: 200330/1200
+                           Station latitude: 45.43
+                          Station longitude: 9.28
+                          Station elevation: 103.0
+                            Showalter index: 8.66
+</PRE>
+</BODY></HTML>
+`
+
+func TestWyoming(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "16080.html")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(wyomingSoundingFixture), 0644))
+
+	observations, err := WyomingObsReader{}.ReadAll(file, allDomain, time.Date(2020, 3, 30, 12, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(observations))
+
+	obs := observations[0]
+	assert.Equal(t, types.PlatformTemp, obs.PlatformType())
+	assert.Equal(t, "16080", obs.StationID)
+	assert.Equal(t, "Milano", obs.StationName)
+	assert.Equal(t, 45.43, obs.Lat)
+	assert.Equal(t, 9.28, obs.Lon)
+	assert.Equal(t, 103.0, obs.Elevation)
+	assert.Equal(t, time.Date(2020, 3, 30, 12, 0, 0, 0, time.UTC), obs.ObsTimeUtc)
+
+	// 1013 hPa level is below ground
+	assert.Equal(t, 5, len(obs.Levels))
+	surface := obs.Levels[0]
+	assert.InDelta(t, 100500, surface.Pressure.AsFloat(), 1e-6)
+	assert.Equal(t, types.Value(103), surface.Height)
+	assert.InDelta(t, 285.55, surface.TempAvg.AsFloat(), 1e-6)
+	assert.InDelta(t, 278.55, surface.DewptAvg.AsFloat(), 1e-6)
+	assert.Equal(t, types.Value(62), surface.HumidityAvg)
+	assert.Equal(t, types.Value(240), surface.WinddirAvg)
+	assert.InDelta(t, 2.057776, surface.WindspeedAvg.AsFloat(), 1e-6)
+	assert.Equal(t, surface.TempAvg, obs.Metric.TempAvg)
+	assert.Equal(t, surface.Pressure, obs.Metric.Pressure)
+
+	top := obs.Levels[4]
+	assert.InDelta(t, 70000, top.Pressure.AsFloat(), 1e-6)
+	assert.True(t, top.DewptAvg.IsNaN())
+	assert.True(t, top.HumidityAvg.IsNaN())
+	assert.InDelta(t, 18.00554, top.WindspeedAvg.AsFloat(), 1e-6)
+}
+
+func TestWyomingOutOfWindow(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "16080.txt")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(wyomingSoundingFixture), 0644))
+
+	observations, err := WyomingObsReader{}.ReadAll(file, allDomain, time.Date(2020, 3, 30, 18, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(observations))
+}
@@ -0,0 +1,148 @@
+// Package precipitation normalises precipitation
+// measured by rain gauges, as reported by different
+// sources, into amounts accumulated over a period.
+package precipitation
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Kind tells how a gauge reports precipitation.
+type Kind int
+
+const (
+	// Interval samples contain the amount fallen since the
+	// previous sample, e.g. Dewetra PLUVIOMETRO sensors.
+	Interval Kind = iota
+	// Counter samples contain a running total, periodically
+	// reset to zero, e.g. the daily accumulation reported
+	// by Wunderground stations.
+	Counter
+)
+
+// Sample is the value reported by a gauge
+// at a time, in mm, or NaN when missing.
+type Sample struct {
+	At    time.Time
+	Value float64
+}
+
+// Series is the timeline of samples of a single gauge.
+type Series struct {
+	Kind Kind
+	// Step is the time between consecutive samples. When
+	// zero, it's the median interval between samples.
+	Step    time.Duration
+	Samples []Sample
+}
+
+// Add appends a sample to the series.
+func (s *Series) Add(at time.Time, value float64) {
+	s.Samples = append(s.Samples, Sample{At: at, Value: value})
+}
+
+// sorted returns the samples of the series sorted by time,
+// keeping only the last one of samples with the same time.
+func (s Series) sorted() []Sample {
+	samples := make([]Sample, len(s.Samples))
+	copy(samples, s.Samples)
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].At.Before(samples[j].At)
+	})
+	unique := samples[:0]
+	for _, sample := range samples {
+		if len(unique) > 0 && unique[len(unique)-1].At.Equal(sample.At) {
+			unique[len(unique)-1] = sample
+			continue
+		}
+		unique = append(unique, sample)
+	}
+	return unique
+}
+
+// step returns the time between consecutive samples,
+// or zero if there are less than two of them.
+func (s Series) step(samples []Sample) time.Duration {
+	if s.Step > 0 || len(samples) < 2 {
+		return s.Step
+	}
+	intervals := make([]time.Duration, len(samples)-1)
+	for i := range intervals {
+		intervals[i] = samples[i+1].At.Sub(samples[i].At)
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i] < intervals[j]
+	})
+	return intervals[(len(intervals)-1)/2]
+}
+
+// Accumulate returns the amount of precipitation fallen
+// in the period ending at end, or NaN when samples don't
+// cover the whole period, or some of them are missing.
+// Samples are allowed to be late or early by half a step.
+// A Counter whose value decreases is assumed to be reset
+// to zero before the sample with the lower value.
+func (s Series) Accumulate(end time.Time, period time.Duration) float64 {
+	samples := s.sorted()
+	step := s.step(samples)
+	if step <= 0 || period <= 0 {
+		return math.NaN()
+	}
+	tolerance := step / 2
+	start := end.Add(-period)
+
+	var window []Sample
+	if s.Kind == Interval {
+		// each sample covers the step before it,
+		// so the one at start is not included.
+		for _, sample := range samples {
+			if sample.At.After(start.Add(tolerance)) && !sample.At.After(end.Add(tolerance)) {
+				window = append(window, sample)
+			}
+		}
+		if len(window) == 0 || window[0].At.After(start.Add(step+tolerance)) {
+			return math.NaN()
+		}
+	} else {
+		// the sample at start is the
+		// base of the accumulation.
+		for _, sample := range samples {
+			if !sample.At.Before(start.Add(-tolerance)) && !sample.At.After(end.Add(tolerance)) {
+				window = append(window, sample)
+			}
+		}
+		if len(window) < 2 || window[0].At.After(start.Add(tolerance)) {
+			return math.NaN()
+		}
+	}
+	if window[len(window)-1].At.Before(end.Add(-tolerance)) {
+		return math.NaN()
+	}
+
+	total := 0.0
+	for i, sample := range window {
+		if math.IsNaN(sample.Value) {
+			return math.NaN()
+		}
+		if i > 0 && sample.At.Sub(window[i-1].At) > step+tolerance {
+			// a gap in the record
+			return math.NaN()
+		}
+		if s.Kind == Interval {
+			total += sample.Value
+			continue
+		}
+		if i == 0 {
+			continue
+		}
+		if increment := sample.Value - window[i-1].Value; increment >= 0 {
+			total += increment
+		} else {
+			// counter reset
+			total += sample.Value
+		}
+	}
+	return total
+}
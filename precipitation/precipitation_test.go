@@ -0,0 +1,61 @@
+package precipitation
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var t0 = time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC)
+
+func hourly(kind Kind, values ...float64) Series {
+	s := Series{Kind: kind}
+	for i, v := range values {
+		s.Add(t0.Add(time.Duration(i)*time.Hour), v)
+	}
+	return s
+}
+
+func TestAccumulateInterval(t *testing.T) {
+	s := hourly(Interval, 9, 1, 2, 0, 3, 4, 0)
+	assert.Equal(t, 3.0, s.Accumulate(t0.Add(3*time.Hour), 3*time.Hour))
+	assert.Equal(t, 7.0, s.Accumulate(t0.Add(6*time.Hour), 3*time.Hour))
+	assert.Equal(t, 4.0, s.Accumulate(t0.Add(5*time.Hour+10*time.Minute), time.Hour))
+	// the first sample covers the hour before t0
+	assert.Equal(t, 19.0, s.Accumulate(t0.Add(6*time.Hour), 7*time.Hour))
+	assert.True(t, math.IsNaN(s.Accumulate(t0.Add(6*time.Hour), 8*time.Hour)))
+	assert.True(t, math.IsNaN(s.Accumulate(t0.Add(8*time.Hour), time.Hour)))
+}
+
+func TestAccumulateCounter(t *testing.T) {
+	// daily accumulation reset at the fourth sample
+	s := hourly(Counter, 10, 11, 13, 0.5, 2.5, 2.5)
+	assert.Equal(t, 3.0, s.Accumulate(t0.Add(2*time.Hour), 2*time.Hour))
+	assert.Equal(t, 4.5, s.Accumulate(t0.Add(4*time.Hour), 3*time.Hour))
+	assert.Equal(t, 5.5, s.Accumulate(t0.Add(5*time.Hour), 5*time.Hour))
+	// no sample at the start of the period
+	assert.True(t, math.IsNaN(s.Accumulate(t0.Add(5*time.Hour), 6*time.Hour)))
+}
+
+func TestAccumulateMissing(t *testing.T) {
+	gap := hourly(Interval, 1, 1, 1, 1)
+	gap.Samples = append(gap.Samples[:1], gap.Samples[2:]...)
+	assert.True(t, math.IsNaN(gap.Accumulate(t0.Add(3*time.Hour), 3*time.Hour)))
+	assert.Equal(t, 1.0, gap.Accumulate(t0.Add(3*time.Hour), time.Hour))
+
+	nan := hourly(Counter, 1, math.NaN(), 3)
+	assert.True(t, math.IsNaN(nan.Accumulate(t0.Add(2*time.Hour), 2*time.Hour)))
+	assert.True(t, math.IsNaN(Series{}.Accumulate(t0, time.Hour)))
+}
+
+func TestAccumulateStep(t *testing.T) {
+	// samples every 10 minutes, unsorted and with a duplicate
+	s := Series{Kind: Interval}
+	for i := 6; i >= 0; i-- {
+		s.Add(t0.Add(time.Duration(i)*10*time.Minute), 0.5)
+	}
+	s.Add(t0.Add(60*time.Minute), 1)
+	assert.Equal(t, 3.5, s.Accumulate(t0.Add(time.Hour), time.Hour))
+}
@@ -0,0 +1,63 @@
+package dewetra2wrf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/obsreader"
+	"github.com/meteocima/dewetra2wrf/pressure"
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// completePressure fills the missing one between station
+// pressure and sea level pressure of observations that
+// report only the other, reducing it with the 12 hours
+// mean temperature of the station. When readBefore is true,
+// temperatures of 12 hours before date are read from inputpath
+// again, returning any error; otherwise, or for stations
+// not found, the current temperature is used.
+// Station pressure is never derived from altimeter settings,
+// that are reduced with the standard atmosphere.
+func completePressure(reader obsreader.ObsReader, inputpath string, domain types.Region, date time.Time, observations []types.Observation, readBefore bool) ([]types.Observation, error) {
+	key := func(obs types.Observation) string {
+		return fmt.Sprintf("%s:%05f:%05f", obs.StationID, obs.Lat, obs.Lon)
+	}
+
+	incomplete := false
+	for _, obs := range observations {
+		if obs.Metric.Pressure.IsNaN() != obs.Metric.SeaLevelPressure.IsNaN() {
+			incomplete = true
+			break
+		}
+	}
+	if !incomplete {
+		return observations, nil
+	}
+
+	before := map[string]types.Value{}
+	if readBefore {
+		previous, err := reader.ReadAll(inputpath, domain, date.Add(-12*time.Hour))
+		if err != nil {
+			return nil, fmt.Errorf("reading temperatures of 12 hours before: %w", err)
+		}
+		for _, obs := range previous {
+			before[key(obs)] = obs.Metric.TempAvg
+		}
+	}
+
+	for i, obs := range observations {
+		tempBefore, ok := before[key(obs)]
+		if !ok {
+			tempBefore = types.NaN()
+		}
+		meanTemp := pressure.MeanTemperature(obs.Metric.TempAvg.AsFloat(), tempBefore.AsFloat())
+
+		m := &observations[i].Metric
+		if m.SeaLevelPressure.IsNaN() {
+			m.SeaLevelPressure = types.Value(pressure.ToSeaLevel(m.Pressure.AsFloat(), obs.Elevation, meanTemp))
+		} else if m.Pressure.IsNaN() && !obs.AltimeterSetting {
+			m.Pressure = types.Value(pressure.ToStation(m.SeaLevelPressure.AsFloat(), obs.Elevation, meanTemp))
+		}
+	}
+	return observations, nil
+}
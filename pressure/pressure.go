@@ -0,0 +1,82 @@
+// Package pressure tells apart the atmospheric pressure
+// measured at station elevation from the one reduced to
+// mean sea level, and converts between the two.
+// Pressures are in Pa, temperatures in K and
+// elevations in m.
+package pressure
+
+import (
+	"fmt"
+	"math"
+)
+
+// Kind tells at which level a pressure is reported.
+type Kind int
+
+const (
+	// Station pressure is measured at station elevation (QFE).
+	Station Kind = iota
+	// SeaLevel pressure is reduced to mean sea level
+	// (QFF or QNH), as reported e.g. by METAR,
+	// Wunderground and Netatmo stations.
+	SeaLevel
+)
+
+func (k Kind) String() string {
+	if k == Station {
+		return "station"
+	}
+	if k == SeaLevel {
+		return "sea level"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+const (
+	// gravity is the standard acceleration of gravity, in m/s²
+	gravity = 9.80665
+	// gasConstant is the specific gas constant of dry air, in J/(kg K)
+	gasConstant = 287.05
+	// lapseRate is the temperature gradient of the
+	// standard atmosphere, in K/m
+	lapseRate = 0.0065
+)
+
+// MeanTemperature returns the 12 hours mean temperature
+// used to reduce pressure, from the temperature of the
+// station now and 12 hours before, so that the daily
+// cycle of temperature doesn't affect the reduction.
+// When the temperature 12 hours before is missing,
+// the current one is returned.
+func MeanTemperature(now, before float64) float64 {
+	if math.IsNaN(before) {
+		return now
+	}
+	return (now + before) / 2
+}
+
+// factor returns the ratio between sea level pressure and
+// station pressure, according to the hypsometric equation
+// applied to a fictitious air column between the station
+// and sea level, whose mean temperature is meanTemp
+// increased by half the standard lapse rate over elevation.
+func factor(elevation, meanTemp float64) float64 {
+	columnTemp := meanTemp + lapseRate*elevation/2
+	return math.Exp(gravity * elevation / (gasConstant * columnTemp))
+}
+
+// ToSeaLevel reduces pressure p measured at a station
+// with given elevation to mean sea level, using the
+// 12 hours mean temperature of the station meanTemp
+// (see MeanTemperature). It returns NaN when any
+// argument is NaN.
+func ToSeaLevel(p, elevation, meanTemp float64) float64 {
+	return p * factor(elevation, meanTemp)
+}
+
+// ToStation is the inverse of ToSeaLevel: it returns the
+// pressure at a station with given elevation from the
+// sea level pressure p0.
+func ToStation(p0, elevation, meanTemp float64) float64 {
+	return p0 / factor(elevation, meanTemp)
+}
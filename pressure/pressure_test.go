@@ -0,0 +1,30 @@
+package pressure
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeanTemperature(t *testing.T) {
+	assert.Equal(t, 285.0, MeanTemperature(290, 280))
+	assert.Equal(t, 290.0, MeanTemperature(290, math.NaN()))
+	assert.True(t, math.IsNaN(MeanTemperature(math.NaN(), 280)))
+}
+
+func TestToSeaLevel(t *testing.T) {
+	assert.Equal(t, 101325.0, ToSeaLevel(101325, 0, 288.15))
+	// standard atmosphere at 1000 m
+	assert.InDelta(t, 101325, ToSeaLevel(89876, 1000, 288.15-6.5), 50)
+	// colder air columns are denser
+	assert.Greater(t, ToSeaLevel(90000, 1000, 263.15), ToSeaLevel(90000, 1000, 293.15))
+	assert.True(t, math.IsNaN(ToSeaLevel(90000, math.NaN(), 288.15)))
+	assert.True(t, math.IsNaN(ToSeaLevel(90000, 1000, math.NaN())))
+}
+
+func TestToStation(t *testing.T) {
+	p := ToStation(101320, 1234, 280)
+	assert.InDelta(t, 87343.3, p, 0.1)
+	assert.InDelta(t, 101320, ToSeaLevel(p, 1234, 280), 1e-6)
+}
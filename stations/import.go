@@ -0,0 +1,254 @@
+package stations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/obsreader"
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/meteocima/dewetra2wrf/units"
+)
+
+// DewetraClasses are the sensor classes
+// of Dewetra registries.
+var DewetraClasses = []string{
+	"TERMOMETRO",
+	"IGROMETRO",
+	"ANEMOMETRO",
+	"DIREZIONEVENTO",
+	"PLUVIOMETRO",
+	"BAROMETRO",
+}
+
+// dewetraSensor is a sensor as listed in
+// Dewetra `<class>-registry.json` files.
+type dewetraSensor struct {
+	ID        string
+	Name      string
+	MU        string
+	Lng, Lat  float64
+	Elevation *float64
+}
+
+// dewetraData is the timeline of a sensor as
+// contained in Dewetra `<class>.json` files.
+type dewetraData struct {
+	SensorID string
+	Timeline []string
+}
+
+// ImportDewetra adds to the registry the stations listed
+// in Dewetra `<class>-registry.json` files contained in
+// dataPath. Sensors of different classes with the same
+// name and coordinates belong to the same station.
+// Active periods are read from timelines of `<class>.json`
+// files, when present.
+func (r *Registry) ImportDewetra(dataPath string) error {
+	stations := map[string]*Station{}
+	sensorIDs := map[string][]string{}
+	found := false
+
+	for _, class := range DewetraClasses {
+		content, err := ioutil.ReadFile(filepath.Join(dataPath, class+"-registry.json"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+
+		sensors := []dewetraSensor{}
+		if err := json.Unmarshal(content, &sensors); err != nil {
+			return fmt.Errorf("%s-registry.json: %w", class, err)
+		}
+		timelines, err := readDewetraTimelines(dataPath, class)
+		if err != nil {
+			return err
+		}
+
+		for _, sensor := range sensors {
+			key := fmt.Sprintf("%s:%05f:%05f", sensor.Name, sensor.Lat, sensor.Lng)
+			station, ok := stations[key]
+			if !ok {
+				station = &Station{
+					Name:      sensor.Name,
+					Network:   types.DPCTrusted.String(),
+					Lat:       sensor.Lat,
+					Lon:       sensor.Lng,
+					Elevation: types.NaN(),
+				}
+				stations[key] = station
+			}
+			if sensor.Elevation != nil {
+				station.Elevation = types.Value(*sensor.Elevation)
+			}
+			station.Sensors = append(station.Sensors, Sensor{ID: sensor.ID, Class: class, Unit: sensor.MU})
+			if period, ok := timelines[sensor.ID]; ok {
+				station.Active = append(station.Active, period)
+			}
+			sensorIDs[key] = append(sensorIDs[key], sensor.ID)
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s: no Dewetra registry found", dataPath)
+	}
+
+	for key, station := range stations {
+		station.ID = dewetraStationID(sensorIDs[key])
+		r.add(*station)
+	}
+	return nil
+}
+
+// readDewetraTimelines returns the first and last
+// time of observations of each sensor of class,
+// or an empty map when the data file is missing.
+func readDewetraTimelines(dataPath, class string) (map[string]Period, error) {
+	periods := map[string]Period{}
+	content, err := ioutil.ReadFile(filepath.Join(dataPath, class+".json"))
+	if os.IsNotExist(err) {
+		return periods, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data := []dewetraData{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("%s.json: %w", class, err)
+	}
+	for _, sens := range data {
+		for _, dateS := range sens.Timeline {
+			at, err := time.Parse(time.RFC3339, dateS)
+			if err != nil {
+				return nil, fmt.Errorf("%s.json: %w", class, err)
+			}
+			period, ok := periods[sens.SensorID]
+			if !ok || at.Before(period.From) {
+				period.From = at
+			}
+			if !ok || at.After(period.To) {
+				period.To = at
+			}
+			periods[sens.SensorID] = period
+		}
+	}
+	return periods, nil
+}
+
+// dewetraStationID returns the ID of a station with
+// sensors with given IDs: the common prefix of sensor
+// IDs up to the last underscore (e.g. 210797226_2 for
+// 210797226_2_00 and 210797226_2_06), or the ID
+// of the sensor for stations with a single one.
+func dewetraStationID(ids []string) string {
+	sort.Strings(ids)
+	if len(ids) == 1 {
+		return ids[0]
+	}
+	first, last := ids[0], ids[len(ids)-1]
+	n := 0
+	for n < len(first) && n < len(last) && first[n] == last[n] {
+		n++
+	}
+	prefix := first[:n]
+	if idx := strings.LastIndex(prefix, "_"); idx > 0 {
+		return prefix[:idx]
+	}
+	return first
+}
+
+// wundSensors are sensors of Wunderground stations, with
+// the observed value that tells if they are present and
+// the unit, among those reported by the station, of
+// their values.
+var wundSensors = []struct {
+	class string
+	value func(obs types.Observation) types.Value
+	unit  func(source units.Source) units.Unit
+}{
+	{"temperature",
+		func(obs types.Observation) types.Value { return obs.Metric.TempAvg },
+		func(source units.Source) units.Unit { return source.Temperature }},
+	{"dewpoint",
+		func(obs types.Observation) types.Value { return obs.Metric.DewptAvg },
+		func(source units.Source) units.Unit { return source.Temperature }},
+	{"humidity",
+		func(obs types.Observation) types.Value { return obs.HumidityAvg },
+		func(source units.Source) units.Unit { return units.Percent }},
+	{"windSpeed",
+		func(obs types.Observation) types.Value { return obs.Metric.WindspeedAvg },
+		func(source units.Source) units.Unit { return source.Speed }},
+	{"windDirection",
+		func(obs types.Observation) types.Value { return obs.WinddirAvg },
+		func(source units.Source) units.Unit { return units.Degree }},
+	{"pressure",
+		func(obs types.Observation) types.Value { return obs.Metric.SeaLevelPressure },
+		func(source units.Source) units.Unit { return source.Pressure }},
+	{"precipitation",
+		func(obs types.Observation) types.Value { return obs.Metric.PrecipTotal },
+		func(source units.Source) units.Unit { return source.Precipitation }},
+}
+
+// ImportWunderground adds to the registry the stations
+// of Wunderground observations contained in JSON files
+// under dataPath, either as returned from 'current' or
+// from 'historical' web API, with any of the units they
+// support. Files that can't be decoded are skipped
+// with a warning.
+func (r *Registry) ImportWunderground(dataPath string) error {
+	return filepath.Walk(dataPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		reports, err := obsreader.DecodeWunderground(content)
+		if err != nil {
+			log.Printf("skipping %s: %s", path, err)
+			return nil
+		}
+		for _, report := range reports {
+			if report.StationID == "" {
+				continue
+			}
+			r.add(wundStation(report))
+		}
+		return nil
+	})
+}
+
+// wundStation returns the station
+// that reported report.
+func wundStation(report obsreader.WundReport) Station {
+	obs := report.Observation
+	station := Station{
+		ID:        obs.StationID,
+		Name:      obs.StationID,
+		Network:   types.Wunderground.String(),
+		Lat:       obs.Lat,
+		Lon:       obs.Lon,
+		Elevation: types.NaN(),
+		Active:    []Period{{From: obs.ObsTimeUtc, To: obs.ObsTimeUtc}},
+	}
+	if obs.Metric.Elev != nil {
+		station.Elevation = *obs.Metric.Elev
+	}
+	for _, sensor := range wundSensors {
+		if !sensor.value(obs).IsNaN() {
+			station.Sensors = append(station.Sensors, Sensor{Class: sensor.class, Unit: sensor.unit(report.Units).Symbol})
+		}
+	}
+	return station
+}
@@ -0,0 +1,148 @@
+package stations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// Registry is a set of stations, keyed by ID.
+type Registry struct {
+	stations map[string]*Station
+}
+
+// New returns an empty Registry.
+func New() *Registry {
+	return &Registry{stations: map[string]*Station{}}
+}
+
+// Open reads the registry saved in filename, or
+// returns an empty one if the file doesn't exist.
+func Open(filename string) (*Registry, error) {
+	r := New()
+
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stations := []*Station{}
+	if err := json.Unmarshal(content, &stations); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, s := range stations {
+		r.stations[s.ID] = s
+	}
+	return r, nil
+}
+
+// Save writes the registry to filename,
+// with stations sorted by ID.
+func (r *Registry) Save(filename string) error {
+	content, err := json.MarshalIndent(r.All(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, content, 0644)
+}
+
+// Len returns the number of stations in the registry.
+func (r *Registry) Len() int {
+	return len(r.stations)
+}
+
+// All returns all stations of the registry, sorted by ID.
+func (r *Registry) All() []*Station {
+	return r.Query(Query{})
+}
+
+// Get returns the station with given ID, or the station
+// having a sensor with given ID.
+func (r *Registry) Get(id string) (*Station, bool) {
+	if s, ok := r.stations[id]; ok {
+		return s, true
+	}
+	for _, s := range r.stations {
+		for _, sensor := range s.Sensors {
+			if sensor.ID != "" && sensor.ID == id {
+				return s, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Query contains criteria used to select stations
+// with Registry.Query. Zero valued fields match
+// every station.
+type Query struct {
+	// Network is the network of stations,
+	// compared case insensitively.
+	Network string
+	// Region contains the coordinates of stations.
+	Region types.Region
+	// ActiveAt is a time when stations were active.
+	ActiveAt time.Time
+	// SensorClass is the class of a sensor
+	// of stations, compared case insensitively.
+	SensorClass string
+}
+
+// Query returns the stations that match all
+// criteria of q, sorted by ID.
+func (r *Registry) Query(q Query) []*Station {
+	result := []*Station{}
+	for _, s := range r.stations {
+		if q.Network != "" && !strings.EqualFold(q.Network, s.Network) {
+			continue
+		}
+		if q.Region != nil && !q.Region.Contains(s.Lat, s.Lon) {
+			continue
+		}
+		if !q.ActiveAt.IsZero() && !s.ActiveAt(q.ActiveAt) {
+			continue
+		}
+		if q.SensorClass != "" && !s.HasSensor(q.SensorClass) {
+			continue
+		}
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// add merges station into the registry: name, coordinates
+// and elevation of an existing station with the same ID
+// are replaced, while sensors and active periods
+// are added to its ones.
+func (r *Registry) add(station Station) {
+	existing, ok := r.stations[station.ID]
+	if !ok {
+		existing = &Station{ID: station.ID, Sensors: []Sensor{}, Active: []Period{}}
+		r.stations[station.ID] = existing
+	}
+	existing.Name = station.Name
+	existing.Network = station.Network
+	existing.Lat = station.Lat
+	existing.Lon = station.Lon
+	if !station.Elevation.IsNaN() || !ok {
+		existing.Elevation = station.Elevation
+	}
+	for _, sensor := range station.Sensors {
+		existing.addSensor(sensor)
+	}
+	for _, p := range station.Active {
+		existing.seen(p.From, p.To)
+	}
+}
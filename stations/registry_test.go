@@ -0,0 +1,153 @@
+package stations
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportDewetra(t *testing.T) {
+	r := New()
+	assert.NoError(t, r.ImportDewetra("../fixtures"))
+	assert.Equal(t, 1, r.Len())
+
+	s, ok := r.Get("210797226_2")
+	assert.True(t, ok)
+	assert.Equal(t, "Arenzano", s.Name)
+	assert.Equal(t, "DPCTrusted", s.Network)
+	assert.Equal(t, 44.4051, s.Lat)
+	assert.Equal(t, 8.67035, s.Lon)
+	assert.True(t, s.Elevation.IsNaN())
+	assert.Len(t, s.Sensors, 6)
+	assert.Equal(t, Sensor{ID: "210797226_2_06", Class: "TERMOMETRO", Unit: "Km/h"}, s.Sensors[5])
+	assert.Equal(t, []Period{{
+		From: time.Date(2021, 3, 14, 21, 30, 0, 0, time.UTC),
+		To:   time.Date(2021, 3, 14, 22, 30, 0, 0, time.UTC),
+	}}, s.Active)
+
+	bySensor, ok := r.Get("210797226_2_03")
+	assert.True(t, ok)
+	assert.Same(t, s, bySensor)
+	_, ok = r.Get("210797226")
+	assert.False(t, ok)
+
+	err := r.ImportDewetra(os.TempDir())
+	assert.EqualError(t, err, os.TempDir()+": no Dewetra registry found")
+}
+
+func TestImportWunderground(t *testing.T) {
+	dir := t.TempDir()
+	current := `{"stationID":"IGENOVA12","obsTimeUtc":"2020-03-30T18:00:00Z","lat":44.4,"lon":8.9,
+		"humidityAvg":70,"winddirAvg":null,"metric":{"tempAvg":12.5,"windspeedAvg":null,
+		"pressureMax":1012,"pressureMin":1011,"precipTotal":0,"dewptAvg":null,"elev":35}}`
+	hist := `{"observations":[
+		{"stationID":"ISAVONA3","obsTimeUtc":"2020-03-29T18:00:00Z","lat":44.3,"lon":8.4,
+		 "humidityAvg":null,"winddirAvg":null,"metric":{"tempAvg":10,"windspeedAvg":null,
+		 "pressureMax":null,"precipTotal":null,"dewptAvg":null}},
+		{"stationID":"ISAVONA3","obsTimeUtc":"2020-03-30T06:00:00Z","lat":44.3,"lon":8.4,
+		 "humidityAvg":null,"winddirAvg":null,"metric":{"tempAvg":11,"windspeedAvg":null,
+		 "pressureMax":null,"precipTotal":null,"dewptAvg":null}},
+		{"stationID":"ISAVONA3","obsTimeUtc":"2020-04-02T06:00:00Z","lat":44.3,"lon":8.4,
+		 "humidityAvg":null,"winddirAvg":null,"metric":{"tempAvg":11,"windspeedAvg":3,
+		 "pressureMax":null,"precipTotal":null,"dewptAvg":null}}
+	]}`
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "2020033018"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "2020033018", "IGENOVA12.json"), []byte(current), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ISAVONA3.json"), []byte(hist), 0644))
+
+	r := New()
+	assert.NoError(t, r.ImportWunderground(dir))
+	assert.Equal(t, 2, r.Len())
+
+	s, ok := r.Get("IGENOVA12")
+	assert.True(t, ok)
+	assert.Equal(t, "Wunderground", s.Network)
+	assert.Equal(t, types.Value(35), s.Elevation)
+	assert.Equal(t, []Sensor{
+		{Class: "humidity", Unit: "%"},
+		{Class: "precipitation", Unit: "mm"},
+		{Class: "pressure", Unit: "hPa"},
+		{Class: "temperature", Unit: "°C"},
+	}, s.Sensors)
+
+	s, ok = r.Get("ISAVONA3")
+	assert.True(t, ok)
+	assert.True(t, s.Elevation.IsNaN())
+	assert.Equal(t, []Period{
+		{From: time.Date(2020, 3, 29, 18, 0, 0, 0, time.UTC), To: time.Date(2020, 3, 30, 6, 0, 0, 0, time.UTC)},
+		{From: time.Date(2020, 4, 2, 6, 0, 0, 0, time.UTC), To: time.Date(2020, 4, 2, 6, 0, 0, 0, time.UTC)},
+	}, s.Active)
+	assert.True(t, s.ActiveAt(time.Date(2020, 3, 30, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, s.ActiveAt(time.Date(2020, 3, 31, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, s.HasSensor("windSpeed"))
+
+	domain, err := types.DomainFromS("44.35,45,8,9.5")
+	assert.NoError(t, err)
+	ids := func(stations []*Station) []string {
+		res := []string{}
+		for _, s := range stations {
+			res = append(res, s.ID)
+		}
+		return res
+	}
+	assert.Equal(t, []string{"IGENOVA12", "ISAVONA3"}, ids(r.All()))
+	assert.Equal(t, []string{"IGENOVA12"}, ids(r.Query(Query{Region: domain})))
+	assert.Equal(t, []string{"ISAVONA3"}, ids(r.Query(Query{SensorClass: "WINDSPEED"})))
+	assert.Equal(t, []string{"IGENOVA12"}, ids(r.Query(Query{ActiveAt: time.Date(2020, 3, 30, 18, 0, 0, 0, time.UTC)})))
+	assert.Equal(t, []string{}, ids(r.Query(Query{Network: "DPCTrusted"})))
+}
+
+func TestImportWundergroundUnits(t *testing.T) {
+	dir := t.TempDir()
+	// humidityAvg and winddirAvg are missing
+	imperial := `{"stationID":"KNYNEWYO1","obsTimeUtc":"2020-03-30T18:00:00Z","lat":40.7,"lon":-74,
+		"imperial":{"tempAvg":50,"windspeedAvg":5,"pressureMax":30.1,"pressureMin":30,
+		"precipTotal":null,"dewptAvg":null,"elev":100}}`
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "KNYNEWYO1.json"), []byte(imperial), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"stationID":`), 0644))
+	noValues := `{"stationID":"IEMPTY1","obsTimeUtc":"2020-03-30T18:00:00Z","lat":44,"lon":8}`
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "IEMPTY1.json"), []byte(noValues), 0644))
+
+	r := New()
+	assert.NoError(t, r.ImportWunderground(dir))
+	assert.Equal(t, 1, r.Len())
+
+	s, ok := r.Get("KNYNEWYO1")
+	assert.True(t, ok)
+	assert.InDelta(t, 30.48, float64(s.Elevation), 1e-6)
+	assert.Equal(t, []Sensor{
+		{Class: "pressure", Unit: "inHg"},
+		{Class: "temperature", Unit: "°F"},
+		{Class: "windSpeed", Unit: "mph"},
+	}, s.Sensors)
+}
+
+func TestSaveAndOpen(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "stations.json")
+	r, err := Open(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, r.Len())
+	assert.NoError(t, r.ImportDewetra("../fixtures"))
+	assert.NoError(t, r.Save(filename))
+
+	saved, err := Open(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, saved.Len())
+	s, _ := saved.Get("210797226_2")
+	expected, _ := r.Get("210797226_2")
+	assert.True(t, s.Elevation.IsNaN())
+	s.Elevation, expected.Elevation = 0, 0
+	assert.Equal(t, expected, s)
+
+	// importing again the same files
+	// leaves stations unchanged.
+	assert.NoError(t, saved.ImportDewetra("../fixtures"))
+	s, _ = saved.Get("210797226_2")
+	assert.Len(t, s.Sensors, 6)
+	assert.Len(t, s.Active, 1)
+}
@@ -0,0 +1,116 @@
+// Package stations implements a persistent registry
+// of weather stations metadata, collected from Dewetra
+// sensors registries and Wunderground observations.
+package stations
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// activityGap is the maximum time between two
+// observations of a station that belong to
+// the same active period.
+const activityGap = 24 * time.Hour
+
+// Sensor is a sensor of a station.
+type Sensor struct {
+	// ID is the ID of the sensor, for networks
+	// that give one to each sensor.
+	ID string `json:"id,omitempty"`
+	// Class is the kind of the sensor, e.g.
+	// TERMOMETRO for Dewetra sensors.
+	Class string `json:"class"`
+	// Unit is the unit of measure of values
+	// returned by the sensor.
+	Unit string `json:"unit"`
+}
+
+// Period is an interval of time
+// when a station was active.
+type Period struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Station contains metadata of a station.
+type Station struct {
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	Network string  `json:"network"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	// Elevation is the elevation reported by the
+	// network, in meters, or NaN when unknown.
+	Elevation types.Value `json:"elevation"`
+	Sensors   []Sensor    `json:"sensors"`
+	// Active lists the periods when the station
+	// was seen reporting observations, sorted
+	// by time.
+	Active []Period `json:"active"`
+}
+
+// HasSensor returns whether the station
+// has a sensor of given class.
+func (s *Station) HasSensor(class string) bool {
+	for _, sensor := range s.Sensors {
+		if strings.EqualFold(sensor.Class, class) {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveAt returns whether the station was active at t.
+func (s *Station) ActiveAt(t time.Time) bool {
+	for _, p := range s.Active {
+		if !t.Before(p.From) && !t.After(p.To) {
+			return true
+		}
+	}
+	return false
+}
+
+// addSensor adds sensor to the station,
+// replacing the one with same ID and class.
+func (s *Station) addSensor(sensor Sensor) {
+	for i, existing := range s.Sensors {
+		if existing.ID == sensor.ID && existing.Class == sensor.Class {
+			s.Sensors[i] = sensor
+			return
+		}
+	}
+	s.Sensors = append(s.Sensors, sensor)
+	sort.Slice(s.Sensors, func(i, j int) bool {
+		if s.Sensors[i].Class == s.Sensors[j].Class {
+			return s.Sensors[i].ID < s.Sensors[j].ID
+		}
+		return s.Sensors[i].Class < s.Sensors[j].Class
+	})
+}
+
+// seen records that the station was active from
+// from to to, merging the interval with active
+// periods closer than activityGap.
+func (s *Station) seen(from, to time.Time) {
+	s.Active = append(s.Active, Period{From: from, To: to})
+	sort.Slice(s.Active, func(i, j int) bool {
+		return s.Active[i].From.Before(s.Active[j].From)
+	})
+
+	merged := []Period{s.Active[0]}
+	for _, p := range s.Active[1:] {
+		last := &merged[len(merged)-1]
+		if p.From.Sub(last.To) <= activityGap {
+			if p.To.After(last.To) {
+				last.To = p.To
+			}
+			continue
+		}
+		merged = append(merged, p)
+	}
+	s.Active = merged
+}
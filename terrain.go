@@ -0,0 +1,97 @@
+package dewetra2wrf
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/meteocima/dewetra2wrf/elevations"
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// ElevationGapPolicy is an enum that tells how
+// stations whose elevation differs too much from
+// model terrain height are handled.
+type ElevationGapPolicy int
+
+// ElevationGapPolicy values ...
+const (
+	// RejectStations discards the observations
+	RejectStations ElevationGapPolicy = iota
+	// InflateErrors keeps the observations, inflating the
+	// observation error of temperatures by the standard
+	// atmosphere lapse rate times the elevation gap.
+	InflateErrors
+)
+
+// lapseRate is the temperature lapse rate of
+// the standard atmosphere, in °K per meter.
+const lapseRate = 0.0065
+
+// FromString returns a new ElevationGapPolicy
+// for the policy represented in given code
+func (p *ElevationGapPolicy) FromString(code string) {
+	if code == "REJECT" {
+		*p = RejectStations
+	} else if code == "INFLATE" {
+		*p = InflateErrors
+	} else {
+		panic("Unknown elevation gap policy " + code)
+	}
+}
+
+// String implements fmt.Stringer for ElevationGapPolicy
+func (p ElevationGapPolicy) String() string {
+	if p == RejectStations {
+		return "RejectStations"
+	}
+
+	if p == InflateErrors {
+		return "InflateErrors"
+	}
+
+	return fmt.Sprintf("%d", int(p))
+}
+
+// unknownModelElevations marks model terrain height
+// of observations as unknown, when no model terrain
+// is used to check station elevations.
+func unknownModelElevations(observations []types.Observation) {
+	for i := range observations {
+		observations[i].ModelElevation = math.NaN()
+	}
+}
+
+// checkElevations records the model terrain height at the
+// location of each surface observation, and applies
+// opts.ElevationGapPolicy to observations whose elevation
+// differs from it more than opts.MaxElevationGap meters.
+// It returns accepted and rejected observations.
+func checkElevations(observations []types.Observation, terrain *elevations.Terrain, opts Options) (accepted, rejected []types.Observation) {
+	accepted = []types.Observation{}
+	rejected = []types.Observation{}
+
+	for _, obs := range observations {
+		// upper-air observations are not
+		// affected by model terrain.
+		if len(obs.Levels) > 0 {
+			obs.ModelElevation = math.NaN()
+			accepted = append(accepted, obs)
+			continue
+		}
+		obs.ModelElevation = terrain.At(obs.Lat, obs.Lon)
+		gap := math.Abs(obs.Elevation - obs.ModelElevation)
+		if opts.MaxElevationGap <= 0 || math.IsNaN(gap) || gap <= opts.MaxElevationGap {
+			accepted = append(accepted, obs)
+			continue
+		}
+
+		if opts.ElevationGapPolicy == InflateErrors {
+			obs.TempErrorInflation = gap * lapseRate
+			accepted = append(accepted, obs)
+			continue
+		}
+		rejected = append(rejected, obs)
+	}
+
+	return accepted, rejected
+}
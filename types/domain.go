@@ -20,8 +20,8 @@ type Domain struct {
 func DomainFromS(s string) (*Domain, error) {
 	if s == "" {
 		return &Domain{
-			MinLat: -180,
-			MinLon: -90,
+			MinLat: -90,
+			MinLon: -180,
 			MaxLat: 90,
 			MaxLon: 180,
 		}, nil
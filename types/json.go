@@ -1,6 +1,7 @@
 package types
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -24,6 +25,16 @@ func (result Result) SensorValue() Value {
 	return Value(result.Value)
 }
 
+// Platform values used for Observation.Platform.
+// They follow the WMO code names used by WRFDA.
+const (
+	PlatformSynop    = "FM-12 SYNOP"
+	PlatformMetar    = "FM-15 METAR"
+	PlatformPilot    = "FM-32 PILOT"
+	PlatformTemp     = "FM-35 TEMP"
+	PlatformProfiler = "FM-132 PROFL"
+)
+
 // Observation represents data for all sensor classes of
 // a station at a moment in time
 type Observation struct {
@@ -34,7 +45,55 @@ type Observation struct {
 	Lat, Lon    float64
 	HumidityAvg Value
 	WinddirAvg  Value
-	Metric      ObservationMetric
+	// Visibility is the horizontal visibility
+	// in meters, when reported.
+	Visibility Value
+	// Platform is the WMO platform type of the report.
+	// An empty string is treated as PlatformSynop.
+	Platform string
+	// Group is the category of the station
+	// network the observation comes from.
+	Group  StationsGroup
+	Metric ObservationMetric
+	// Levels contains values measured at each level
+	// by upper-air platforms (e.g. radiosondes
+	// or profilers). It is empty for surface observations.
+	Levels []Level
+	// ModelElevation is the terrain height of the
+	// model at station location, in meters. It is set
+	// only when a model terrain is used to check
+	// station elevations, and is NaN when unknown.
+	ModelElevation float64
+	// PrecipPeriod is the period, ending at ObsTimeUtc,
+	// over which Metric.PrecipTotal is accumulated,
+	// or zero when unknown.
+	PrecipPeriod time.Duration
+	// UvHigh is the highest UV index of the period
+	// summarized by Wunderground history observations.
+	UvHigh Value
+	// TempErrorInflation is added, in quadrature, to
+	// observation errors of temperature and dew point,
+	// in °K. It accounts for the gap between station
+	// elevation and model terrain.
+	TempErrorInflation float64
+	// AltimeterSetting tells that Metric.SeaLevelPressure
+	// is a QNH or altimeter setting, as reported by METAR,
+	// reduced to sea level with the standard atmosphere
+	// instead of the actual temperature of the station.
+	AltimeterSetting bool
+}
+
+// Level contains values of an upper-air
+// observation at a single level.
+// Values are in Pa, m, °K, m/s, degrees and %.
+type Level struct {
+	Pressure     Value
+	Height       Value
+	TempAvg      Value
+	DewptAvg     Value
+	WindspeedAvg Value
+	WinddirAvg   Value
+	HumidityAvg  Value
 }
 
 // ObservationMetric contains a subset of values
@@ -47,6 +106,58 @@ type ObservationMetric struct {
 	PrecipTotal  Value
 	PressureMin  Value
 	PressureMax  Value
+	// SeaLevelPressure is the pressure reduced
+	// to mean sea level, when reported.
+	SeaLevelPressure Value
+	// TempHigh and TempLow are the highest and lowest
+	// temperatures of the period summarized by
+	// Wunderground history observations.
+	TempHigh Value
+	TempLow  Value
+	// WindgustAvg is the average speed of wind gusts.
+	WindgustAvg Value
+	// PrecipRate is the precipitation rate, in mm/h.
+	PrecipRate Value
+	// HeatindexAvg is the average heat index.
+	HeatindexAvg Value
+	// Elev is the station elevation reported by
+	// Wunderground, or nil when missing.
+	Elev *Value
+}
+
+// MissingMetric returns an
+// ObservationMetric with all values set to NaN.
+func MissingMetric() ObservationMetric {
+	return ObservationMetric{
+		TempAvg:      NaN(),
+		DewptAvg:     NaN(),
+		WindspeedAvg: NaN(),
+		Pressure:     NaN(),
+		PrecipTotal:  NaN(),
+		PressureMin:  NaN(),
+		PressureMax:  NaN(),
+		TempHigh:     NaN(),
+		TempLow:      NaN(),
+		WindgustAvg:  NaN(),
+		PrecipRate:   NaN(),
+		HeatindexAvg: NaN(),
+
+		SeaLevelPressure: NaN(),
+	}
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// Values missing from buff are set to NaN.
+func (metric *ObservationMetric) UnmarshalJSON(buff []byte) error {
+	// plainMetric has no UnmarshalJSON method,
+	// so it's decoded field by field.
+	type plainMetric ObservationMetric
+	values := plainMetric(MissingMetric())
+	if err := json.Unmarshal(buff, &values); err != nil {
+		return err
+	}
+	*metric = ObservationMetric(values)
+	return nil
 }
 
 // SortKey returns a string used to sort observations
@@ -55,3 +166,12 @@ func (obs Observation) SortKey() string {
 	return s
 
 }
+
+// PlatformType returns the WMO platform type of
+// the observation, defaulting to PlatformSynop.
+func (obs Observation) PlatformType() string {
+	if obs.Platform == "" {
+		return PlatformSynop
+	}
+	return obs.Platform
+}
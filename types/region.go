@@ -0,0 +1,214 @@
+package types
+
+import (
+	"math"
+)
+
+// Region is implemented by types that represent
+// a geographic area used to filter stations.
+type Region interface {
+	// Contains returns whether the point at
+	// given latitude and longitude is inside the region.
+	Contains(lat, lon float64) bool
+	// Bounds returns the smallest Domain
+	// that contains the whole region.
+	Bounds() Domain
+}
+
+// boundary is implemented by regions that can
+// compute the distance of a point from their borders.
+type boundary interface {
+	// distance returns the distance in meters between
+	// the point at lat, lon and the region border.
+	distance(lat, lon float64) float64
+}
+
+// earthRadius is the mean earth radius in meters
+const earthRadius = 6371008.8
+
+// metersPerDegree is the length in meters of
+// a degree of latitude.
+const metersPerDegree = earthRadius * math.Pi / 180
+
+// Point is a geographic point, with
+// latitude and longitude in degrees.
+type Point struct {
+	Lat, Lon float64
+}
+
+// Contains implements Region for Domain
+func (d Domain) Contains(lat, lon float64) bool {
+	return lat <= d.MaxLat && lat >= d.MinLat &&
+		lon <= d.MaxLon && lon >= d.MinLon
+}
+
+// Bounds implements Region for Domain
+func (d Domain) Bounds() Domain {
+	return d
+}
+
+func (d Domain) distance(lat, lon float64) float64 {
+	return Polygon{d.ring()}.distance(lat, lon)
+}
+
+func (d Domain) ring() []Point {
+	return []Point{
+		{d.MinLat, d.MinLon},
+		{d.MinLat, d.MaxLon},
+		{d.MaxLat, d.MaxLon},
+		{d.MaxLat, d.MinLon},
+		{d.MinLat, d.MinLon},
+	}
+}
+
+// Polygon is a Region delimited by one or more rings.
+// A point is inside the polygon when it's contained
+// in an odd number of rings, so that the first ring
+// is the exterior and next ones are holes, or further
+// exteriors inside holes, as in ESRI shapefiles.
+type Polygon [][]Point
+
+// Contains implements Region for Polygon
+func (p Polygon) Contains(lat, lon float64) bool {
+	inside := false
+	for _, ring := range p {
+		if ringContains(ring, lat, lon) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// ringContains returns whether the point is inside
+// ring, using the ray casting algorithm.
+func ringContains(ring []Point, lat, lon float64) bool {
+	inside := false
+	j := len(ring) - 1
+	for i := range ring {
+		a, b := ring[i], ring[j]
+		if (a.Lat > lat) != (b.Lat > lat) &&
+			lon < (b.Lon-a.Lon)*(lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+		j = i
+	}
+	return inside
+}
+
+// Bounds implements Region for Polygon
+func (p Polygon) Bounds() Domain {
+	bounds := Domain{
+		MinLat: math.Inf(1),
+		MinLon: math.Inf(1),
+		MaxLat: math.Inf(-1),
+		MaxLon: math.Inf(-1),
+	}
+	for _, ring := range p {
+		for _, pt := range ring {
+			bounds.MinLat = math.Min(bounds.MinLat, pt.Lat)
+			bounds.MaxLat = math.Max(bounds.MaxLat, pt.Lat)
+			bounds.MinLon = math.Min(bounds.MinLon, pt.Lon)
+			bounds.MaxLon = math.Max(bounds.MaxLon, pt.Lon)
+		}
+	}
+	return bounds
+}
+
+func (p Polygon) distance(lat, lon float64) float64 {
+	min := math.Inf(1)
+	// segments are projected on a plane tangent to the
+	// point, which is accurate enough for buffers
+	// of a few tens of kilometers.
+	cosLat := math.Cos(lat * math.Pi / 180)
+	project := func(pt Point) (float64, float64) {
+		return (pt.Lon - lon) * cosLat * metersPerDegree, (pt.Lat - lat) * metersPerDegree
+	}
+	for _, ring := range p {
+		for i := 1; i < len(ring); i++ {
+			ax, ay := project(ring[i-1])
+			bx, by := project(ring[i])
+			min = math.Min(min, segmentDistance(ax, ay, bx, by))
+		}
+	}
+	return min
+}
+
+// segmentDistance returns the distance between the
+// origin and the segment from (ax, ay) to (bx, by).
+func segmentDistance(ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	lenSq := dx*dx + dy*dy
+	t := 0.0
+	if lenSq > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lenSq))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
+
+// MultiPolygon is a Region made of many polygons.
+// A point is inside it when it's contained
+// in any of its polygons.
+type MultiPolygon []Polygon
+
+// Contains implements Region for MultiPolygon
+func (mp MultiPolygon) Contains(lat, lon float64) bool {
+	for _, p := range mp {
+		if p.Contains(lat, lon) {
+			return true
+		}
+	}
+	return false
+}
+
+// Bounds implements Region for MultiPolygon
+func (mp MultiPolygon) Bounds() Domain {
+	rings := Polygon{}
+	for _, p := range mp {
+		rings = append(rings, p...)
+	}
+	return rings.Bounds()
+}
+
+func (mp MultiPolygon) distance(lat, lon float64) float64 {
+	min := math.Inf(1)
+	for _, p := range mp {
+		min = math.Min(min, p.distance(lat, lon))
+	}
+	return min
+}
+
+// Buffer is a Region that extends another one
+// by Distance meters around its border.
+// Region must be a Domain, a Polygon or a MultiPolygon.
+type Buffer struct {
+	Region   Region
+	Distance float64
+}
+
+// Contains implements Region for Buffer.
+// Use NewBuffer to check that Region is supported:
+// points outside unsupported regions are never contained.
+func (b Buffer) Contains(lat, lon float64) bool {
+	if b.Region.Contains(lat, lon) {
+		return true
+	}
+	border, ok := b.Region.(boundary)
+	if !ok {
+		return false
+	}
+	return border.distance(lat, lon) <= b.Distance
+}
+
+// Bounds implements Region for Buffer
+func (b Buffer) Bounds() Domain {
+	bounds := b.Region.Bounds()
+	dLat := b.Distance / metersPerDegree
+	maxAbsLat := math.Min(89, math.Max(math.Abs(bounds.MinLat), math.Abs(bounds.MaxLat))+dLat)
+	dLon := b.Distance / (metersPerDegree * math.Cos(maxAbsLat*math.Pi/180))
+	return Domain{
+		MinLat: math.Max(-90, bounds.MinLat-dLat),
+		MaxLat: math.Min(90, bounds.MaxLat+dLat),
+		MinLon: math.Max(-180, bounds.MinLon-dLon),
+		MaxLon: math.Min(180, bounds.MaxLon+dLon),
+	}
+}
@@ -0,0 +1,187 @@
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// square with a square hole
+var holed = Polygon{
+	{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}},
+	{{4, 4}, {4, 6}, {6, 6}, {6, 4}, {4, 4}},
+}
+
+func TestDomainFromSDefault(t *testing.T) {
+	d, err := DomainFromS("")
+	assert.NoError(t, err)
+	assert.Equal(t, Domain{MinLat: -90, MaxLat: 90, MinLon: -180, MaxLon: 180}, *d)
+}
+
+func TestPolygon(t *testing.T) {
+	assert.True(t, holed.Contains(2, 2))
+	assert.False(t, holed.Contains(5, 5))
+	assert.False(t, holed.Contains(11, 5))
+	assert.Equal(t, Domain{MinLat: 0, MaxLat: 10, MinLon: 0, MaxLon: 10}, holed.Bounds())
+}
+
+func TestMultiPolygon(t *testing.T) {
+	mp := MultiPolygon{holed, Polygon{{{20, 20}, {20, 21}, {21, 21}, {20, 20}}}}
+	assert.True(t, mp.Contains(2, 2))
+	assert.True(t, mp.Contains(20.5, 20.8))
+	assert.False(t, mp.Contains(15, 15))
+	assert.Equal(t, Domain{MinLat: 0, MaxLat: 21, MinLon: 0, MaxLon: 21}, mp.Bounds())
+}
+
+func TestBuffer(t *testing.T) {
+	d := Domain{MinLat: 44, MaxLat: 45, MinLon: 8, MaxLon: 9}
+	// 0.1 degrees of latitude are about 11 km
+	assert.True(t, Buffer{d, 12000}.Contains(45.1, 8.5))
+	assert.False(t, Buffer{d, 10000}.Contains(45.1, 8.5))
+	// inside the hole, 1 degree from its border
+	assert.False(t, Buffer{holed, 1000}.Contains(5, 5))
+	assert.True(t, Buffer{holed, 112000}.Contains(5, 5))
+
+	bounds := Buffer{d, 11119.5}.Bounds()
+	assert.InDelta(t, 43.9, bounds.MinLat, 1e-4)
+	assert.InDelta(t, 45.1, bounds.MaxLat, 1e-4)
+	assert.True(t, bounds.MaxLon > 9.14)
+
+	r, err := NewBuffer(d, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, d, r)
+	r, err = NewBuffer(d, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, Buffer{d, 1000}, r)
+	_, err = NewBuffer(Buffer{d, 1000}, 1000)
+	assert.EqualError(t, err, "buffer is not supported for types.Buffer regions")
+}
+
+func TestParseWKT(t *testing.T) {
+	r, err := ParseWKT("POLYGON ((0 0, 10 0, 10 10, 0 10, 0 0), (4 4, 6 4, 6 6, 4 6, 4 4))")
+	assert.NoError(t, err)
+	p := r.(Polygon)
+	assert.Equal(t, 2, len(p))
+	assert.Equal(t, Point{Lat: 0, Lon: 10}, p[0][1])
+	assert.True(t, p.Contains(2, 2))
+	assert.False(t, p.Contains(5, 5))
+
+	r, err = ParseWKT("multipolygon Z (((0 0 1, 1 0 1, 1 1 1, 0 0 1)), ((5 5 2, 6 5 2, 6 6 2, 5 5 2)))")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(r.(MultiPolygon)))
+	assert.Equal(t, Point{Lat: 5, Lon: 6}, r.(MultiPolygon)[1][0][1])
+
+	_, err = ParseWKT("POINT (1 2)")
+	assert.Error(t, err)
+	_, err = ParseWKT("POLYGON ((0 0, 1 0")
+	assert.Error(t, err)
+}
+
+func TestParseGeoJSONRegion(t *testing.T) {
+	r, err := ParseGeoJSONRegion([]byte(`{"type": "Feature", "properties": {},
+		"geometry": {"type": "Polygon", "coordinates": [[[8, 44], [9, 44], [9, 45], [8, 44]]]}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, Polygon{{{44, 8}, {44, 9}, {45, 9}, {44, 8}}}, r)
+
+	r, err = ParseGeoJSONRegion([]byte(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[8, 44], [9, 44], [9, 45], [8, 44]]]}},
+		{"type": "Feature", "geometry": {"type": "MultiPolygon", "coordinates": [
+			[[[0, 0], [1, 0], [1, 1], [0, 0]]],
+			[[[2, 2], [3, 2], [3, 3], [2, 2]]]
+		]}}
+	]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(r.(MultiPolygon)))
+
+	_, err = ParseGeoJSONRegion([]byte(`{"type": "Point", "coordinates": [1, 2]}`))
+	assert.Error(t, err)
+}
+
+// shapefile builds the content of a .shp file
+// containing a polygon record for each element of
+// polygons, given as lists of rings of lon, lat pairs.
+func shapefile(polygons ...[][]float64) []byte {
+	records := &bytes.Buffer{}
+	for i, rings := range polygons {
+		content := &bytes.Buffer{}
+		numPoints := 0
+		parts := []int32{}
+		for _, ring := range rings {
+			parts = append(parts, int32(numPoints))
+			numPoints += len(ring) / 2
+		}
+		binary.Write(content, binary.LittleEndian, int32(shapePolygon))
+		binary.Write(content, binary.LittleEndian, [4]float64{})
+		binary.Write(content, binary.LittleEndian, int32(len(rings)))
+		binary.Write(content, binary.LittleEndian, int32(numPoints))
+		binary.Write(content, binary.LittleEndian, parts)
+		for _, ring := range rings {
+			binary.Write(content, binary.LittleEndian, ring)
+		}
+		binary.Write(records, binary.BigEndian, int32(i+1))
+		binary.Write(records, binary.BigEndian, int32(content.Len()/2))
+		records.Write(content.Bytes())
+	}
+
+	header := make([]byte, 100)
+	binary.BigEndian.PutUint32(header[0:4], 9994)
+	binary.BigEndian.PutUint32(header[24:28], uint32((100+records.Len())/2))
+	binary.LittleEndian.PutUint32(header[28:32], 1000)
+	binary.LittleEndian.PutUint32(header[32:36], shapePolygon)
+	return append(header, records.Bytes()...)
+}
+
+func TestReadShapefile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "region.shp")
+	content := shapefile(
+		[][]float64{
+			{0, 0, 0, 10, 10, 10, 10, 0, 0, 0},
+			{4, 4, 6, 4, 6, 6, 4, 6, 4, 4},
+		},
+		[][]float64{
+			{20, 20, 20, 21, 21, 21, 20, 20},
+		},
+	)
+	assert.NoError(t, ioutil.WriteFile(file, content, 0644))
+
+	r, err := RegionFromS(file)
+	assert.NoError(t, err)
+	mp := r.(MultiPolygon)
+	assert.Equal(t, 2, len(mp))
+	assert.Equal(t, 2, len(mp[0]))
+	assert.True(t, mp.Contains(2, 2))
+	assert.False(t, mp.Contains(5, 5))
+	assert.True(t, mp.Contains(20.5, 20.2))
+
+	assert.NoError(t, ioutil.WriteFile(file, content[:150], 0644))
+	_, err = ReadShapefile(file)
+	assert.EqualError(t, err, file+": truncated record")
+
+	// a polygon record with its shape type only
+	short := append([]byte{}, content[:100]...)
+	short = append(short, 0, 0, 0, 1, 0, 0, 0, 2, byte(shapePolygon), 0, 0, 0)
+	assert.NoError(t, ioutil.WriteFile(file, short, 0644))
+	_, err = ReadShapefile(file)
+	assert.EqualError(t, err, file+": invalid polygon record")
+}
+
+func TestRegionFromS(t *testing.T) {
+	r, err := RegionFromS("40,45,10,14")
+	assert.NoError(t, err)
+	assert.Equal(t, Domain{MinLat: 40, MaxLat: 45, MinLon: 10, MaxLon: 14}, r)
+
+	r, err = RegionFromS("POLYGON((10 40, 14 40, 14 45, 10 40))")
+	assert.NoError(t, err)
+	assert.IsType(t, Polygon{}, r)
+
+	file := filepath.Join(t.TempDir(), "region.geojson")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`{"type": "Polygon", "coordinates": [[[10, 40], [14, 40], [14, 45], [10, 40]]]}`), 0644))
+	r, err = RegionFromS(file)
+	assert.NoError(t, err)
+	assert.True(t, r.Contains(41, 13))
+	assert.False(t, r.Contains(44, 11))
+}
@@ -0,0 +1,387 @@
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// RegionFromS returns a new Region accordingly to the given
+// string, that could be a WKT POLYGON or MULTIPOLYGON in
+// lon lat order, the path of a .wkt, .geojson, .json or .shp
+// file containing polygons with WGS84 lon lat coordinates,
+// or MinLat,MaxLat,MinLon,MaxLon values as accepted by DomainFromS.
+func RegionFromS(s string) (Region, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	if strings.HasPrefix(upper, "POLYGON") || strings.HasPrefix(upper, "MULTIPOLYGON") {
+		return ParseWKT(s)
+	}
+
+	switch strings.ToLower(filepath.Ext(s)) {
+	case ".shp":
+		return ReadShapefile(s)
+	case ".wkt":
+		content, err := ioutil.ReadFile(s)
+		if err != nil {
+			return nil, err
+		}
+		return ParseWKT(string(content))
+	case ".geojson", ".json":
+		content, err := ioutil.ReadFile(s)
+		if err != nil {
+			return nil, err
+		}
+		return ParseGeoJSONRegion(content)
+	}
+
+	domain, err := DomainFromS(s)
+	if err != nil {
+		return nil, err
+	}
+	return *domain, nil
+}
+
+// wktParser parses WKT text
+type wktParser struct {
+	text string
+	pos  int
+}
+
+func (p *wktParser) skipSpaces() {
+	for p.pos < len(p.text) && unicode.IsSpace(rune(p.text[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *wktParser) expect(ch byte) error {
+	p.skipSpaces()
+	if p.pos >= len(p.text) || p.text[p.pos] != ch {
+		return fmt.Errorf("WKT: expected `%c` at position %d", ch, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+// next returns whether next character is ch,
+// consuming it in that case.
+func (p *wktParser) next(ch byte) bool {
+	p.skipSpaces()
+	if p.pos < len(p.text) && p.text[p.pos] == ch {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *wktParser) number() (float64, error) {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.text) && strings.IndexByte("+-.0123456789eE", p.text[p.pos]) >= 0 {
+		p.pos++
+	}
+	v, err := strconv.ParseFloat(p.text[start:p.pos], 64)
+	if err != nil {
+		return 0, fmt.Errorf("WKT: invalid number at position %d", start)
+	}
+	return v, nil
+}
+
+// ring parses a list of points, ignoring
+// Z and M coordinates if present.
+func (p *wktParser) ring() ([]Point, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	ring := []Point{}
+	for {
+		lon, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		lat, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		ring = append(ring, Point{Lat: lat, Lon: lon})
+		p.skipSpaces()
+		for p.pos < len(p.text) && p.text[p.pos] != ',' && p.text[p.pos] != ')' {
+			if _, err := p.number(); err != nil {
+				return nil, err
+			}
+			p.skipSpaces()
+		}
+		if !p.next(',') {
+			break
+		}
+	}
+	return ring, p.expect(')')
+}
+
+func (p *wktParser) polygon() (Polygon, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	polygon := Polygon{}
+	for {
+		ring, err := p.ring()
+		if err != nil {
+			return nil, err
+		}
+		polygon = append(polygon, ring)
+		if !p.next(',') {
+			break
+		}
+	}
+	return polygon, p.expect(')')
+}
+
+// skipTag consumes tag and optional Z, M
+// or ZM dimension modifiers following it.
+func (p *wktParser) skipTag(tag string) {
+	p.skipSpaces()
+	p.pos += len(tag)
+	p.skipSpaces()
+	for p.pos < len(p.text) && unicode.IsLetter(rune(p.text[p.pos])) {
+		p.pos++
+	}
+}
+
+// ParseWKT returns the Region represented by
+// WKT text, that must be a POLYGON or a MULTIPOLYGON.
+func ParseWKT(text string) (Region, error) {
+	p := &wktParser{text: strings.ToUpper(strings.TrimSpace(text))}
+
+	if strings.HasPrefix(p.text, "MULTIPOLYGON") {
+		p.skipTag("MULTIPOLYGON")
+		if err := p.expect('('); err != nil {
+			return nil, err
+		}
+		mp := MultiPolygon{}
+		for {
+			polygon, err := p.polygon()
+			if err != nil {
+				return nil, err
+			}
+			mp = append(mp, polygon)
+			if !p.next(',') {
+				break
+			}
+		}
+		return mp, p.expect(')')
+	}
+
+	if strings.HasPrefix(p.text, "POLYGON") {
+		p.skipTag("POLYGON")
+		return p.polygon()
+	}
+
+	return nil, errors.New("WKT: only POLYGON and MULTIPOLYGON are supported")
+}
+
+type geoJSONObject struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSONObject  `json:"geometry"`
+	Geometries  []geoJSONObject `json:"geometries"`
+	Features    []geoJSONObject `json:"features"`
+}
+
+func geoJSONPolygon(coords [][][]float64) (Polygon, error) {
+	polygon := make(Polygon, len(coords))
+	for i, ring := range coords {
+		polygon[i] = make([]Point, len(ring))
+		for j, pos := range ring {
+			if len(pos) < 2 {
+				return nil, errors.New("GeoJSON: invalid position")
+			}
+			polygon[i][j] = Point{Lat: pos[1], Lon: pos[0]}
+		}
+	}
+	return polygon, nil
+}
+
+// polygons returns all polygons contained in obj
+func (obj geoJSONObject) polygons() (MultiPolygon, error) {
+	switch obj.Type {
+	case "Polygon":
+		var coords [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &coords); err != nil {
+			return nil, err
+		}
+		polygon, err := geoJSONPolygon(coords)
+		return MultiPolygon{polygon}, err
+	case "MultiPolygon":
+		var coords [][][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &coords); err != nil {
+			return nil, err
+		}
+		mp := MultiPolygon{}
+		for _, c := range coords {
+			polygon, err := geoJSONPolygon(c)
+			if err != nil {
+				return nil, err
+			}
+			mp = append(mp, polygon)
+		}
+		return mp, nil
+	case "Feature":
+		if obj.Geometry == nil {
+			return MultiPolygon{}, nil
+		}
+		return obj.Geometry.polygons()
+	case "FeatureCollection", "GeometryCollection":
+		children := obj.Features
+		if obj.Type == "GeometryCollection" {
+			children = obj.Geometries
+		}
+		mp := MultiPolygon{}
+		for _, child := range children {
+			polygons, err := child.polygons()
+			if err != nil {
+				return nil, err
+			}
+			mp = append(mp, polygons...)
+		}
+		return mp, nil
+	}
+	return nil, fmt.Errorf("GeoJSON: unsupported type `%s`", obj.Type)
+}
+
+// ParseGeoJSONRegion returns the Region made of all
+// Polygon and MultiPolygon geometries contained in a GeoJSON
+// geometry, feature or feature collection.
+func ParseGeoJSONRegion(content []byte) (Region, error) {
+	var obj geoJSONObject
+	if err := json.Unmarshal(content, &obj); err != nil {
+		return nil, err
+	}
+	mp, err := obj.polygons()
+	if err != nil {
+		return nil, err
+	}
+	if len(mp) == 0 {
+		return nil, errors.New("GeoJSON: no polygons found")
+	}
+	if len(mp) == 1 {
+		return mp[0], nil
+	}
+	return mp, nil
+}
+
+// shapefile shape types of polygons
+const (
+	shapePolygon  = 5
+	shapePolygonZ = 15
+	shapePolygonM = 25
+)
+
+// ReadShapefile returns the MultiPolygon made of all polygons
+// contained in an ESRI shapefile (.shp). Coordinates must be
+// WGS84 longitudes and latitudes: the .prj file is ignored.
+func ReadShapefile(file string) (Region, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) < 100 || binary.BigEndian.Uint32(content[0:4]) != 9994 {
+		return nil, fmt.Errorf("%s: not a shapefile", file)
+	}
+
+	mp := MultiPolygon{}
+	for pos := 100; pos+8 <= len(content); {
+		length := int(binary.BigEndian.Uint32(content[pos+4:pos+8])) * 2
+		pos += 8
+		if pos+length > len(content) {
+			return nil, fmt.Errorf("%s: truncated record", file)
+		}
+		record := content[pos : pos+length]
+		pos += length
+
+		if len(record) < 4 {
+			continue
+		}
+		shapeType := binary.LittleEndian.Uint32(record[0:4])
+		if shapeType != shapePolygon && shapeType != shapePolygonZ && shapeType != shapePolygonM {
+			continue
+		}
+		polygon, err := shapefilePolygon(record)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		mp = append(mp, polygon)
+	}
+
+	if len(mp) == 0 {
+		return nil, fmt.Errorf("%s: no polygons found", file)
+	}
+	return mp, nil
+}
+
+// shapefilePolygon decodes a polygon record: shape type,
+// bounding box, number of parts and points, parts indexes
+// and points.
+func shapefilePolygon(record []byte) (Polygon, error) {
+	if len(record) < 44 {
+		return nil, errors.New("invalid polygon record")
+	}
+	r := bytes.NewReader(record[36:])
+	var numParts, numPoints int32
+	if err := binary.Read(r, binary.LittleEndian, &numParts); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &numPoints); err != nil {
+		return nil, err
+	}
+	if numParts < 0 || numPoints < 0 || int(numParts)*4+int(numPoints)*16 > r.Len() {
+		return nil, errors.New("invalid polygon record")
+	}
+	parts := make([]int32, numParts)
+	if err := binary.Read(r, binary.LittleEndian, parts); err != nil {
+		return nil, err
+	}
+	points := make([]float64, numPoints*2)
+	if err := binary.Read(r, binary.LittleEndian, points); err != nil {
+		return nil, err
+	}
+
+	polygon := make(Polygon, numParts)
+	for i := range parts {
+		start := int(parts[i])
+		end := int(numPoints)
+		if i+1 < len(parts) {
+			end = int(parts[i+1])
+		}
+		if start < 0 || start > end || end > int(numPoints) {
+			return nil, errors.New("invalid polygon parts")
+		}
+		ring := make([]Point, 0, end-start)
+		for j := start; j < end; j++ {
+			ring = append(ring, Point{Lat: points[j*2+1], Lon: points[j*2]})
+		}
+		polygon[i] = ring
+	}
+	return polygon, nil
+}
+
+// NewBuffer returns region extended by distance meters
+// around its border, or region itself when distance
+// is not greater than zero. It returns an error when
+// region is not a Domain, a Polygon or a MultiPolygon,
+// e.g. the grid of a WRF domain, whose border is unknown.
+func NewBuffer(region Region, distance float64) (Region, error) {
+	if distance <= 0 || math.IsNaN(distance) {
+		return region, nil
+	}
+	if _, ok := region.(boundary); !ok {
+		return nil, fmt.Errorf("buffer is not supported for %T regions", region)
+	}
+	return Buffer{Region: region, Distance: distance}, nil
+}
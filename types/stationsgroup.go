@@ -1,5 +1,7 @@
 package types
 
+import "strconv"
+
 // StationsGroup is an enum that represents
 // category of meteo stations (e.g. wunderground
 // stations or DPC network stations)
@@ -10,4 +12,33 @@ const (
 	Wunderground StationsGroup = iota
 	// DPCTrusted represents trusted italian stations
 	DPCTrusted
+	// Netatmo represents netatmo personal stations
+	Netatmo
+	// WMOStations represents stations of the WMO
+	// network, exchanged as METAR, SYNOP or BUFR reports
+	WMOStations
+	// Unknown is the group of stations read from
+	// sources that don't tell their network
+	// (e.g. WRF ascii files)
+	Unknown
 )
+
+// String implements fmt.Stringer for StationsGroup
+func (g StationsGroup) String() string {
+	if g == Wunderground {
+		return "Wunderground"
+	}
+	if g == DPCTrusted {
+		return "DPCTrusted"
+	}
+	if g == Netatmo {
+		return "Netatmo"
+	}
+	if g == WMOStations {
+		return "WMOStations"
+	}
+	if g == Unknown {
+		return "Unknown"
+	}
+	return strconv.Itoa(int(g))
+}
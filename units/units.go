@@ -0,0 +1,191 @@
+// Package units converts values of physical quantities
+// between units of measure. Values of types.Observation
+// are always expressed in base units: K for temperatures,
+// m/s for speeds, Pa for pressures, mm for precipitation,
+// m for lengths, % for ratios and degrees for angles.
+package units
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Quantity is a kind of physical quantity.
+type Quantity int
+
+const (
+	// Temperature is measured in K
+	Temperature Quantity = iota
+	// Speed is measured in m/s
+	Speed
+	// Pressure is measured in Pa
+	Pressure
+	// Precipitation is measured in mm
+	Precipitation
+	// Length is measured in m
+	Length
+	// Ratio is measured in %
+	Ratio
+	// Angle is measured in degrees
+	Angle
+)
+
+func (q Quantity) String() string {
+	if q == Temperature {
+		return "temperature"
+	}
+	if q == Speed {
+		return "speed"
+	}
+	if q == Pressure {
+		return "pressure"
+	}
+	if q == Precipitation {
+		return "precipitation"
+	}
+	if q == Length {
+		return "length"
+	}
+	if q == Ratio {
+		return "ratio"
+	}
+	if q == Angle {
+		return "angle"
+	}
+	return fmt.Sprintf("Quantity(%d)", int(q))
+}
+
+// Unit is a unit of measure of a Quantity.
+// A value v expressed in the unit corresponds
+// to v*scale+offset in the base unit of
+// the quantity.
+type Unit struct {
+	Symbol   string
+	Quantity Quantity
+	scale    float64
+	offset   float64
+}
+
+// Known units of measure.
+var (
+	Kelvin     = Unit{"K", Temperature, 1, 0}
+	Celsius    = Unit{"°C", Temperature, 1, 273.15}
+	Fahrenheit = Unit{"°F", Temperature, 5.0 / 9, 273.15 - 32*5.0/9}
+
+	MetersPerSecond   = Unit{"m/s", Speed, 1, 0}
+	KilometersPerHour = Unit{"km/h", Speed, 0.277778, 0}
+	Knots             = Unit{"kn", Speed, 0.514444, 0}
+	MilesPerHour      = Unit{"mph", Speed, 0.44704, 0}
+
+	Pascal          = Unit{"Pa", Pressure, 1, 0}
+	Hectopascal     = Unit{"hPa", Pressure, 100, 0}
+	Millibar        = Unit{"mbar", Pressure, 100, 0}
+	Kilopascal      = Unit{"kPa", Pressure, 1000, 0}
+	InchesOfMercury = Unit{"inHg", Pressure, 3386.389, 0}
+
+	Millimeter = Unit{"mm", Precipitation, 1, 0}
+	Inch       = Unit{"in", Precipitation, 25.4, 0}
+
+	Meter     = Unit{"m", Length, 1, 0}
+	Kilometer = Unit{"km", Length, 1000, 0}
+	Foot      = Unit{"ft", Length, 0.3048, 0}
+	Mile      = Unit{"mi", Length, 1609.344, 0}
+
+	Percent = Unit{"%", Ratio, 1, 0}
+
+	Degree = Unit{"deg", Angle, 1, 0}
+)
+
+// symbols contains the symbols accepted
+// by Parse for each unit, besides its own.
+var symbols = []struct {
+	unit    Unit
+	aliases []string
+}{
+	{Kelvin, nil},
+	{Celsius, []string{"C", "degC", "celsius"}},
+	{Fahrenheit, []string{"F", "degF", "fahrenheit"}},
+	{MetersPerSecond, []string{"m s-1", "mps"}},
+	{KilometersPerHour, []string{"kmh", "kph"}},
+	{Knots, []string{"kt", "kts", "knots"}},
+	{MilesPerHour, []string{"mi/h"}},
+	{Pascal, nil},
+	{Hectopascal, nil},
+	{Millibar, []string{"mb"}},
+	{Kilopascal, nil},
+	{InchesOfMercury, []string{"in Hg"}},
+	{Millimeter, nil},
+	{Inch, []string{"inches"}},
+	{Meter, nil},
+	{Kilometer, nil},
+	{Foot, []string{"feet"}},
+	{Mile, []string{"miles"}},
+	{Percent, nil},
+	{Degree, []string{"°", "degrees"}},
+}
+
+// Parse returns the unit of quantity q with given symbol,
+// or an error if the symbol is not known for q.
+// Symbols are compared case insensitively, so that
+// e.g. `Km/h` and `KT` are accepted too.
+func Parse(q Quantity, symbol string) (Unit, error) {
+	symbol = strings.TrimSpace(symbol)
+	for _, s := range symbols {
+		if s.unit.Quantity != q {
+			continue
+		}
+		if strings.EqualFold(s.unit.Symbol, symbol) {
+			return s.unit, nil
+		}
+		for _, alias := range s.aliases {
+			if strings.EqualFold(alias, symbol) {
+				return s.unit, nil
+			}
+		}
+	}
+	return Unit{}, fmt.Errorf("unknown %s unit `%s`", q, symbol)
+}
+
+// ToBase converts v from u into the base unit of u's
+// quantity. The zero Unit converts every value to NaN,
+// so that values in undeclared units are never mis-scaled.
+func (u Unit) ToBase(v float64) float64 {
+	if u.scale == 0 {
+		return math.NaN()
+	}
+	return v*u.scale + u.offset
+}
+
+// FromBase converts v from the base unit
+// of u's quantity into u.
+func (u Unit) FromBase(v float64) float64 {
+	if u.scale == 0 {
+		return math.NaN()
+	}
+	return (v - u.offset) / u.scale
+}
+
+func (u Unit) String() string {
+	return u.Symbol
+}
+
+// Convert converts v from unit from to unit to, returning
+// an error if the two units measure different quantities.
+func Convert(v float64, from, to Unit) (float64, error) {
+	if from.Quantity != to.Quantity {
+		return 0, fmt.Errorf("cannot convert %s `%s` into %s `%s`", from.Quantity, from, to.Quantity, to)
+	}
+	return to.FromBase(from.ToBase(v)), nil
+}
+
+// Source declares the units of values read from a
+// source of observations. Quantities not provided
+// by the source are left to the zero Unit.
+type Source struct {
+	Temperature   Unit
+	Speed         Unit
+	Pressure      Unit
+	Precipitation Unit
+	Length        Unit
+}
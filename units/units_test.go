@@ -0,0 +1,78 @@
+package units
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToBase(t *testing.T) {
+	assert.InDelta(t, 293.15, Celsius.ToBase(20), 1e-9)
+	assert.InDelta(t, 273.15, Fahrenheit.ToBase(32), 1e-9)
+	assert.InDelta(t, 373.15, Fahrenheit.ToBase(212), 1e-9)
+	assert.Equal(t, 280.0, Kelvin.ToBase(280))
+	assert.InDelta(t, 10, KilometersPerHour.ToBase(36), 1e-4)
+	assert.InDelta(t, 5.14444, Knots.ToBase(10), 1e-9)
+	assert.InDelta(t, 4.4704, MilesPerHour.ToBase(10), 1e-9)
+	assert.Equal(t, 101325.0, Hectopascal.ToBase(1013.25))
+	assert.Equal(t, 101325.0, Millibar.ToBase(1013.25))
+	assert.InDelta(t, 101591.67, InchesOfMercury.ToBase(30), 0.01)
+	assert.Equal(t, 25.4, Inch.ToBase(1))
+	assert.InDelta(t, 304.8, Foot.ToBase(1000), 1e-9)
+	assert.True(t, math.IsNaN(Celsius.ToBase(math.NaN())))
+	assert.True(t, math.IsNaN(Unit{}.ToBase(20)))
+}
+
+func TestFromBase(t *testing.T) {
+	assert.InDelta(t, 20, Celsius.FromBase(293.15), 1e-9)
+	assert.InDelta(t, 212, Fahrenheit.FromBase(373.15), 1e-9)
+	assert.InDelta(t, 1013.25, Hectopascal.FromBase(101325), 1e-9)
+	assert.True(t, math.IsNaN(Unit{}.FromBase(20)))
+}
+
+func TestConvert(t *testing.T) {
+	v, err := Convert(100, Celsius, Fahrenheit)
+	assert.NoError(t, err)
+	assert.InDelta(t, 212, v, 1e-9)
+
+	v, err = Convert(29.92, InchesOfMercury, Hectopascal)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1013.2, v, 0.1)
+
+	_, err = Convert(10, Knots, Hectopascal)
+	assert.EqualError(t, err, "cannot convert speed `kn` into pressure `hPa`")
+}
+
+func TestParse(t *testing.T) {
+	for symbol, expected := range map[string]Unit{
+		"°C":   Celsius,
+		"C":    Celsius,
+		"degC": Celsius,
+		"°F":   Fahrenheit,
+		"K":    Kelvin,
+		"Km/h": KilometersPerHour,
+		"KMH":  KilometersPerHour,
+		"KT":   Knots,
+		"MPS":  MetersPerSecond,
+		"mph":  MilesPerHour,
+		"hPa":  Hectopascal,
+		"mb":   Millibar,
+		"inHg": InchesOfMercury,
+	} {
+		q := expected.Quantity
+		unit, err := Parse(q, symbol)
+		assert.NoError(t, err, symbol)
+		assert.Equal(t, expected, unit, symbol)
+	}
+
+	unit, err := Parse(Precipitation, " in ")
+	assert.NoError(t, err)
+	assert.Equal(t, Inch, unit)
+
+	_, err = Parse(Temperature, "°R")
+	assert.EqualError(t, err, "unknown temperature unit `°R`")
+
+	_, err = Parse(Temperature, "Km/h")
+	assert.EqualError(t, err, "unknown temperature unit `Km/h`")
+}
@@ -0,0 +1,19 @@
+package dewetra2wrf
+
+import (
+	"github.com/meteocima/dewetra2wrf/types"
+	"github.com/meteocima/dewetra2wrf/wind"
+)
+
+// normalizeWind normalises wind directions of observations,
+// and of their levels, with wind.Direction: whatever the
+// source, calm wind has direction 0 and wind from north 360.
+func normalizeWind(observations []types.Observation) []types.Observation {
+	for i, obs := range observations {
+		observations[i].WinddirAvg = types.Value(wind.Direction(obs.Metric.WindspeedAvg.AsFloat(), obs.WinddirAvg.AsFloat()))
+		for j, level := range obs.Levels {
+			obs.Levels[j].WinddirAvg = types.Value(wind.Direction(level.WindspeedAvg.AsFloat(), level.WinddirAvg.AsFloat()))
+		}
+	}
+	return observations
+}
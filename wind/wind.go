@@ -0,0 +1,128 @@
+// Package wind handles wind as a vector: it converts
+// speed and direction into u/v components and back,
+// normalises reported directions and averages wind
+// over time. Speeds are in m/s, directions in degrees
+// clockwise from north, telling where wind blows from.
+package wind
+
+import "math"
+
+// CalmSpeed is the speed below which wind is calm,
+// that is less than 1 knot.
+const CalmSpeed = 0.5
+
+// VariableSteadiness is the steadiness of directions,
+// the length of the mean of their unit vectors, below
+// which an average wind has a variable direction.
+// It corresponds to directions spread with a circular
+// standard deviation of about 67°.
+const VariableSteadiness = 0.5
+
+// Direction normalises dir, reported for a wind with given
+// speed, following the WMO convention: 0 means calm, so it's
+// returned for calm winds whatever their direction, while
+// wind from north is 360. Other directions are brought
+// within (0, 360]. A NaN dir, for a wind whose direction
+// is missing or variable, is returned as is.
+func Direction(speed, dir float64) float64 {
+	if math.IsNaN(dir) {
+		return dir
+	}
+	if speed < CalmSpeed {
+		return 0
+	}
+	dir = math.Mod(dir, 360)
+	if dir <= 0 {
+		dir += 360
+	}
+	return dir
+}
+
+// IsVariable returns whether a wind with given
+// speed and direction blows from a variable direction,
+// as reported by METAR VRB groups.
+func IsVariable(speed, dir float64) bool {
+	return !math.IsNaN(speed) && speed >= CalmSpeed && math.IsNaN(dir)
+}
+
+// Components returns the eastward (u) and northward (v)
+// components of a wind with given speed and direction.
+// Calm winds have null components, whatever their
+// direction, while they are NaN when speed is missing
+// or direction is missing or variable.
+func Components(speed, dir float64) (u, v float64) {
+	if speed < CalmSpeed {
+		return 0, 0
+	}
+	// 0 and 360 both give null
+	// components, with the same sign.
+	rad := math.Mod(dir, 360) * math.Pi / 180
+	return -speed * math.Sin(rad), -speed * math.Cos(rad)
+}
+
+// FromComponents returns speed and direction of
+// a wind with eastward component u and northward
+// component v, normalised by Direction.
+func FromComponents(u, v float64) (speed, dir float64) {
+	speed = math.Hypot(u, v)
+	return speed, Direction(speed, bearing(u, v))
+}
+
+// bearing returns the direction a vector with
+// components u and v points from, in degrees.
+func bearing(u, v float64) float64 {
+	return math.Atan2(-u, -v) * 180 / math.Pi
+}
+
+// Sample is a measurement of wind.
+type Sample struct {
+	Speed     float64
+	Direction float64
+}
+
+// Average returns the mean wind of samples, as recommended
+// by WMO for surface wind: speed is the scalar mean of speeds,
+// while direction is the one of the mean of unit vectors of
+// non calm samples, so that directions across north are
+// averaged correctly. Direction is NaN, i.e. variable, when
+// its steadiness is below VariableSteadiness, and 0 when
+// the mean wind is calm. Samples with a missing speed are
+// ignored; it returns NaN when there are none.
+func Average(samples []Sample) (speed, dir float64) {
+	n := 0
+	sumSpeed := 0.0
+	blowing := 0
+	var sumU, sumV float64
+	for _, s := range samples {
+		if math.IsNaN(s.Speed) {
+			continue
+		}
+		n++
+		sumSpeed += s.Speed
+		if s.Speed < CalmSpeed {
+			continue
+		}
+		blowing++
+		if math.IsNaN(s.Direction) {
+			// variable directions add
+			// to the spread of directions.
+			continue
+		}
+		u, v := Components(1, s.Direction)
+		sumU += u
+		sumV += v
+	}
+	if n == 0 {
+		return math.NaN(), math.NaN()
+	}
+
+	speed = sumSpeed / float64(n)
+	if speed < CalmSpeed {
+		return speed, 0
+	}
+	meanU, meanV := sumU/float64(blowing), sumV/float64(blowing)
+	if math.Hypot(meanU, meanV) < VariableSteadiness {
+		return speed, math.NaN()
+	}
+	return speed, Direction(speed, bearing(meanU, meanV))
+}
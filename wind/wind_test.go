@@ -0,0 +1,83 @@
+package wind
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirection(t *testing.T) {
+	assert.Equal(t, 360.0, Direction(5, 0))
+	assert.Equal(t, 360.0, Direction(5, 360))
+	assert.Equal(t, 90.0, Direction(5, 450))
+	assert.Equal(t, 350.0, Direction(5, -10))
+	assert.Equal(t, 0.0, Direction(0.2, 250))
+	assert.True(t, math.IsNaN(Direction(5, math.NaN())))
+}
+
+func TestIsVariable(t *testing.T) {
+	assert.True(t, IsVariable(3, math.NaN()))
+	assert.False(t, IsVariable(0, math.NaN()))
+	assert.False(t, IsVariable(math.NaN(), math.NaN()))
+	assert.False(t, IsVariable(3, 120))
+}
+
+func TestComponents(t *testing.T) {
+	// wind from west blows eastward
+	u, v := Components(10, 270)
+	assert.InDelta(t, 10, u, 1e-9)
+	assert.InDelta(t, 0, v, 1e-9)
+	// wind from north blows southward
+	u, v = Components(10, 360)
+	assert.InDelta(t, 0, u, 1e-9)
+	assert.InDelta(t, -10, v, 1e-9)
+
+	u, v = Components(0, 0)
+	assert.Equal(t, 0.0, u)
+	assert.Equal(t, 0.0, v)
+
+	u, v = Components(10, math.NaN())
+	assert.True(t, math.IsNaN(u))
+	assert.True(t, math.IsNaN(v))
+	u, _ = Components(math.NaN(), 90)
+	assert.True(t, math.IsNaN(u))
+}
+
+func TestFromComponents(t *testing.T) {
+	for _, dir := range []float64{45, 135, 225, 315, 360} {
+		speed, actual := FromComponents(Components(7, dir))
+		assert.InDelta(t, 7, speed, 1e-9)
+		assert.InDelta(t, dir, actual, 1e-9)
+	}
+	speed, dir := FromComponents(0, 0)
+	assert.Equal(t, 0.0, speed)
+	assert.Equal(t, 0.0, dir)
+}
+
+func TestAverage(t *testing.T) {
+	// scalar averaging would give 180
+	speed, dir := Average([]Sample{{4, 350}, {6, 10}, {5, 0}})
+	assert.InDelta(t, 5, speed, 1e-9)
+	assert.InDelta(t, 360, dir, 1e-9)
+
+	speed, dir = Average([]Sample{{4, 80}, {math.NaN(), 200}, {4, 100}})
+	assert.InDelta(t, 4, speed, 1e-9)
+	assert.InDelta(t, 90, dir, 1e-9)
+
+	// opposite directions
+	speed, dir = Average([]Sample{{4, 90}, {4, 270}})
+	assert.InDelta(t, 4, speed, 1e-9)
+	assert.True(t, math.IsNaN(dir))
+
+	_, dir = Average([]Sample{{4, 90}, {4, math.NaN()}, {4, math.NaN()}})
+	assert.True(t, math.IsNaN(dir))
+
+	speed, dir = Average([]Sample{{0.2, 90}, {0.4, 270}, {0, 0}})
+	assert.InDelta(t, 0.2, speed, 1e-9)
+	assert.Equal(t, 0.0, dir)
+
+	speed, dir = Average([]Sample{{math.NaN(), 90}})
+	assert.True(t, math.IsNaN(speed))
+	assert.True(t, math.IsNaN(dir))
+}
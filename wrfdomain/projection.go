@@ -0,0 +1,133 @@
+package wrfdomain
+
+import (
+	"fmt"
+	"math"
+)
+
+// MAP_PROJ values of WRF projections
+const (
+	Lambert     = 1
+	PolarStereo = 2
+	Mercator    = 3
+	LatLon      = 6
+)
+
+// earthRadius is the earth radius in meters used by WRF
+const earthRadius = 6370000.0
+
+const radPerDeg = math.Pi / 180
+
+// Projection contains the map projection parameters
+// of a WRF domain, as found in global attributes of
+// geo_em and wrfinput files.
+type Projection struct {
+	MapProj  int
+	TrueLat1 float64
+	TrueLat2 float64
+	StandLon float64
+	PoleLat  float64
+	PoleLon  float64
+}
+
+// Validate returns an error if the projection
+// is not supported.
+func (p Projection) Validate() error {
+	switch p.MapProj {
+	case Lambert, PolarStereo, Mercator, LatLon:
+		return nil
+	}
+	return fmt.Errorf("unsupported MAP_PROJ %d", p.MapProj)
+}
+
+// wrapLon returns lon normalized in range [-180, 180)
+func wrapLon(lon float64) float64 {
+	lon = math.Mod(lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return lon - 180
+}
+
+// coneFactor returns the cone factor of
+// a Lambert conformal projection.
+func (p Projection) coneFactor() float64 {
+	t1, t2 := math.Abs(p.TrueLat1)*radPerDeg, math.Abs(p.TrueLat2)*radPerDeg
+	if math.Abs(p.TrueLat1-p.TrueLat2) > 0.1 {
+		return (math.Log(math.Cos(t1)) - math.Log(math.Cos(t2))) /
+			(math.Log(math.Tan(math.Pi/4-t1/2)) - math.Log(math.Tan(math.Pi/4-t2/2)))
+	}
+	return math.Sin(t1)
+}
+
+// project returns the coordinates of the point at
+// lat, lon on the plane of the projection. refLon
+// is the longitude, on the projection plane, that
+// cylindrical projections use as origin of x, so
+// that a domain doesn't cross the discontinuity at
+// the antimeridian.
+// Units of x and y are meters for conic and azimuthal
+// projections, and degrees for the lat-lon one: only
+// their ratio with grid spacing matters.
+func (p Projection) project(lat, lon, refLon float64) (x, y float64) {
+	hemi := 1.0
+	if p.TrueLat1 < 0 {
+		hemi = -1
+	}
+
+	switch p.MapProj {
+	case Lambert, PolarStereo:
+		n := 1.0
+		if p.MapProj == Lambert {
+			n = p.coneFactor()
+		}
+		t1 := hemi * p.TrueLat1 * radPerDeg
+		phi := hemi * lat * radPerDeg
+		var r float64
+		if p.MapProj == Lambert {
+			r = earthRadius * math.Cos(t1) / n *
+				math.Pow(math.Tan(math.Pi/4-phi/2)/math.Tan(math.Pi/4-t1/2), n)
+		} else {
+			r = earthRadius * (1 + math.Sin(t1)) * math.Tan(math.Pi/4-phi/2)
+		}
+		theta := n * wrapLon(lon-p.StandLon) * radPerDeg
+		return r * math.Sin(theta), -hemi * r * math.Cos(theta)
+
+	case Mercator:
+		scale := earthRadius * math.Cos(p.TrueLat1*radPerDeg)
+		x = scale * wrapLon(lon-refLon) * radPerDeg
+		y = scale * math.Log(math.Tan(math.Pi/4+lat*radPerDeg/2))
+		return x, y
+	}
+
+	// LatLon
+	rlat, rlon := p.rotate(lat, lon)
+	return wrapLon(rlon - refLon), rlat
+}
+
+// rotate returns the coordinates of the point at lat, lon on the
+// computational grid of a lat-lon projection, whose north pole
+// is at PoleLat, PoleLon and that is rotated by StandLon
+// around the earth axis. It follows rotate_coords of
+// WRF map_utils module.
+func (p Projection) rotate(lat, lon float64) (rlat, rlon float64) {
+	phiNP := p.PoleLat * radPerDeg
+	lamNP := p.PoleLon * radPerDeg
+	lam0 := p.StandLon * radPerDeg
+	phi := lat * radPerDeg
+	lam := lon * radPerDeg
+
+	dlam := math.Pi - lam0
+	sinphi := math.Cos(phiNP)*math.Cos(phi)*math.Cos(lam-dlam) + math.Sin(phiNP)*math.Sin(phi)
+	cosphi := math.Sqrt(1 - sinphi*sinphi)
+	coslam := math.Sin(phiNP)*math.Cos(phi)*math.Cos(lam-dlam) - math.Cos(phiNP)*math.Sin(phi)
+	sinlam := math.Cos(phi) * math.Sin(lam-dlam)
+	if cosphi != 0 {
+		coslam /= cosphi
+		sinlam /= cosphi
+	}
+
+	rlat = math.Asin(sinphi) / radPerDeg
+	rlon = wrapLon((math.Atan2(sinlam, coslam) - dlam - lam0 + lamNP) / radPerDeg)
+	return rlat, rlon
+}
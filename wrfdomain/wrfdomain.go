@@ -0,0 +1,190 @@
+// Package wrfdomain implements a types.Region that
+// contains the points falling inside the grid of a WRF
+// domain, as described by geo_em or wrfinput files.
+package wrfdomain
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/meteocima/dewetra2wrf/internal/ncdf"
+	"github.com/meteocima/dewetra2wrf/types"
+)
+
+// Grid is the mass points grid of a WRF domain.
+// It implements types.Region: a point is contained
+// in the grid when it falls inside the area delimited
+// by the outermost mass points, excluding Margin cells
+// along each border.
+type Grid struct {
+	// Margin is the number of grid cells along each
+	// border of the domain whose points are excluded.
+	Margin float64
+	// Nx and Ny are the number of mass points
+	// along west-east and south-north directions.
+	Nx, Ny int
+
+	proj           Projection
+	refLon         float64
+	x0, y0, dx, dy float64
+	bounds         types.Domain
+}
+
+// NewGrid returns the Grid with given projection, whose mass
+// points have the given latitudes and longitudes. lats and lons
+// contain ny rows of nx values each, from south-west to north-east,
+// as XLAT_M and XLONG_M variables of geo_em files.
+// Grid spacing is computed from coordinates of the corners,
+// so that nested domains are handled as well.
+func NewGrid(proj Projection, lats, lons []float64, nx, ny int, margin float64) (*Grid, error) {
+	if err := proj.Validate(); err != nil {
+		return nil, err
+	}
+	if nx < 2 || ny < 2 || len(lats) != nx*ny || len(lons) != nx*ny {
+		return nil, fmt.Errorf("invalid grid of %dx%d points", nx, ny)
+	}
+	if margin < 0 || margin*2 >= float64(nx-1) || margin*2 >= float64(ny-1) {
+		return nil, fmt.Errorf("invalid margin of %g cells", margin)
+	}
+
+	g := &Grid{
+		Margin: margin,
+		Nx:     nx,
+		Ny:     ny,
+		proj:   proj,
+	}
+
+	center := ny/2*nx + nx/2
+	g.refLon = lons[center]
+	if proj.MapProj == LatLon {
+		_, g.refLon = proj.rotate(lats[center], lons[center])
+	}
+
+	g.x0, g.y0 = proj.project(lats[0], lons[0], g.refLon)
+	east, _ := proj.project(lats[nx-1], lons[nx-1], g.refLon)
+	_, north := proj.project(lats[(ny-1)*nx], lons[(ny-1)*nx], g.refLon)
+	g.dx = (east - g.x0) / float64(nx-1)
+	g.dy = (north - g.y0) / float64(ny-1)
+	if g.dx <= 0 || g.dy <= 0 {
+		return nil, fmt.Errorf("grid coordinates are not consistent with MAP_PROJ %d", proj.MapProj)
+	}
+
+	g.bounds = gridBounds(lats, lons, lons[center])
+	// grids containing a pole span all longitudes
+	if g.Contains(90, 0) {
+		g.bounds.MaxLat = 90
+		g.bounds.MinLon, g.bounds.MaxLon = -180, 180
+	}
+	if g.Contains(-90, 0) {
+		g.bounds.MinLat = -90
+		g.bounds.MinLon, g.bounds.MaxLon = -180, 180
+	}
+
+	return g, nil
+}
+
+// gridBounds returns the smallest Domain containing
+// points at lats, lons. Longitudes are taken around
+// cenLon, so that the bounds of a grid crossing the
+// antimeridian span from -180 to 180, since a Domain
+// can't wrap around it.
+func gridBounds(lats, lons []float64, cenLon float64) types.Domain {
+	bounds := types.Domain{
+		MinLat: math.Inf(1),
+		MinLon: math.Inf(1),
+		MaxLat: math.Inf(-1),
+		MaxLon: math.Inf(-1),
+	}
+	for i := range lats {
+		lon := cenLon + wrapLon(lons[i]-cenLon)
+		bounds.MinLat = math.Min(bounds.MinLat, lats[i])
+		bounds.MaxLat = math.Max(bounds.MaxLat, lats[i])
+		bounds.MinLon = math.Min(bounds.MinLon, lon)
+		bounds.MaxLon = math.Max(bounds.MaxLon, lon)
+	}
+	if bounds.MinLon < -180 || bounds.MaxLon > 180 {
+		bounds.MinLon, bounds.MaxLon = -180, 180
+	}
+	return bounds
+}
+
+// IJ returns the position of the point at lat, lon on
+// the grid, as fractional zero-based indexes of mass points
+// along west-east (i) and south-north (j) directions.
+func (g *Grid) IJ(lat, lon float64) (i, j float64) {
+	x, y := g.proj.project(lat, lon, g.refLon)
+	return (x - g.x0) / g.dx, (y - g.y0) / g.dy
+}
+
+// Contains implements types.Region for Grid
+func (g *Grid) Contains(lat, lon float64) bool {
+	i, j := g.IJ(lat, lon)
+	return i >= g.Margin && i <= float64(g.Nx-1)-g.Margin &&
+		j >= g.Margin && j <= float64(g.Ny-1)-g.Margin
+}
+
+// Bounds implements types.Region for Grid
+func (g *Grid) Bounds() types.Domain {
+	return g.bounds
+}
+
+// Open returns the Grid of the domain described by a
+// geo_em or wrfinput file. Stations within margin
+// cells from the borders are excluded from it.
+func Open(filename string, margin float64) (*Grid, error) {
+	f := ncdf.OpenFile(filename)
+	if f.Error() != nil {
+		return nil, fmt.Errorf("%s: %w", filename, f.Error())
+	}
+	defer f.Close()
+
+	proj := Projection{
+		MapProj:  int(f.AttribFloat64("MAP_PROJ")),
+		TrueLat1: f.AttribFloat64("TRUELAT1"),
+		TrueLat2: f.AttribFloat64("TRUELAT2"),
+		StandLon: f.AttribFloat64("STAND_LON"),
+	}
+	if proj.MapProj == LatLon {
+		proj.PoleLat = f.AttribFloat64("POLE_LAT")
+		proj.PoleLon = f.AttribFloat64("POLE_LON")
+	}
+
+	// geo_em files contain XLAT_M and XLONG_M,
+	// wrfinput ones XLAT and XLONG.
+	latName, lonName := "XLAT_M", "XLONG_M"
+	if _, ok := f.AllVars()[latName]; !ok {
+		latName, lonName = "XLAT", "XLONG"
+	}
+	latVar := f.Var(latName)
+	lonVar := f.Var(lonName)
+	dims := latVar.Dims()
+	lats := float32sTo64(latVar.ValuesFloat32())
+	lons := float32sTo64(lonVar.ValuesFloat32())
+	if f.Error() != nil {
+		return nil, fmt.Errorf("%s: %w", filename, f.Error())
+	}
+	if len(dims) < 2 {
+		return nil, fmt.Errorf("%s: %s is not bidimensional", filename, latName)
+	}
+
+	// only the first time of the file is used
+	ny, nx := int(dims[len(dims)-2]), int(dims[len(dims)-1])
+	if len(lats) < nx*ny || len(lons) < nx*ny {
+		return nil, fmt.Errorf("%s: %s and %s have different sizes", filename, latName, lonName)
+	}
+	lats, lons = lats[:nx*ny], lons[:nx*ny]
+
+	grid, err := NewGrid(proj, lats, lons, nx, ny, margin)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return grid, nil
+}
+
+func float32sTo64(values []float32) []float64 {
+	res := make([]float64, len(values))
+	for i, v := range values {
+		res[i] = float64(v)
+	}
+	return res
+}
@@ -0,0 +1,322 @@
+package wrfdomain
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/meteocima/dewetra2wrf/internal/ncdf"
+	"github.com/stretchr/testify/assert"
+)
+
+// unproject returns latitude and longitude of the point
+// at x, y on the projection plane, using Newton's method
+// starting from lat0, lon0.
+func unproject(p Projection, x, y, refLon, lat0, lon0 float64) (float64, float64) {
+	lat, lon := lat0, lon0
+	const h = 1e-6
+	for iter := 0; iter < 50; iter++ {
+		fx, fy := p.project(lat, lon, refLon)
+		xLat, yLat := p.project(lat+h, lon, refLon)
+		xLon, yLon := p.project(lat, lon+h, refLon)
+		a, b := (xLat-fx)/h, (xLon-fx)/h
+		c, d := (yLat-fy)/h, (yLon-fy)/h
+		det := a*d - b*c
+		ex, ey := x-fx, y-fy
+		lat += (d*ex - b*ey) / det
+		lon += (a*ey - c*ex) / det
+	}
+	return lat, lon
+}
+
+// testGrid returns latitudes and longitudes of a regular
+// grid of nx*ny points on projection p, centered at
+// cenLat, cenLon and with spacing dx, dy.
+func testGrid(p Projection, cenLat, cenLon, dx, dy float64, nx, ny int) ([]float64, []float64) {
+	refLon := cenLon
+	if p.MapProj == LatLon {
+		_, refLon = p.rotate(cenLat, cenLon)
+	}
+	cx, cy := p.project(cenLat, cenLon, refLon)
+	lats := make([]float64, nx*ny)
+	lons := make([]float64, nx*ny)
+	for j := 0; j < ny; j++ {
+		for i := 0; i < nx; i++ {
+			x := cx + (float64(i)-float64(nx-1)/2)*dx
+			y := cy + (float64(j)-float64(ny-1)/2)*dy
+			lats[j*nx+i], lons[j*nx+i] = unproject(p, x, y, refLon, cenLat, cenLon)
+		}
+	}
+	return lats, lons
+}
+
+var lambert = Projection{MapProj: Lambert, TrueLat1: 30, TrueLat2: 60, StandLon: 10}
+
+func TestLambertGrid(t *testing.T) {
+	lats, lons := testGrid(lambert, 45, 10, 10000, 10000, 101, 81)
+	g, err := NewGrid(lambert, lats, lons, 101, 81, 0)
+	assert.NoError(t, err)
+
+	i, j := g.IJ(45, 10)
+	assert.InDelta(t, 50, i, 1e-6)
+	assert.InDelta(t, 40, j, 1e-6)
+
+	i, j = g.IJ(lats[3*101+7], lons[3*101+7])
+	assert.InDelta(t, 7, i, 1e-6)
+	assert.InDelta(t, 3, j, 1e-6)
+
+	assert.True(t, g.Contains(45, 10))
+	// north-west corner of the bounding box falls outside
+	// the grid, since lambert grids are not rectangles
+	// on a lat-lon plane.
+	bounds := g.Bounds()
+	assert.True(t, bounds.Contains(bounds.MaxLat-0.01, bounds.MinLon+0.01))
+	assert.False(t, g.Contains(bounds.MaxLat-0.01, bounds.MinLon+0.01))
+	assert.False(t, g.Contains(45, 30))
+
+	// a station 2 cells away from the southern border
+	lat, lon := lats[2*101+50], lons[2*101+50]
+	assert.True(t, g.Contains(lat, lon))
+	g, err = NewGrid(lambert, lats, lons, 101, 81, 5)
+	assert.NoError(t, err)
+	assert.False(t, g.Contains(lat, lon))
+	assert.True(t, g.Contains(45, 10))
+}
+
+func TestOtherProjections(t *testing.T) {
+	projections := []Projection{
+		{MapProj: Lambert, TrueLat1: -30, TrueLat2: -60, StandLon: 150},
+		{MapProj: PolarStereo, TrueLat1: 60, StandLon: -45},
+		{MapProj: Mercator, TrueLat1: 0, StandLon: 179},
+		{MapProj: LatLon, PoleLat: 45, PoleLon: 180, StandLon: -10},
+	}
+	centers := [][2]float64{{-35, 150}, {70, -40}, {5, 179}, {45, 10}}
+	spacing := []float64{10000, 10000, 10000, 0.1}
+
+	for n, p := range projections {
+		lats, lons := testGrid(p, centers[n][0], centers[n][1], spacing[n], spacing[n], 41, 31)
+		g, err := NewGrid(p, lats, lons, 41, 31, 1)
+		assert.NoError(t, err)
+		i, j := g.IJ(centers[n][0], centers[n][1])
+		assert.InDelta(t, 20, i, 1e-6, "MAP_PROJ %d", p.MapProj)
+		assert.InDelta(t, 15, j, 1e-6, "MAP_PROJ %d", p.MapProj)
+		assert.True(t, g.Contains(lats[2*41+2], lons[2*41+2]), "MAP_PROJ %d", p.MapProj)
+		assert.False(t, g.Contains(lats[41], lons[41]), "MAP_PROJ %d", p.MapProj)
+	}
+}
+
+// geogrid returns latitudes and longitudes of the mass points of a
+// domain of nx*ny points centered at refLat, refLon with spacing dx
+// (meters, or degrees for LatLon), as written in XLAT_M and XLONG_M
+// by geogrid. It follows the ijll_* routines of WPS map_utils module,
+// which use closed-form inverse projections, independent from
+// project, and rounds values to float32 as stored in geo_em files.
+func geogrid(p Projection, refLat, refLon, dx float64, nx, ny int) ([]float64, []float64) {
+	const degPerRad = 180 / math.Pi
+	knownI, knownJ := float64(nx+1)/2, float64(ny+1)/2
+	rebydx := earthRadius / dx
+	hemi := 1.0
+	if p.TrueLat1 < 0 {
+		hemi = -1
+	}
+
+	var ijll func(i, j float64) (float64, float64)
+	switch p.MapProj {
+	case Lambert:
+		cone := math.Sin(math.Abs(p.TrueLat1) * radPerDeg)
+		if math.Abs(p.TrueLat1-p.TrueLat2) > 0.1 {
+			cone = (math.Log10(math.Cos(p.TrueLat1*radPerDeg)) - math.Log10(math.Cos(p.TrueLat2*radPerDeg))) /
+				(math.Log10(math.Tan((45-math.Abs(p.TrueLat1)/2)*radPerDeg)) -
+					math.Log10(math.Tan((45-math.Abs(p.TrueLat2)/2)*radPerDeg)))
+		}
+		rsw := rebydx * math.Cos(p.TrueLat1*radPerDeg) / cone *
+			math.Pow(math.Tan((90*hemi-refLat)*radPerDeg/2)/math.Tan((90*hemi-p.TrueLat1)*radPerDeg/2), cone)
+		arg := cone * wrapLon(refLon-p.StandLon) * radPerDeg
+		poleI := hemi*knownI - hemi*rsw*math.Sin(arg)
+		poleJ := hemi*knownJ + rsw*math.Cos(arg)
+		chi1 := (90 - hemi*p.TrueLat1) * radPerDeg
+		ijll = func(i, j float64) (float64, float64) {
+			xx, yy := hemi*i-poleI, poleJ-hemi*j
+			r := math.Hypot(xx, yy) / rebydx
+			lon := p.StandLon + math.Atan2(hemi*xx, yy)*degPerRad/cone
+			chi := 2 * math.Atan(math.Pow(r*cone/math.Sin(chi1), 1/cone)*math.Tan(chi1/2))
+			return (90 - chi*degPerRad) * hemi, lon
+		}
+	case PolarStereo:
+		refPoleLon := p.StandLon + 90
+		scaleTop := 1 + hemi*math.Sin(p.TrueLat1*radPerDeg)
+		rsw := rebydx * math.Cos(refLat*radPerDeg) * scaleTop / (1 + hemi*math.Sin(refLat*radPerDeg))
+		alo1 := (refLon - refPoleLon) * radPerDeg
+		poleI := knownI - rsw*math.Cos(alo1)
+		poleJ := knownJ - hemi*rsw*math.Sin(alo1)
+		gi2 := math.Pow(rebydx*scaleTop, 2)
+		ijll = func(i, j float64) (float64, float64) {
+			xx, yy := i-poleI, (j-poleJ)*hemi
+			r2 := xx*xx + yy*yy
+			lat := math.Asin((gi2-r2)/(gi2+r2)) * degPerRad * hemi
+			lon := refPoleLon - math.Acos(xx/math.Sqrt(r2))*degPerRad
+			if yy > 0 {
+				lon = refPoleLon + math.Acos(xx/math.Sqrt(r2))*degPerRad
+			}
+			return lat, lon
+		}
+	case Mercator:
+		dlon := dx / (earthRadius * math.Cos(p.TrueLat1*radPerDeg))
+		rsw := math.Log(math.Tan((refLat+90)*radPerDeg/2)) / dlon
+		ijll = func(i, j float64) (float64, float64) {
+			lat := 2*math.Atan(math.Exp(dlon*(rsw+j-knownJ)))*degPerRad - 90
+			return lat, (i-knownI)*dlon*degPerRad + refLon
+		}
+	case LatLon:
+		// rotate_coords from computational to geographic
+		// coordinates, the inverse of Projection.rotate.
+		phiNP := p.PoleLat * radPerDeg
+		refRLat, refRLon := p.rotate(refLat, refLon)
+		ijll = func(i, j float64) (float64, float64) {
+			rlat := (refRLat + (j-knownJ)*dx) * radPerDeg
+			rlon := (refRLon + (i-knownI)*dx) * radPerDeg
+			dlam := p.PoleLon * radPerDeg
+			sinphi := math.Cos(phiNP)*math.Cos(rlat)*math.Cos(rlon-dlam) + math.Sin(phiNP)*math.Sin(rlat)
+			cosphi := math.Sqrt(1 - sinphi*sinphi)
+			coslam := (math.Sin(phiNP)*math.Cos(rlat)*math.Cos(rlon-dlam) - math.Cos(phiNP)*math.Sin(rlat)) / cosphi
+			sinlam := math.Cos(rlat) * math.Sin(rlon-dlam) / cosphi
+			return math.Asin(sinphi) * degPerRad, math.Atan2(sinlam, coslam)*degPerRad - p.StandLon
+		}
+	}
+
+	lats := make([]float64, nx*ny)
+	lons := make([]float64, nx*ny)
+	for j := 0; j < ny; j++ {
+		for i := 0; i < nx; i++ {
+			lat, lon := ijll(float64(i+1), float64(j+1))
+			lats[j*nx+i] = float64(float32(lat))
+			lons[j*nx+i] = float64(float32(wrapLon(lon)))
+		}
+	}
+	return lats, lons
+}
+
+func TestGeogridDomains(t *testing.T) {
+	tests := []struct {
+		proj              Projection
+		refLat, refLon    float64
+		dx                float64
+		minLon, maxLon    float64
+		minLat, maxLat    float64
+		containsNorthPole bool
+	}{
+		// the domain of the WRF tutorial case, on the
+		// Lambert projection of the default namelist.wps
+		{proj: Projection{MapProj: Lambert, TrueLat1: 30, TrueLat2: 60, StandLon: -98},
+			refLat: 34.83, refLon: -81.03, dx: 30000},
+		{proj: Projection{MapProj: Lambert, TrueLat1: -30, TrueLat2: -60, StandLon: 150},
+			refLat: -35, refLon: 150, dx: 12000},
+		// a domain containing the north pole
+		{proj: Projection{MapProj: PolarStereo, TrueLat1: 60, StandLon: -45},
+			refLat: 88, refLon: -45, dx: 25000, containsNorthPole: true},
+		{proj: Projection{MapProj: PolarStereo, TrueLat1: -71, StandLon: 0},
+			refLat: -75, refLon: 40, dx: 20000},
+		// a domain crossing the antimeridian
+		{proj: Projection{MapProj: Mercator, TrueLat1: 0, StandLon: 179},
+			refLat: 5, refLon: 179, dx: 20000},
+		{proj: Projection{MapProj: LatLon, PoleLat: 45, PoleLon: 180, StandLon: -10},
+			refLat: 45, refLon: 10, dx: 0.1},
+	}
+
+	const nx, ny = 73, 60
+	for _, test := range tests {
+		msg := fmt.Sprintf("MAP_PROJ %d centered at %g, %g", test.proj.MapProj, test.refLat, test.refLon)
+		lats, lons := geogrid(test.proj, test.refLat, test.refLon, test.dx, nx, ny)
+		g, err := NewGrid(test.proj, lats, lons, nx, ny, 0)
+		if !assert.NoError(t, err, msg) {
+			continue
+		}
+
+		// the reference point is at the center of
+		// the mass grid, 1-based as in WPS.
+		i, j := g.IJ(test.refLat, test.refLon)
+		assert.InDelta(t, float64(nx-1)/2, i, 1e-3, msg)
+		assert.InDelta(t, float64(ny-1)/2, j, 1e-3, msg)
+
+		for _, corner := range [][2]int{{0, 0}, {nx - 1, 0}, {0, ny - 1}, {nx - 1, ny - 1}, {10, 50}} {
+			n := corner[1]*nx + corner[0]
+			i, j := g.IJ(lats[n], lons[n])
+			assert.InDelta(t, float64(corner[0]), i, 1e-2, msg)
+			assert.InDelta(t, float64(corner[1]), j, 1e-2, msg)
+		}
+
+		bounds := g.Bounds()
+		for n := range lats {
+			assert.True(t, bounds.Contains(lats[n], lons[n]), msg)
+		}
+		assert.Equal(t, test.containsNorthPole, bounds.MaxLat == 90, msg)
+	}
+}
+
+func TestBoundsAcrossAntimeridian(t *testing.T) {
+	p := Projection{MapProj: Mercator, TrueLat1: 0, StandLon: 179}
+	lats, lons := geogrid(p, 5, 179, 20000, 41, 31)
+	g, err := NewGrid(p, lats, lons, 41, 31, 0)
+	assert.NoError(t, err)
+	assert.True(t, g.Contains(5, -179.5))
+	assert.True(t, g.Contains(5, 178.5))
+	bounds := g.Bounds()
+	assert.Equal(t, -180.0, bounds.MinLon)
+	assert.Equal(t, 180.0, bounds.MaxLon)
+	assert.True(t, bounds.Contains(5, -179.5))
+	assert.True(t, bounds.Contains(5, 178.5))
+
+	lats, lons = geogrid(p, 5, 170, 20000, 41, 31)
+	g, err = NewGrid(p, lats, lons, 41, 31, 0)
+	assert.NoError(t, err)
+	assert.InDelta(t, 166.4, g.Bounds().MinLon, 0.1)
+	assert.InDelta(t, 173.6, g.Bounds().MaxLon, 0.1)
+}
+
+func TestNewGridErrors(t *testing.T) {
+	lats, lons := testGrid(lambert, 45, 10, 10000, 10000, 11, 11)
+	_, err := NewGrid(Projection{MapProj: 4}, lats, lons, 11, 11, 0)
+	assert.EqualError(t, err, "unsupported MAP_PROJ 4")
+	_, err = NewGrid(lambert, lats, lons, 10, 11, 0)
+	assert.EqualError(t, err, "invalid grid of 10x11 points")
+	_, err = NewGrid(lambert, lats, lons, 11, 11, 5)
+	assert.EqualError(t, err, "invalid margin of 5 cells")
+}
+
+func TestOpen(t *testing.T) {
+	const nx, ny = 21, 16
+	lats, lons := testGrid(lambert, 45, 10, 10000, 10000, nx, ny)
+	lats32 := make([]float32, nx*ny)
+	lons32 := make([]float32, nx*ny)
+	for i := range lats {
+		lats32[i] = float32(lats[i])
+		lons32[i] = float32(lons[i])
+	}
+
+	file := filepath.Join(t.TempDir(), "geo_em.d01.nc")
+	f := ncdf.CreateFile(file)
+	f.SetAttribInt32s("MAP_PROJ", Lambert)
+	f.SetAttribFloat32s("TRUELAT1", 30)
+	f.SetAttribFloat32s("TRUELAT2", 60)
+	f.SetAttribFloat32s("STAND_LON", 10)
+	f.AddDim("Time", 1)
+	f.AddDim("south_north", ny)
+	f.AddDim("west_east", nx)
+	lat := f.AddVar("XLAT_M", ncdf.Float32, "Time", "south_north", "west_east")
+	lon := f.AddVar("XLONG_M", ncdf.Float32, "Time", "south_north", "west_east")
+	f.EndDef()
+	lat.WriteFloat32s(lats32)
+	lon.WriteFloat32s(lons32)
+	f.Close()
+	assert.NoError(t, f.Error())
+
+	g, err := Open(file, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, nx, g.Nx)
+	assert.Equal(t, ny, g.Ny)
+	i, j := g.IJ(45, 10)
+	assert.InDelta(t, 10, i, 1e-3)
+	assert.InDelta(t, 7.5, j, 1e-3)
+	assert.False(t, math.IsNaN(g.Bounds().MaxLat))
+}